@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// the most rounds of tool calls the model can make before it has to answer
+const maxToolRounds = 4
+
+// returned when the model sends back a completion without any choices
+var errNoResponse = errors.New("no OpenAI response found")
+
+// ChatProvider creates chat completions. *openai.Client is one; tests use a scripted fake.
+type ChatProvider interface {
+	CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error)
+}
+
+// Agent holds a conversation with the model, running the tools it calls against the database
+type Agent struct {
+	db       *Db
+	chat     ChatProvider
+	model    string
+	tools    []openai.Tool
+	dialogue []openai.ChatCompletionMessage
+}
+
+// AgentTurn is the answer to one question and what the tools returned along the way
+type AgentTurn struct {
+	Answer string
+	// every course a tool returned during this turn, by CRN, so the answer's citations can be checked
+	Sources   map[string]Course
+	ToolCalls []openai.ToolCall
+}
+
+func NewAgent(db *Db, chat ChatProvider) *Agent {
+	return &Agent{
+		db:       db,
+		chat:     chat,
+		model:    openai.GPT4oMini,
+		tools:    AllTools(),
+		dialogue: InitializeDialogue(),
+	}
+}
+
+// sends the question to the model and runs the tools it calls until it answers. If the question
+// fails, the dialogue is left as it was before it so that it can be asked again.
+func (a *Agent) Ask(ctx context.Context, question string) (AgentTurn, error) {
+	before := a.checkpoint()
+	turn, err := a.ask(ctx, question)
+	if err != nil {
+		a.rewind(before)
+	}
+	return turn, err
+}
+
+// the point in the dialogue that rewind goes back to
+func (a *Agent) checkpoint() int {
+	return len(a.dialogue)
+}
+
+// forgets every message after the checkpoint, as if the questions since were never asked
+func (a *Agent) rewind(checkpoint int) {
+	if checkpoint < len(a.dialogue) {
+		a.dialogue = a.dialogue[:checkpoint]
+	}
+}
+
+func (a *Agent) ask(ctx context.Context, question string) (AgentTurn, error) {
+	turn := AgentTurn{Sources: make(map[string]Course)}
+	// the tools query the database within the question's context, so they stop when it ends
+	db := a.db.withContext(ctx)
+	a.dialogue = append(a.dialogue, openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: question,
+	})
+
+	for round := 0; ; round++ {
+		request := openai.ChatCompletionRequest{
+			Model:    a.model,
+			Messages: a.dialogue,
+		}
+		// once the model is out of tool rounds it has to answer with what it has
+		if round < maxToolRounds {
+			request.Tools = a.tools
+		}
+
+		msg, err := a.complete(ctx, request)
+		if err != nil {
+			return turn, err
+		}
+		a.dialogue = append(a.dialogue, msg)
+		if len(msg.ToolCalls) == 0 {
+			turn.Answer = msg.Content
+			return turn, nil
+		}
+
+		// if the AI called tools, handle the tools appropriately
+		for _, tool := range msg.ToolCalls {
+			fmt.Printf("OpenAI called us back wanting to invoke our function '%v' with params '%v'\n",
+				tool.Function.Name, tool.Function.Arguments)
+
+			content, retrieved, err := runTool(db, tool)
+			if err != nil {
+				return turn, err
+			}
+			// a tool that doesn't use the context still ends the question once it is cancelled
+			if err := ctx.Err(); err != nil {
+				return turn, err
+			}
+			for _, course := range retrieved {
+				turn.Sources[course.CRN] = course
+			}
+			turn.ToolCalls = append(turn.ToolCalls, tool)
+
+			// append the tool's response to our dialogue as a new chat message
+			a.dialogue = append(a.dialogue, openai.ChatCompletionMessage{
+				Role:       openai.ChatMessageRoleTool,
+				Content:    content,
+				Name:       tool.Function.Name,
+				ToolCallID: tool.ID,
+			})
+		}
+		fmt.Printf("Sending OpenAI our function's response and requesting the reply to the original question...\n")
+	}
+}
+
+// asks the model to rewrite its last answer given the feedback, and replaces the answer in the
+// dialogue with the rewritten one. The feedback itself isn't kept in the dialogue.
+func (a *Agent) Revise(ctx context.Context, feedback string) (string, error) {
+	revision := append(append([]openai.ChatCompletionMessage{}, a.dialogue...), openai.ChatCompletionMessage{
+		Role:    openai.ChatMessageRoleUser,
+		Content: feedback,
+	})
+	msg, err := a.complete(ctx, openai.ChatCompletionRequest{
+		Model:    a.model,
+		Messages: revision,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	if last := len(a.dialogue) - 1; last >= 0 && a.dialogue[last].Role == openai.ChatMessageRoleAssistant {
+		a.dialogue[last] = msg
+	}
+	return msg.Content, nil
+}
+
+// sends a completion request and returns the model's message
+func (a *Agent) complete(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionMessage, error) {
+	resp, err := a.chat.CreateChatCompletion(ctx, request)
+	if err != nil {
+		return openai.ChatCompletionMessage{}, fmt.Errorf("completion error: %w", err)
+	}
+	if len(resp.Choices) == 0 {
+		return openai.ChatCompletionMessage{}, errNoResponse
+	}
+	return resp.Choices[0].Message, nil
+}
@@ -0,0 +1,261 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestAgentAsk(t *testing.T) {
+	tests := []struct {
+		name            string
+		script          []openai.ChatCompletionMessage
+		expectedAnswer  string
+		expectedSources []string
+		expectedTools   []string
+	}{
+		{
+			name:           "answers without tools",
+			script:         []openai.ChatCompletionMessage{answerMessage("Hello! Ask me about courses.")},
+			expectedAnswer: "Hello! Ask me about courses.",
+		},
+		{
+			name: "searches then answers",
+			script: []openai.ChatCompletionMessage{
+				toolCallMessage("call_1", "get_relevant_courses", `{"InstructorFullName": "Phil Peterson"}`),
+				answerMessage("Philip Peterson teaches Software Development [CRN 40646]."),
+			},
+			expectedAnswer:  "Philip Peterson teaches Software Development [CRN 40646].",
+			expectedSources: []string{"40646", "42344"},
+			expectedTools:   []string{"get_relevant_courses"},
+		},
+		{
+			name: "several tool rounds",
+			script: []openai.ChatCompletionMessage{
+				toolCallMessage("call_1", "get_relevant_courses", `{"Subject": "BIOL"}`),
+				toolCallMessage("call_2", "check_eligibility", `{"completed_courses": [], "courses": ["CS 315"]}`),
+				answerMessage("You can take CS 315 [CRN 40649]."),
+			},
+			expectedAnswer:  "You can take CS 315 [CRN 40649].",
+			expectedSources: []string{"40519", "40649"},
+			expectedTools:   []string{"get_relevant_courses", "check_eligibility"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chat := &fakeChat{script: test.script}
+			agent := NewAgent(newFakeDb(t, fakeCourses), chat)
+
+			turn, err := agent.Ask(context.Background(), "question")
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if turn.Answer != test.expectedAnswer {
+				t.Errorf("Expected answer %q, got %q", test.expectedAnswer, turn.Answer)
+			}
+
+			sources := []string{}
+			for crn := range turn.Sources {
+				sources = append(sources, crn)
+			}
+			sort.Strings(sources)
+			if test.expectedSources == nil {
+				test.expectedSources = []string{}
+			}
+			if !reflect.DeepEqual(sources, test.expectedSources) {
+				t.Errorf("Expected sources %v, got %v", test.expectedSources, sources)
+			}
+
+			var tools []string
+			for _, call := range turn.ToolCalls {
+				tools = append(tools, call.Function.Name)
+			}
+			if !reflect.DeepEqual(tools, test.expectedTools) {
+				t.Errorf("Expected tool calls %v, got %v", test.expectedTools, tools)
+			}
+
+			if len(chat.requests) != len(test.script) {
+				t.Fatalf("Expected %d requests, got %d", len(test.script), len(chat.requests))
+			}
+			// every tool call is answered in the next request
+			for i, msg := range test.script[:len(test.script)-1] {
+				next := chat.requests[i+1].Messages
+				for _, call := range msg.ToolCalls {
+					if !hasToolResponse(next, call.ID) {
+						t.Errorf("Request %d has no response to tool call %s", i+1, call.ID)
+					}
+				}
+			}
+		})
+	}
+}
+
+func hasToolResponse(messages []openai.ChatCompletionMessage, toolCallID string) bool {
+	for _, msg := range messages {
+		if msg.Role == openai.ChatMessageRoleTool && msg.ToolCallID == toolCallID && msg.Content != "" {
+			return true
+		}
+	}
+	return false
+}
+
+func TestAgentAskStopsOfferingToolsAfterMaxRounds(t *testing.T) {
+	var script []openai.ChatCompletionMessage
+	for i := 0; i < maxToolRounds; i++ {
+		script = append(script, toolCallMessage("call", "get_relevant_courses", `{"Subject": "CS"}`))
+	}
+	script = append(script, answerMessage("done"))
+	chat := &fakeChat{script: script}
+
+	turn, err := NewAgent(newFakeDb(t, fakeCourses), chat).Ask(context.Background(), "question")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if turn.Answer != "done" {
+		t.Errorf("Expected the answer after the last round, got %q", turn.Answer)
+	}
+	for i, request := range chat.requests {
+		if offered := len(request.Tools) > 0; offered != (i < maxToolRounds) {
+			t.Errorf("Request %d offered tools: %v", i, offered)
+		}
+	}
+}
+
+func TestAgentAskReportsCompletionErrors(t *testing.T) {
+	chat := &fakeChat{}
+	agent := NewAgent(newFakeDb(t, fakeCourses), chat)
+	before := len(agent.dialogue)
+	if _, err := agent.Ask(context.Background(), "question"); err == nil {
+		t.Errorf("Expected an error when the model doesn't respond")
+	}
+	// the failed question isn't left in the dialogue, so it can be asked again
+	if len(agent.dialogue) != before {
+		t.Errorf("Expected %d messages in the dialogue after the failure, got %d", before, len(agent.dialogue))
+	}
+}
+
+func TestAgentRevise(t *testing.T) {
+	chat := &fakeChat{script: []openai.ChatCompletionMessage{
+		answerMessage("CS 272 meets at 3:00 PM [CRN 40646]."),
+		answerMessage("CS 272 meets at 2:40 PM [CRN 40646]."),
+	}}
+	agent := NewAgent(newFakeDb(t, fakeCourses), chat)
+
+	if _, err := agent.Ask(context.Background(), "When does CS 272 meet?"); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	revised, err := agent.Revise(context.Background(), "Fix the time.")
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if revised != "CS 272 meets at 2:40 PM [CRN 40646]." {
+		t.Errorf("Unexpected revision %q", revised)
+	}
+
+	sent := chat.requests[1].Messages
+	if last := sent[len(sent)-1]; last.Role != openai.ChatMessageRoleUser || last.Content != "Fix the time." {
+		t.Errorf("Expected the feedback to be sent last, got %+v", last)
+	}
+	// the dialogue keeps the revised answer and not the feedback
+	last := agent.dialogue[len(agent.dialogue)-1]
+	if last.Content != revised {
+		t.Errorf("Expected the dialogue to end with the revision, got %q", last.Content)
+	}
+	for _, msg := range agent.dialogue {
+		if strings.Contains(msg.Content, "Fix the time.") {
+			t.Errorf("The feedback was kept in the dialogue")
+		}
+	}
+}
+
+// unreachableCollection is a fakeCollection whose reads fail with err
+type unreachableCollection struct {
+	*fakeCollection
+	err error
+}
+
+func (c *unreachableCollection) Query(ctx context.Context, text string, nResults int, where map[string]interface{}) (CollectionResults, error) {
+	return CollectionResults{}, c.err
+}
+
+func (c *unreachableCollection) Get(ctx context.Context, ids []string, where map[string]interface{}) (CollectionResults, error) {
+	return CollectionResults{}, c.err
+}
+
+func TestAgentAskReportsStoreErrors(t *testing.T) {
+	search := toolCallMessage("call_1", "get_relevant_courses", `{"Subject": "CS"}`)
+
+	t.Run("busy", func(t *testing.T) {
+		db := newFakeDb(t, fakeCourses)
+		db.coursesCollection = &unreachableCollection{fakeCollection: newFakeCollection(), err: fmt.Errorf("error running filtered query: %w", ErrCircuitOpen)}
+		chat := &fakeChat{script: []openai.ChatCompletionMessage{search, answerMessage("unused")}}
+		agent := NewAgent(db, chat)
+		before := agent.checkpoint()
+
+		_, err := agent.Ask(context.Background(), "question")
+		if !isBusyError(err) {
+			t.Fatalf("Expected a busy error, got %v", err)
+		}
+		if len(chat.requests) != 1 || agent.checkpoint() != before {
+			t.Errorf("Expected the question to end after the search, got %d requests and %d messages", len(chat.requests), agent.checkpoint())
+		}
+	})
+
+	t.Run("other failures go to the model", func(t *testing.T) {
+		db := newFakeDb(t, fakeCourses)
+		db.coursesCollection = &unreachableCollection{fakeCollection: newFakeCollection(), err: errors.New("malformed filter")}
+		chat := &fakeChat{script: []openai.ChatCompletionMessage{search, answerMessage("Sorry, the search failed.")}}
+
+		if _, err := NewAgent(db, chat).Ask(context.Background(), "question"); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		messages := chat.requests[1].Messages
+		if last := messages[len(messages)-1]; !strings.Contains(last.Content, "the course search failed: ") {
+			t.Errorf("Expected the failure as the tool's response, got %q", last.Content)
+		}
+	})
+}
+
+// cancellingCollection cancels the question when it is queried, as Ctrl-C during a search would
+type cancellingCollection struct {
+	*fakeCollection
+	cancel context.CancelFunc
+}
+
+func (c *cancellingCollection) Query(ctx context.Context, text string, nResults int, where map[string]interface{}) (CollectionResults, error) {
+	c.cancel()
+	return CollectionResults{}, ctx.Err()
+}
+
+func (c *cancellingCollection) Get(ctx context.Context, ids []string, where map[string]interface{}) (CollectionResults, error) {
+	c.cancel()
+	return CollectionResults{}, ctx.Err()
+}
+
+func TestAgentAskStopsWhenCancelledDuringTool(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	db := newFakeDb(t, fakeCourses)
+	db.coursesCollection = &cancellingCollection{fakeCollection: newFakeCollection(), cancel: cancel}
+	chat := &fakeChat{script: []openai.ChatCompletionMessage{
+		toolCallMessage("call_1", "get_relevant_courses", `{"Subject": "CS"}`),
+		answerMessage("unused"),
+	}}
+	agent := NewAgent(db, chat)
+	before := agent.checkpoint()
+
+	_, err := agent.Ask(ctx, "question")
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("Expected the question to be cancelled, got %v", err)
+	}
+	if len(chat.requests) != 1 || agent.checkpoint() != before {
+		t.Errorf("Expected the question to stop at the search and be dropped, got %d requests and %d messages", len(chat.requests), agent.checkpoint())
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"strings"
+	"unicode"
+)
+
+// canonicalCandidate is a name from the instructors or subjects collection and how far it is from
+// the text that was looked up
+type canonicalCandidate struct {
+	Name     string
+	Distance float32
+}
+
+// the k names in the collection closest to the text, closest first
+func lookupCanonical(db *Db, collection VectorCollection, text string, k int) ([]canonicalCandidate, error) {
+	results, err := collection.Query(db.ctx, text, k, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	candidates := make([]canonicalCandidate, 0, len(results.Documents))
+	for i, name := range results.Documents {
+		// collections ingested before unassigned instructors were left out have the instructor " "
+		if strings.TrimSpace(name) == "" {
+			continue
+		}
+		candidate := canonicalCandidate{Name: name}
+		if i < len(results.Distances) {
+			candidate.Distance = results.Distances[i]
+		}
+		candidates = append(candidates, candidate)
+	}
+	return candidates, nil
+}
+
+// the kinds of noise added to a canonical name
+const (
+	variantNickname      = "nickname"
+	variantTypo          = "typo"
+	variantMissingLetter = "missing_letter"
+	variantLastNameOnly  = "last_name_only"
+	variantSwapped       = "swapped_order"
+	variantNoAccents     = "no_accents"
+	variantLowercase     = "lowercase"
+	variantAbbreviated   = "abbreviated"
+)
+
+// common short forms of first names, as students would write them
+var nicknames = map[string]string{
+	"Alexander": "Alex", "Alexandra": "Alex", "Andrew": "Andy", "Anthony": "Tony", "Benjamin": "Ben",
+	"Catherine": "Cathy", "Christina": "Chris", "Christopher": "Chris", "Daniel": "Dan", "David": "Dave",
+	"Deborah": "Deb", "Edward": "Ed", "Elizabeth": "Liz", "Gregory": "Greg", "Jackson": "Jack",
+	"James": "Jim", "Jennifer": "Jen", "Jonathan": "Jon", "Joseph": "Joe", "Joshua": "Josh",
+	"Katherine": "Kate", "Kathryn": "Kate", "Kenneth": "Ken", "Margaret": "Maggie", "Matthew": "Matt",
+	"Michael": "Mike", "Nathaniel": "Nate", "Nicholas": "Nick", "Patricia": "Pat", "Philip": "Phil",
+	"Rebecca": "Becky", "Richard": "Rick", "Robert": "Rob", "Ronald": "Ron", "Samuel": "Sam",
+	"Stephen": "Steve", "Steven": "Steve", "Susan": "Sue", "Thomas": "Tom", "Timothy": "Tim",
+	"Victoria": "Vicky", "William": "Will", "Zachary": "Zach",
+}
+
+// the plain letter of common accented letters
+var accentFolds = map[rune]rune{
+	'á': 'a', 'à': 'a', 'â': 'a', 'ä': 'a', 'ã': 'a', 'å': 'a', 'é': 'e', 'è': 'e', 'ê': 'e', 'ë': 'e',
+	'í': 'i', 'ì': 'i', 'î': 'i', 'ï': 'i', 'ó': 'o', 'ò': 'o', 'ô': 'o', 'ö': 'o', 'õ': 'o', 'ø': 'o',
+	'ú': 'u', 'ù': 'u', 'û': 'u', 'ü': 'u', 'ñ': 'n', 'ç': 'c', 'ý': 'y',
+	'Á': 'A', 'À': 'A', 'Â': 'A', 'Ä': 'A', 'Ã': 'A', 'Å': 'A', 'É': 'E', 'È': 'E', 'Ê': 'E', 'Ë': 'E',
+	'Í': 'I', 'Ì': 'I', 'Î': 'I', 'Ï': 'I', 'Ó': 'O', 'Ò': 'O', 'Ô': 'O', 'Ö': 'O', 'Õ': 'O', 'Ø': 'O',
+	'Ú': 'U', 'Ù': 'U', 'Û': 'U', 'Ü': 'U', 'Ñ': 'N', 'Ç': 'C', 'Ý': 'Y',
+}
+
+// nameVariant is a noisy spelling of a canonical name
+type nameVariant struct {
+	Kind string
+	Text string
+}
+
+// the noisy spellings of an instructor's full name a student might type
+func instructorVariants(name string) []nameVariant {
+	fields := strings.Fields(name)
+	if len(fields) == 0 {
+		return nil
+	}
+	first, last := fields[0], fields[len(fields)-1]
+
+	var variants []nameVariant
+	if nickname, exists := nicknames[first]; exists {
+		variants = append(variants, nameVariant{variantNickname, nickname + " " + strings.Join(fields[1:], " ")})
+	}
+	variants = append(variants, misspellings(name)...)
+	if len(fields) > 1 {
+		variants = append(variants,
+			nameVariant{variantLastNameOnly, last},
+			nameVariant{variantSwapped, last + ", " + strings.Join(fields[:len(fields)-1], " ")},
+		)
+	}
+	return withoutDuplicates(name, variants)
+}
+
+// the noisy spellings of a course title a student might type
+func subjectVariants(title string) []nameVariant {
+	variants := misspellings(title)
+	// cut the longest word short, as in "Software Dev"
+	if words := strings.Fields(title); len(words) > 1 {
+		i := longestWord(words)
+		if runes := []rune(words[i]); len(runes) > 4 {
+			words[i] = string(runes[:3])
+			variants = append(variants, nameVariant{variantAbbreviated, strings.Join(words, " ")})
+		}
+	}
+	return withoutDuplicates(title, variants)
+}
+
+// variants every name gets: typos, missing accents and lowercase
+func misspellings(name string) []nameVariant {
+	var variants []nameVariant
+	words := strings.Fields(name)
+	if len(words) > 0 {
+		i := longestWord(words)
+		if runes := []rune(words[i]); len(runes) >= 4 {
+			// swap two letters in the middle of the longest word
+			middle := len(runes) / 2
+			swapped := append([]rune{}, runes...)
+			swapped[middle-1], swapped[middle] = swapped[middle], swapped[middle-1]
+			variants = append(variants, nameVariant{variantTypo, replaceWord(words, i, string(swapped))})
+
+			dropped := append(append([]rune{}, runes[:middle]...), runes[middle+1:]...)
+			variants = append(variants, nameVariant{variantMissingLetter, replaceWord(words, i, string(dropped))})
+		}
+	}
+	variants = append(variants,
+		nameVariant{variantNoAccents, foldAccents(name)},
+		nameVariant{variantLowercase, strings.ToLower(name)},
+	)
+	return variants
+}
+
+// drops variants that are identical to the name or to an earlier variant
+func withoutDuplicates(name string, variants []nameVariant) []nameVariant {
+	seen := map[string]bool{name: true}
+	var unique []nameVariant
+	for _, variant := range variants {
+		if seen[variant.Text] {
+			continue
+		}
+		seen[variant.Text] = true
+		unique = append(unique, variant)
+	}
+	return unique
+}
+
+func longestWord(words []string) int {
+	longest := 0
+	for i, word := range words {
+		if len([]rune(word)) > len([]rune(words[longest])) {
+			longest = i
+		}
+	}
+	return longest
+}
+
+func replaceWord(words []string, i int, word string) string {
+	replaced := append([]string{}, words...)
+	replaced[i] = word
+	return strings.Join(replaced, " ")
+}
+
+// replaces accented letters with their plain letters, e.g. "Pérez" becomes "Perez"
+func foldAccents(text string) string {
+	return strings.Map(func(r rune) rune {
+		if plain, exists := accentFolds[r]; exists {
+			return plain
+		}
+		if r > unicode.MaxASCII && unicode.Is(unicode.Mn, r) {
+			return -1
+		}
+		return r
+	}, text)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNameVariants(t *testing.T) {
+	tests := []struct {
+		name     string
+		variants []nameVariant
+		expected []nameVariant
+	}{
+		{
+			name:     "instructor",
+			variants: instructorVariants("Philip Peterson"),
+			expected: []nameVariant{
+				{variantNickname, "Phil Peterson"},
+				{variantTypo, "Philip Petreson"},
+				{variantMissingLetter, "Philip Peteson"},
+				{variantLowercase, "philip peterson"},
+				{variantLastNameOnly, "Peterson"},
+				{variantSwapped, "Peterson, Philip"},
+			},
+		},
+		{
+			name:     "instructor with accents",
+			variants: instructorVariants("Abdiel Portalatín Pérez"),
+			expected: []nameVariant{
+				{variantTypo, "Abdiel Portlaatín Pérez"},
+				{variantMissingLetter, "Abdiel Portaatín Pérez"},
+				{variantNoAccents, "Abdiel Portalatin Perez"},
+				{variantLowercase, "abdiel portalatín pérez"},
+				{variantLastNameOnly, "Pérez"},
+				{variantSwapped, "Pérez, Abdiel Portalatín"},
+			},
+		},
+		{
+			name:     "subject",
+			variants: subjectVariants("Software Development"),
+			expected: []nameVariant{
+				{variantTypo, "Software Deveolpment"},
+				{variantMissingLetter, "Software Develpment"},
+				{variantLowercase, "software development"},
+				{variantAbbreviated, "Software Dev"},
+			},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if !reflect.DeepEqual(test.variants, test.expected) {
+				t.Errorf("Expected %v, got %v", test.expected, test.variants)
+			}
+		})
+	}
+}
+
+func TestScoreCanonicalBenchmark(t *testing.T) {
+	outcomes := []probeOutcome{
+		{canonicalProbe{"instructors", variantNickname, "Phil Peterson", "Philip Peterson"}, []canonicalCandidate{{"Philip Peterson", 0.1}, {"Philip Choong", 0.3}}},
+		{canonicalProbe{"instructors", variantNickname, "Greg Benson", "Gregory Benson"}, []canonicalCandidate{{"Greg Smith", 0.2}, {"Gregory Benson", 0.25}}},
+		{canonicalProbe{"instructors", variantLastNameOnly, "Choong", "Philip Choong"}, []canonicalCandidate{{"Philip Choong", 0.4}}},
+		{canonicalProbe{"instructors", variantUnknown, "Philip Benson", ""}, []canonicalCandidate{{"Philip Peterson", 0.15}}},
+	}
+
+	scores := scoreCanonicalBenchmark(outcomes, 2, []float64{0.1, 0.3})
+	expected := canonicalScores{
+		ByVariant: map[string]canonicalStats{
+			variantNickname:     {Probes: 2, Top1: 1, TopK: 2},
+			variantLastNameOnly: {Probes: 1, Top1: 1, TopK: 1},
+		},
+		Thresholds: []thresholdRates{
+			{Threshold: 0.1, Accepted: 1.0 / 3, FalseAccepted: 0, UnknownAccepted: 0},
+			{Threshold: 0.3, Accepted: 1.0 / 3, FalseAccepted: 1.0 / 3, UnknownAccepted: 1},
+		},
+	}
+	if !reflect.DeepEqual(scores["instructors"], expected) {
+		t.Errorf("Expected %+v, got %+v", expected, scores["instructors"])
+	}
+}
+
+func TestRunCanonicalBenchmark(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+	instructors, subjects := canonicalNames(fakeCourses)
+	outcomes, err := runCanonicalBenchmark(db, canonicalProbes(instructors, subjects, 0), 3)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	// the fake collection matches on whole words, so a last name alone always finds its instructor
+	scores := scoreCanonicalBenchmark(outcomes, 3, defaultCanonicalThresholds)
+	stats := scores["instructors"].ByVariant[variantLastNameOnly]
+	if stats.Probes != len(instructors) || stats.Top1 != stats.Probes {
+		t.Errorf("Expected every last name to resolve, got %+v", stats)
+	}
+	if _, exists := scores["subjects"]; !exists {
+		t.Errorf("Expected subject scores")
+	}
+}
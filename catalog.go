@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// CatalogEntry holds the catalog information shared by every section of a course
+type CatalogEntry struct {
+	Subject       string   `json:"subject"`
+	CourseNumber  string   `json:"courseNumber"`
+	Title         string   `json:"title"`
+	Description   string   `json:"description"`
+	Credits       string   `json:"credits"`
+	Prerequisites string   `json:"prerequisites"`
+	Corequisites  string   `json:"corequisites"`
+	Attributes    []string `json:"attributes"`
+}
+
+// the accepted CSV header names for each catalog field, compared case-insensitively
+var catalogCSVColumns = map[string][]string{
+	"subject":       {"subject", "subj"},
+	"courseNumber":  {"course number", "coursenumber", "crse num", "number"},
+	"title":         {"title", "course title"},
+	"description":   {"description", "course description"},
+	"credits":       {"credits", "units", "credit hours"},
+	"prerequisites": {"prerequisites", "prerequisite", "prereqs"},
+	"corequisites":  {"corequisites", "corequisite", "coreqs"},
+	"attributes":    {"attributes", "core", "designations"},
+}
+
+// the key that joins a catalog entry to the sections of its course, e.g. "CS 272"
+func catalogKey(subject, courseNumber string) string {
+	return strings.ToUpper(strings.TrimSpace(subject)) + " " + strings.ToUpper(strings.TrimSpace(courseNumber))
+}
+
+// reads catalog entries from a .csv or .json file, keyed by subject and course number
+func readCatalog(filePath string) (map[string]CatalogEntry, error) {
+	var (
+		entries []CatalogEntry
+		err     error
+	)
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".csv":
+		entries, err = readCatalogFromCSV(filePath)
+	case ".json":
+		entries, err = readCatalogFromJSON(filePath)
+	default:
+		return nil, fmt.Errorf("unsupported catalog format '%s', expected .csv or .json", filepath.Ext(filePath))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	catalog := make(map[string]CatalogEntry, len(entries))
+	for _, entry := range entries {
+		if strings.TrimSpace(entry.Subject) == "" || strings.TrimSpace(entry.CourseNumber) == "" {
+			continue
+		}
+		catalog[catalogKey(entry.Subject, entry.CourseNumber)] = entry
+	}
+	return catalog, nil
+}
+
+func readCatalogFromJSON(filePath string) ([]CatalogEntry, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog file: %v", err)
+	}
+
+	var entries []CatalogEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal catalog JSON: %v", err)
+	}
+	return entries, nil
+}
+
+func readCatalogFromCSV(filePath string) ([]CatalogEntry, error) {
+	file, err := os.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open catalog file: %v", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog CSV header: %v", err)
+	}
+
+	// find the column of every field we know about
+	columns := make(map[string]int)
+	for i, header := range headers {
+		header = strings.ToLower(strings.TrimSpace(header))
+		for field, names := range catalogCSVColumns {
+			for _, name := range names {
+				if header == name {
+					columns[field] = i
+				}
+			}
+		}
+	}
+	if _, ok := columns["subject"]; !ok {
+		return nil, fmt.Errorf("catalog CSV has no subject column")
+	}
+	if _, ok := columns["courseNumber"]; !ok {
+		return nil, fmt.Errorf("catalog CSV has no course number column")
+	}
+
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read catalog CSV records: %v", err)
+	}
+
+	var entries []CatalogEntry
+	for _, record := range records {
+		field := func(name string) string {
+			i, ok := columns[name]
+			if !ok || i >= len(record) {
+				return ""
+			}
+			return strings.TrimSpace(record[i])
+		}
+
+		var attributes []string
+		for _, attribute := range strings.Split(field("attributes"), ";") {
+			if attribute = strings.TrimSpace(attribute); attribute != "" {
+				attributes = append(attributes, attribute)
+			}
+		}
+
+		entries = append(entries, CatalogEntry{
+			Subject:       field("subject"),
+			CourseNumber:  field("courseNumber"),
+			Title:         field("title"),
+			Description:   field("description"),
+			Credits:       field("credits"),
+			Prerequisites: field("prerequisites"),
+			Corequisites:  field("corequisites"),
+			Attributes:    attributes,
+		})
+	}
+	return entries, nil
+}
+
+// copies the catalog information onto every section of the same course and returns how many matched
+func joinCatalog(courses []Course, catalog map[string]CatalogEntry) int {
+	matched := 0
+	for i := range courses {
+		entry, exists := catalog[catalogKey(courses[i].Subject, courses[i].CourseNumber)]
+		if !exists {
+			continue
+		}
+
+		courses[i].Description = entry.Description
+		courses[i].Credits = entry.Credits
+		courses[i].Prerequisites = entry.Prerequisites
+		courses[i].Corequisites = entry.Corequisites
+		courses[i].Attributes = strings.Join(entry.Attributes, "; ")
+		matched++
+	}
+	return matched
+}
@@ -0,0 +1,51 @@
+package main
+
+import "testing"
+
+func TestReadCatalog(t *testing.T) {
+	tests := []struct {
+		name       string
+		filePath   string
+		key        string
+		credits    string
+		attributes string
+	}{
+		{name: "CSV", filePath: "testdata/catalog.csv", key: "CS 360", credits: "4", attributes: "Core B2"},
+		{name: "JSON", filePath: "testdata/catalog.json", key: "CS 360", credits: "4", attributes: "Core B2; Writing"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			catalog, err := readCatalog(test.filePath)
+			if err != nil {
+				t.Fatalf("Error reading catalog: %v", err)
+			}
+			if _, exists := catalog[test.key]; !exists {
+				t.Fatalf("Expected catalog entry %s, got %v", test.key, catalog)
+			}
+
+			courses := []Course{
+				{CRN: "1", Subject: "CS", CourseNumber: "360", Section: "01"},
+				{CRN: "2", Subject: "CS", CourseNumber: "360", Section: "02"},
+				{CRN: "3", Subject: "MUS", CourseNumber: "100", Section: "01"},
+			}
+			if matched := joinCatalog(courses, catalog); matched != 2 {
+				t.Errorf("Expected 2 sections to match the catalog, got %d", matched)
+			}
+			for _, course := range courses[:2] {
+				if course.Credits != test.credits || course.Attributes != test.attributes || course.Description == "" {
+					t.Errorf("Catalog was not joined onto section %s: %+v", course.Section, course)
+				}
+			}
+			if courses[2].Description != "" {
+				t.Errorf("Unrelated course picked up a description: %+v", courses[2])
+			}
+		})
+	}
+}
+
+func TestReadCatalogUnsupportedFormat(t *testing.T) {
+	if _, err := readCatalog("catalog.xml"); err == nil {
+		t.Errorf("Expected an error for an unsupported catalog format")
+	}
+}
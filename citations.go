@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// a citation such as "[CRN 40646]" or "[CRN: 40646, 42344]"
+var citationPattern = regexp.MustCompile(`(?i)\[CRNs?:?\s*([\d,\s]+)\]`)
+
+// the start of a top-level list item, which begins a new chunk of the answer
+var listItemPattern = regexp.MustCompile(`^(\d+\.|[-*])\s`)
+
+// CitationReport describes how the CRNs cited in an answer line up with the courses retrieved for it
+type CitationReport struct {
+	// every cited CRN, in the order it first appears
+	Cited []string
+	// cited CRNs that no tool call returned during the turn
+	Unknown []string
+	// details next to a citation that don't match the cited course
+	Mismatches []string
+	// the answer mentions retrieved courses without citing any of them
+	Uncited bool
+}
+
+// checks the citations of an answer against the courses the tools returned during the turn
+func checkCitations(answer string, sources map[string]Course) CitationReport {
+	var report CitationReport
+	seen := make(map[string]bool)
+
+	for _, chunk := range splitAnswer(answer) {
+		cited := citedCRNs(chunk)
+		var records []Course
+		for _, crn := range cited {
+			if !seen[crn] {
+				seen[crn] = true
+				report.Cited = append(report.Cited, crn)
+			}
+			if course, exists := sources[crn]; exists {
+				records = append(records, course)
+			} else if !contains(report.Unknown, crn) {
+				report.Unknown = append(report.Unknown, crn)
+			}
+		}
+		if len(records) == 0 {
+			continue
+		}
+
+		// only the time, room and instructor written next to a citation are checked here; the
+		// verifier checks every other detail
+		var claims []answerClaim
+		for _, claim := range extractClaims(citationPattern.ReplaceAllString(chunk, ""), nil) {
+			if claim.Field == claimTime || claim.Field == claimRoom || claim.Field == claimInstructor {
+				claims = append(claims, claim)
+			}
+		}
+		for _, mismatch := range compareClaims(claims, records) {
+			report.Mismatches = append(report.Mismatches, mismatch.String())
+		}
+	}
+
+	report.Uncited = len(report.Cited) == 0 && len(sources) > 0 && mentionsAnySource(answer, sources)
+	return report
+}
+
+// splits an answer into paragraphs and top-level list items, so that a citation applies to the
+// details written next to it
+func splitAnswer(answer string) []string {
+	var (
+		chunks  []string
+		current []string
+	)
+	flush := func() {
+		if len(current) > 0 {
+			chunks = append(chunks, strings.Join(current, "\n"))
+			current = nil
+		}
+	}
+	for _, line := range strings.Split(answer, "\n") {
+		trimmed := strings.TrimSpace(line)
+		switch {
+		case trimmed == "":
+			flush()
+			continue
+		case listItemPattern.MatchString(line):
+			flush()
+		}
+		current = append(current, line)
+	}
+	flush()
+	return chunks
+}
+
+// the CRNs cited in a piece of text
+func citedCRNs(text string) []string {
+	var crns []string
+	for _, match := range citationPattern.FindAllStringSubmatch(text, -1) {
+		for _, crn := range strings.FieldsFunc(match[1], func(r rune) bool { return r == ',' || r == ' ' || r == '\n' || r == '\t' }) {
+			if !contains(crns, crn) {
+				crns = append(crns, crn)
+			}
+		}
+	}
+	return crns
+}
+
+// whether the answer names the title of any retrieved course
+func mentionsAnySource(answer string, sources map[string]Course) bool {
+	lower := strings.ToLower(answer)
+	for _, course := range sources {
+		if title := strings.ToLower(strings.TrimSpace(course.Title)); title != "" && strings.Contains(lower, title) {
+			return true
+		}
+	}
+	return false
+}
+
+// renders the sources footer shown under an answer
+func formatSourcesFooter(report CitationReport, sources map[string]Course) string {
+	var b strings.Builder
+	if len(report.Cited) > 0 {
+		b.WriteString("Sources:\n")
+		for _, crn := range report.Cited {
+			course, exists := sources[crn]
+			if !exists {
+				continue
+			}
+			fmt.Fprintf(&b, "  [CRN %s] %s %s-%s %s", crn, course.Subject, course.CourseNumber, course.Section, course.Title)
+			if name := strings.TrimSpace(course.InstructorFirstName + " " + course.InstructorLastName); name != "" {
+				fmt.Fprintf(&b, " (%s)", name)
+			}
+			b.WriteString("\n")
+		}
+	}
+	for _, crn := range report.Unknown {
+		fmt.Fprintf(&b, "  ⚠ CRN %s was cited but was not returned by any search\n", crn)
+	}
+	for _, mismatch := range report.Mismatches {
+		fmt.Fprintf(&b, "  ⚠ %s\n", mismatch)
+	}
+	if report.Uncited {
+		b.WriteString("  ⚠ this answer mentions courses without citing their CRNs\n")
+	}
+	return b.String()
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
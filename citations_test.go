@@ -0,0 +1,88 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestCheckCitations(t *testing.T) {
+	sources := map[string]Course{
+		"40646": {CRN: "40646", Subject: "CS", CourseNumber: "272", Section: "01", Title: "Software Development", BeginTime: "1440", EndTime: "1625", Building: "LS", Room: "G12", InstructorFirstName: "Philip", InstructorLastName: "Peterson"},
+		"42344": {CRN: "42344", Subject: "CS", CourseNumber: "272L", Section: "01", Title: "Software Development Lab", BeginTime: "1455", EndTime: "1625", Building: "MH", Room: "122", InstructorFirstName: "Philip", InstructorLastName: "Peterson"},
+	}
+
+	tests := []struct {
+		name       string
+		answer     string
+		cited      []string
+		unknown    []string
+		mismatches int
+		uncited    bool
+	}{
+		{
+			name:   "matching details",
+			answer: "1. **Software Development** [CRN 40646]\n   - Time: 2:40 PM - 4:25 PM\n   - Room: G12\n   - Instructor: Phil Peterson",
+			cited:  []string{"40646"},
+		},
+		{
+			name:    "unknown CRN",
+			answer:  "You could take Bioinformatics [CRN 99999].",
+			cited:   []string{"99999"},
+			unknown: []string{"99999"},
+		},
+		{
+			name:       "wrong room and time",
+			answer:     "Software Development [CRN 40646] meets at 9:00 AM in room 311.",
+			cited:      []string{"40646"},
+			mismatches: 2,
+		},
+		{
+			name:       "wrong instructor",
+			answer:     "Software Development Lab [CRN 42344] is taught by Greg Benson.",
+			cited:      []string{"42344"},
+			mismatches: 1,
+		},
+		{
+			name:   "details belong to the item they are written in",
+			answer: "1. Lab [CRN 42344], room 122\n2. Lecture [CRN 40646], room G12",
+			cited:  []string{"42344", "40646"},
+		},
+		{
+			name:    "no citations",
+			answer:  "Phil teaches Software Development on Tuesdays.",
+			uncited: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			report := checkCitations(test.answer, sources)
+			if !reflect.DeepEqual(report.Cited, test.cited) {
+				t.Errorf("Cited = %v, expected %v", report.Cited, test.cited)
+			}
+			if !reflect.DeepEqual(report.Unknown, test.unknown) {
+				t.Errorf("Unknown = %v, expected %v", report.Unknown, test.unknown)
+			}
+			if len(report.Mismatches) != test.mismatches {
+				t.Errorf("Mismatches = %v, expected %d", report.Mismatches, test.mismatches)
+			}
+			if report.Uncited != test.uncited {
+				t.Errorf("Uncited = %v, expected %v", report.Uncited, test.uncited)
+			}
+		})
+	}
+}
+
+func TestFormatSourcesFooter(t *testing.T) {
+	sources := map[string]Course{
+		"40646": {CRN: "40646", Subject: "CS", CourseNumber: "272", Section: "01", Title: "Software Development", InstructorFirstName: "Philip", InstructorLastName: "Peterson"},
+	}
+	footer := formatSourcesFooter(CitationReport{Cited: []string{"40646", "12345"}, Unknown: []string{"12345"}}, sources)
+
+	for _, expected := range []string{"Sources:", "[CRN 40646] CS 272-01 Software Development (Philip Peterson)", "CRN 12345 was cited but was not returned"} {
+		if !strings.Contains(footer, expected) {
+			t.Errorf("Footer is missing %q:\n%s", expected, footer)
+		}
+	}
+}
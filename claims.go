@@ -0,0 +1,303 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// the kinds of course details an answer can claim
+const (
+	claimCourse     = "course"
+	claimSection    = "section"
+	claimDays       = "days"
+	claimTime       = "time"
+	claimBuilding   = "building"
+	claimRoom       = "room"
+	claimInstructor = "instructor"
+)
+
+// a course code such as "CS 272" or "CS272L"; only codes whose subject was retrieved are claims
+var courseClaimPattern = regexp.MustCompile(`\b([A-Z]{2,5})\s?(\d{3}[A-Z]?)\b`)
+
+// a section such as "Section 01" or "SEC: 06"
+var sectionPattern = regexp.MustCompile(`(?i)\b(?:section|sec)\b\.?\**:?\**\s*(\d{1,2}[A-Z]?)\b`)
+
+// meeting days written as letters, such as "Meet Days: MWF" or "Days: TR"
+var dayLettersPattern = regexp.MustCompile(`(?i:\b(?:meet(?:ing)?\s+)?days)\**:?\**\s*([MTWRFSU]{1,7})\b`)
+
+// meeting days written as a run of weekday names, such as "Mondays and Wednesdays"
+var dayNamesPattern = regexp.MustCompile(`(?i)\b(?:mon|tues|wednes|thurs|fri|satur|sun)days?\b(?:\s*(?:,|and|&|/)\s*(?:mon|tues|wednes|thurs|fri|satur|sun)days?\b)*`)
+
+// a single weekday name inside dayNamesPattern
+var dayNamePattern = regexp.MustCompile(`(?i)(mon|tues|wednes|thurs|fri|satur|sun)days?`)
+
+// a time such as "2:40 PM", "14:40" or "9 AM"
+var timePattern = regexp.MustCompile(`(?i)\b(\d{1,2})(?::(\d{2}))?\s*([AP]\.?M\.?)|\b(\d{1,2}):(\d{2})\b`)
+
+// a building such as "Building: KA" or "bldg LS"
+var buildingPattern = regexp.MustCompile(`\b(?:[Bb]uilding|BLDG|[Bb]ldg)\b\.?\**:?\**\s*([A-Z]{2,4})\b`)
+
+// a room such as "Room: G12", "room 311" or "Room: KA 263", which also claims the building
+var roomPattern = regexp.MustCompile(`\b(?:[Rr]oom|RM|[Rr]m)\b\.?\**:?\**\s*(?:([A-Z]{2,4})\s+)?([A-Z]?\d+[A-Z]?)\b`)
+
+// an instructor such as "Instructor: Philip Peterson" or "taught by Greg Benson"
+var instructorPattern = regexp.MustCompile(`(?:[Ii]nstructor|[Tt]aught by|[Pp]rofessor|[Pp]rof\.)\**:?\**\s*([A-Z][\p{L}'-]+(?:\s+[A-Z][\p{L}'-]+)+)`)
+
+// the letter used for each weekday in the schedule's Meet Days column
+var dayLetters = map[string]string{
+	"mon": "M", "tues": "T", "wednes": "W", "thurs": "R", "fri": "F", "satur": "S", "sun": "U",
+}
+
+// answerClaim is a single course detail stated in an answer
+type answerClaim struct {
+	Field string
+	// the claimed value in the form it is compared in, e.g. "2:40 PM" or "MWF"
+	Value string
+	// the text the value was read from, and the whole phrase around it, so it can be corrected
+	Raw    string
+	Phrase string
+	// the position of this claim among the claims of the same field in its chunk
+	Ordinal int
+}
+
+// claimMismatch is a claim that doesn't match the course records it refers to
+type claimMismatch struct {
+	CRNs    []string `json:"crns"`
+	Field   string   `json:"field"`
+	Claimed string   `json:"claimed"`
+	Actual  string   `json:"actual"`
+
+	claim answerClaim
+	// where the chunk the claim was read from starts in the answer
+	chunkOffset int
+}
+
+func (m claimMismatch) String() string {
+	return fmt.Sprintf("[CRN %s] says %s %s, but the record says %s", strings.Join(m.CRNs, ", "), m.Field, m.Claimed, m.Actual)
+}
+
+// pulls every course detail out of a piece of an answer. Course codes are only read for the
+// subjects in knownSubjects, so that a building and room such as "LS 307" isn't taken for a course.
+func extractClaims(text string, knownSubjects map[string]bool) []answerClaim {
+	var claims []answerClaim
+	add := func(field, value, raw, phrase string) {
+		ordinal := 0
+		for _, claim := range claims {
+			if claim.Field == field {
+				ordinal++
+			}
+		}
+		claims = append(claims, answerClaim{Field: field, Value: value, Raw: raw, Phrase: phrase, Ordinal: ordinal})
+	}
+
+	for _, match := range courseClaimPattern.FindAllStringSubmatch(text, -1) {
+		if knownSubjects[match[1]] {
+			add(claimCourse, match[1]+" "+match[2], match[0], match[0])
+		}
+	}
+	for _, match := range sectionPattern.FindAllStringSubmatch(text, -1) {
+		add(claimSection, normalizeSection(match[1]), match[1], match[0])
+	}
+	for _, match := range dayLettersPattern.FindAllStringSubmatch(text, -1) {
+		add(claimDays, strings.ToUpper(match[1]), match[1], match[0])
+	}
+	for _, match := range dayNamesPattern.FindAllString(text, -1) {
+		var letters strings.Builder
+		for _, name := range dayNamePattern.FindAllStringSubmatch(match, -1) {
+			letters.WriteString(dayLetters[strings.ToLower(name[1])])
+		}
+		add(claimDays, letters.String(), match, match)
+	}
+	for _, match := range timePattern.FindAllStringSubmatch(text, -1) {
+		if minutes, ok := parseAnswerTime(match); ok {
+			add(claimTime, formatMinutes(minutes), match[0], match[0])
+		}
+	}
+	for _, match := range buildingPattern.FindAllStringSubmatch(text, -1) {
+		add(claimBuilding, match[1], match[1], match[0])
+	}
+	for _, match := range roomPattern.FindAllStringSubmatch(text, -1) {
+		if match[1] != "" {
+			add(claimBuilding, match[1], match[1], match[0])
+		}
+		add(claimRoom, strings.ToUpper(match[2]), match[2], match[0])
+	}
+	for _, match := range instructorPattern.FindAllStringSubmatch(text, -1) {
+		add(claimInstructor, match[1], match[1], match[0])
+	}
+	return claims
+}
+
+// checks every claim against the records it refers to; a claim holds if any of the records agrees
+func compareClaims(claims []answerClaim, records []Course) []claimMismatch {
+	var crns []string
+	for _, record := range records {
+		crns = append(crns, record.CRN)
+	}
+
+	var mismatches []claimMismatch
+	for _, claim := range claims {
+		if anyRecord(records, func(c Course) bool { return claimHolds(claim, c) }) {
+			continue
+		}
+		var actual []string
+		for _, record := range records {
+			actual = append(actual, actualValue(claim, record))
+		}
+		mismatches = append(mismatches, claimMismatch{
+			CRNs:    crns,
+			Field:   claim.Field,
+			Claimed: claim.Value,
+			Actual:  strings.Join(actual, " / "),
+			claim:   claim,
+		})
+	}
+	return mismatches
+}
+
+// whether a single record agrees with a claim
+func claimHolds(claim answerClaim, course Course) bool {
+	switch claim.Field {
+	case claimCourse:
+		return claim.Value == catalogKey(course.Subject, course.CourseNumber)
+	case claimSection:
+		return claim.Value == normalizeSection(course.Section)
+	case claimDays:
+		return strings.EqualFold(claim.Value, strings.TrimSpace(course.MeetDays))
+	case claimTime:
+		return course.BeginTime != "" && (claim.Value == formatMinutes(clockMinutes(course.BeginTime)) || claim.Value == formatMinutes(clockMinutes(course.EndTime)))
+	case claimBuilding:
+		return strings.EqualFold(claim.Value, strings.TrimSpace(course.Building))
+	case claimRoom:
+		return strings.EqualFold(claim.Value, strings.TrimSpace(course.Room))
+	case claimInstructor:
+		return instructorMatches(course, claim.Value)
+	}
+	return true
+}
+
+// the value a record has for the field of a claim, as it would be written in an answer. Times are
+// matched by position: the first time in a chunk is the begin time, the second the end time.
+func actualValue(claim answerClaim, course Course) string {
+	switch claim.Field {
+	case claimCourse:
+		return catalogKey(course.Subject, course.CourseNumber)
+	case claimSection:
+		return course.Section
+	case claimDays:
+		return orUnscheduled(course.MeetDays, course)
+	case claimTime:
+		if course.BeginTime == "" {
+			if course.MeetingStatus != "" {
+				return course.unscheduledPhrase()
+			}
+			return "no scheduled time"
+		}
+		if claim.Ordinal%2 == 1 {
+			return formatMinutes(clockMinutes(course.EndTime))
+		}
+		return formatMinutes(clockMinutes(course.BeginTime))
+	case claimBuilding:
+		return orUnscheduled(course.Building, course)
+	case claimRoom:
+		return orUnscheduled(course.Room, course)
+	case claimInstructor:
+		return course.instructorPhrase()
+	}
+	return ""
+}
+
+// a meeting detail, or why the schedule leaves it blank
+func orUnscheduled(value string, course Course) string {
+	if strings.TrimSpace(value) == "" {
+		return course.unscheduledPhrase()
+	}
+	return strings.TrimSpace(value)
+}
+
+// turns "1" and "01" into "01"
+func normalizeSection(section string) string {
+	section = strings.ToUpper(strings.TrimSpace(section))
+	if len(section) == 1 || (len(section) == 2 && section[1] >= 'A' && section[1] <= 'Z') {
+		section = "0" + section
+	}
+	return section
+}
+
+// turns a match of timePattern into minutes past midnight
+func parseAnswerTime(match []string) (int, bool) {
+	var hour, minute int
+	if match[1] != "" {
+		fmt.Sscanf(match[1], "%d", &hour)
+		if match[2] != "" {
+			fmt.Sscanf(match[2], "%d", &minute)
+		}
+		meridiem := strings.ToUpper(strings.ReplaceAll(match[3], ".", ""))
+		if hour < 1 || hour > 12 {
+			return 0, false
+		}
+		if hour == 12 {
+			hour = 0
+		}
+		if meridiem == "PM" {
+			hour += 12
+		}
+	} else {
+		fmt.Sscanf(match[4], "%d", &hour)
+		fmt.Sscanf(match[5], "%d", &minute)
+		if hour > 23 {
+			return 0, false
+		}
+	}
+	if minute > 59 {
+		return 0, false
+	}
+	return hour*60 + minute, true
+}
+
+// whether a name written in the answer refers to the course's instructor; a matching last name is
+// enough, since "Phil Peterson" and "Philip Peterson" are the same person
+func instructorMatches(course Course, name string) bool {
+	last := strings.ToLower(strings.TrimSpace(course.InstructorLastName))
+	if last == "" {
+		return false
+	}
+	for _, word := range strings.Fields(strings.ToLower(name)) {
+		if word == last {
+			return true
+		}
+	}
+	return false
+}
+
+// renders minutes past midnight as "2:40 PM"
+func formatMinutes(minutes int) string {
+	hour, minute := minutes/60, minutes%60
+	meridiem := "AM"
+	if hour >= 12 {
+		meridiem = "PM"
+	}
+	if hour%12 == 0 {
+		return fmt.Sprintf("12:%02d %s", minute, meridiem)
+	}
+	return fmt.Sprintf("%d:%02d %s", hour%12, minute, meridiem)
+}
+
+func anyRecord(records []Course, match func(Course) bool) bool {
+	for _, record := range records {
+		if match(record) {
+			return true
+		}
+	}
+	return false
+}
+
+// the subjects of a set of courses
+func subjectsOf(sources map[string]Course) map[string]bool {
+	subjects := make(map[string]bool)
+	for _, course := range sources {
+		subjects[strings.ToUpper(strings.TrimSpace(course.Subject))] = true
+	}
+	return subjects
+}
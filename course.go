@@ -2,7 +2,9 @@ package main
 
 import (
 	"encoding/csv"
+	"errors"
 	"fmt"
+	"io"
 	"os"
 )
 
@@ -28,6 +30,17 @@ type Course struct {
     InstructorLastName      string `json:"Primary Instructor Last Name"`      
     InstructorEmail         string `json:"Primary Instructor Email"`
     College                 string `json:"College"`                           
+
+    // filled in from the course catalog when one is imported
+    Description             string `json:"Description,omitempty"`
+    Credits                 string `json:"Credits,omitempty"`
+    Prerequisites           string `json:"Prerequisites,omitempty"`
+    Corequisites            string `json:"Corequisites,omitempty"`
+    Attributes              string `json:"Attributes,omitempty"`
+
+    // what the schedule's blank fields mean, see setStates
+    InstructorStatus        string `json:"Instructor Status,omitempty"`
+    MeetingStatus           string `json:"Meeting Status,omitempty"`
 }
 
 /*
@@ -36,9 +49,15 @@ if err := gocsv.Unmarshal(reader, &courses)
 */
 
 func readCoursesFromCSV(filePath string) ([]Course, error) {
+    courses, _, err := readCourseRows(filePath)
+    return courses, err
+}
+
+// reads the courses of the schedule CSV along with the rows that were skipped because they couldn't be read
+func readCourseRows(filePath string) ([]Course, []RowError, error) {
     file, err := os.Open(filePath)
     if err != nil {
-        return nil, fmt.Errorf("failed to open CSV file: %v", err)
+        return nil, nil, fmt.Errorf("failed to open CSV file: %w", err)
     }
     defer file.Close()
 
@@ -48,18 +67,32 @@ func readCoursesFromCSV(filePath string) ([]Course, error) {
     // Read the header line
     headers, err := reader.Read()
     if err != nil {
-        return nil, fmt.Errorf("failed to read CSV header: %v", err)
-    }
-
-    records, err := reader.ReadAll()
-    if err != nil {
-        return nil, fmt.Errorf("failed to read CSV records: %v", err)
+        return nil, nil, fmt.Errorf("failed to read CSV header: %w", err)
     }
 
     var courses []Course
-    for _, record := range records {
+    var skipped []RowError
+    // the row each CRN was first read from, since every section is stored under its CRN
+    crnLines := make(map[string]int)
+    for {
+        record, err := reader.Read()
+        if err == io.EOF {
+            break
+        }
+        // a row with broken quoting is skipped, the reader carries on with the next one
+        var parseErr *csv.ParseError
+        if errors.As(err, &parseErr) {
+            skipped = append(skipped, RowError{Line: parseErr.StartLine, Reason: parseErr.Err.Error()})
+            continue
+        }
+        if err != nil {
+            return nil, nil, fmt.Errorf("failed to read CSV records: %w", err)
+        }
+
 		// err check in case of improperly formatted CSVs
         if len(record) < len(headers) {
+            line, _ := reader.FieldPos(0)
+            skipped = append(skipped, RowError{Line: line, Reason: fmt.Sprintf("has %d fields, expected %d", len(record), len(headers))})
             continue 
         }
 
@@ -86,8 +119,17 @@ func readCoursesFromCSV(filePath string) ([]Course, error) {
 			InstructorEmail:     record[19],
 			College:             record[20],
 		}
+        course.setStates()
+
+        // a section meeting at several times has a row per meeting; only the first is kept
+        line, _ := reader.FieldPos(0)
+        if first, seen := crnLines[course.CRN]; seen {
+            skipped = append(skipped, RowError{Line: line, Reason: fmt.Sprintf("CRN %s is also on row %d, only the meeting on that row is ingested", course.CRN, first)})
+            continue
+        }
+        crnLines[course.CRN] = line
         courses = append(courses, course)
     }
 
-    return courses, nil
+    return courses, skipped, nil
 }
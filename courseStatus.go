@@ -0,0 +1,78 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// what a section is marked as when the schedule leaves its instructor, or its times and place, blank
+const (
+	// no instructor has been assigned yet
+	instructorStaff = "Staff/TBA"
+	// an online section with no scheduled meetings
+	meetingOnline = "Online/Asynchronous"
+	// an online section that meets at set times, with no room
+	meetingOnlineSynchronous = "Online/Synchronous"
+	// the times or place of an in-person section are still to be announced
+	meetingTBA = "TBA"
+)
+
+// fills in InstructorStatus and MeetingStatus from the fields the schedule left blank
+func (c *Course) setStates() {
+	c.InstructorStatus = ""
+	if strings.TrimSpace(c.InstructorFirstName+c.InstructorLastName) == "" {
+		c.InstructorStatus = instructorStaff
+	}
+
+	c.MeetingStatus = ""
+	beginTime, building := strings.TrimSpace(c.BeginTime), strings.TrimSpace(c.Building)
+	// online meetings have no room, so the schedule lists their building as ONL or leaves it blank
+	remote := strings.HasPrefix(c.InstructionMode, "Online") || c.MeetingTypeCodes == "RE" || c.MeetingTypeCodes == "OL"
+	online := building == "ONL" || (building == "" && remote)
+	// synchronous online sections meet at set times, so without them they are still to be announced
+	asynchronous := c.InstructionMode == "Online Asynchronous" || ((building == "ONL" || c.MeetingTypeCodes == "OL") && c.InstructionMode != "Online Synchronous")
+	switch {
+	case beginTime == "" && asynchronous:
+		c.MeetingStatus = meetingOnline
+	case beginTime != "" && online:
+		c.MeetingStatus = meetingOnlineSynchronous
+	case beginTime == "" || building == "":
+		c.MeetingStatus = meetingTBA
+	}
+}
+
+// the instructor's full name, or "" when none has been assigned rather than " "
+func (c Course) instructorFullName() string {
+	return strings.TrimSpace(c.InstructorFirstName + " " + c.InstructorLastName)
+}
+
+// how an answer should describe the instructor
+func (c Course) instructorPhrase() string {
+	if name := c.instructorFullName(); name != "" {
+		return name
+	}
+	return "not yet assigned"
+}
+
+// how an answer should describe a meeting detail the schedule leaves blank
+func (c Course) unscheduledPhrase() string {
+	switch c.MeetingStatus {
+	case meetingOnline:
+		return "none, the section is online and asynchronous"
+	case meetingOnlineSynchronous:
+		return "none, the section meets online"
+	case meetingTBA:
+		return "to be announced"
+	}
+	return "none"
+}
+
+// reads a course document, marking the states of documents written before they were recorded
+func decodeCourse(document string) (Course, error) {
+	var course Course
+	if err := json.Unmarshal([]byte(document), &course); err != nil {
+		return Course{}, err
+	}
+	course.setStates()
+	return course, nil
+}
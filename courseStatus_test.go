@@ -0,0 +1,85 @@
+package main
+
+import "testing"
+
+func TestSetStates(t *testing.T) {
+	tests := []struct {
+		name       string
+		course     Course
+		instructor string
+		meeting    string
+	}{
+		{
+			name:   "scheduled and assigned",
+			course: Course{InstructionMode: "In-Person", MeetingTypeCodes: "IP", BeginTime: "1440", Building: "LS", InstructorFirstName: "Greg", InstructorLastName: "Benson"},
+		},
+		{
+			name:       "no instructor",
+			course:     Course{InstructionMode: "In-Person", MeetingTypeCodes: "IP", BeginTime: "1440", Building: "LS", InstructorFirstName: " ", InstructorLastName: ""},
+			instructor: instructorStaff,
+		},
+		{
+			name:    "online and asynchronous",
+			course:  Course{InstructionMode: "Online Asynchronous", MeetingTypeCodes: "OL", Building: "ONL", InstructorFirstName: "Greg", InstructorLastName: "Benson"},
+			meeting: meetingOnline,
+		},
+		{
+			name:    "online building without a mode",
+			course:  Course{MeetingTypeCodes: "OL", Building: "ONL", InstructorFirstName: "Greg", InstructorLastName: "Benson"},
+			meeting: meetingOnline,
+		},
+		{
+			name:    "online synchronous without times",
+			course:  Course{InstructionMode: "Online Synchronous", MeetingTypeCodes: "OL", Building: "ONL", InstructorFirstName: "Greg", InstructorLastName: "Benson"},
+			meeting: meetingTBA,
+		},
+		{
+			// BAM 300-01 (CRN 40252) on the Fall 2024 schedule
+			name:    "online synchronous with times and no building",
+			course:  Course{InstructionMode: "Online Synchronous", MeetingTypeCodes: "RE", MeetDays: "W", BeginTime: "1800", EndTime: "2200", Building: "", Room: "", InstructorFirstName: "Mary", InstructorLastName: "Garlick"},
+			meeting: meetingOnlineSynchronous,
+		},
+		{
+			name:    "remote meeting in the ONL building",
+			course:  Course{InstructionMode: "Hybrid", MeetingTypeCodes: "RE", MeetDays: "R", BeginTime: "1150", EndTime: "1240", Building: "ONL", Room: "ONL", InstructorFirstName: "Greg", InstructorLastName: "Benson"},
+			meeting: meetingOnlineSynchronous,
+		},
+		{
+			name:       "in person without a room or instructor",
+			course:     Course{InstructionMode: "In-Person", MeetingTypeCodes: "IP", MeetDays: "MW", BeginTime: "1000"},
+			instructor: instructorStaff,
+			meeting:    meetingTBA,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			course := test.course
+			course.setStates()
+			if course.InstructorStatus != test.instructor || course.MeetingStatus != test.meeting {
+				t.Errorf("States = %q, %q, expected %q, %q", course.InstructorStatus, course.MeetingStatus, test.instructor, test.meeting)
+			}
+		})
+	}
+}
+
+func TestUnassignedInstructorsAreNotIndexed(t *testing.T) {
+	courses := []Course{
+		{CRN: "41000", Title: "Senior Team Project"},
+		{CRN: "40649", Title: "Computer Architecture", InstructorFirstName: "Greg", InstructorLastName: "Benson"},
+	}
+	for i := range courses {
+		courses[i].setStates()
+	}
+
+	docs, err := buildCollectionDocuments(courses, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(docs.instructors.documents) != 1 || docs.instructors.documents[0] != "Greg Benson" {
+		t.Errorf("Expected only Greg Benson to be indexed, got %q", docs.instructors.documents)
+	}
+	if metadata := docs.courses.metadatas[0]; metadata["InstructorFullName"] != "" || metadata["InstructorStatus"] != instructorStaff {
+		t.Errorf("Expected the unassigned section to be marked Staff/TBA, got %v", metadata)
+	}
+}
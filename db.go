@@ -3,319 +3,729 @@ package main
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
-	"strings"
+	"path/filepath"
+	"sort"
+	"sync"
 
-	chroma "github.com/amikos-tech/chroma-go"
 	"github.com/amikos-tech/chroma-go/pkg/embeddings/openai"
 	"github.com/amikos-tech/chroma-go/types"
+	goopenai "github.com/sashabaranov/go-openai"
 )
 
 type Db struct {
 	ctx                       context.Context
-	client                    *chroma.Client
-	coursesCollection         *chroma.Collection
+	store                     VectorStore
+	httpClient                *http.Client
+	coursesCollection         VectorCollection
 	coursesCollectionName     string
-	instructorsCollection     *chroma.Collection
+	instructorsCollection     VectorCollection
 	instructorsCollectionName string
-	subjectsCollection        *chroma.Collection
+	subjectsCollection        VectorCollection
 	subjectsCollectionName    string
+	courseCodesCollection     VectorCollection
+	courseCodesCollectionName string
+	lexicalIndex              *LexicalIndex
+	prereqGraph               *PrereqGraph
+	// the directory of the store's lexical index and prerequisite graph; "" for the working directory
+	stateDir string
+	// the department names of the subject codes, keyed by code; nil when there are none
+	departments map[string]Department
+	// the office hours of the instructors, keyed by their lowercase email; nil when there are none
+	officeHours map[string]OfficeHours
+	// skip batches that fail to be written during ingestion instead of stopping at the first one
+	partialIngest bool
+	// the cache in front of the embedding function, nil when there is none
+	embeddingCache *embeddingCache
+	// how ingestion writes its batches
+	ingest ingestSettings
+}
+
+// ingestSettings is how the batches of an ingestion are written
+type ingestSettings struct {
+	// the batches written at the same time; 0 writes them one after another
+	workers int
+	// keeps the documents sent to be embedded under a budget of tokens a minute; nil for no limit
+	limiter *tokenLimiter
+	// where each collection's progress is drawn; nil to draw nothing
+	progress io.Writer
+	// the batches already written, which are skipped, and where the written ones are recorded; nil for none
+	checkpoint *ingestCheckpoint
+}
+
+// the collections the schedule is ingested into
+const (
+	defaultCoursesCollectionName     = "usf-courses"
+	defaultInstructorsCollectionName = "instructors"
+	defaultSubjectsCollectionName    = "subjects"
+	defaultCourseCodesCollectionName = "course-codes"
+)
+
+// the course schedule that gets parsed into the database
+const scheduleCSVPath = "Fall 2024 Class Schedule 08082024.csv"
+
+// StartOptions controls what Start does besides connecting to the database
+type StartOptions struct {
+	// wipe the collections and re-ingest the schedule CSV
+	Delete bool
+	// course catalog joined onto the sections; without Delete it is imported into the existing collection
+	CatalogPath string
+	// structured prerequisite file that overrides the prerequisites parsed from the catalog text
+	PrereqPath string
+	// office hours of the instructors, returned by get_instructor
+	OfficeHoursPath string
+	// department names of the subject codes, written to the subjects collection on ingestion
+	DepartmentsPath string
+	// client for every request to Chroma and OpenAI, e.g. one that records or replays them; nil for the default
+	HTTPClient *http.Client
+	// keep ingesting when a batch fails to be written and report the failed batches at the end
+	PartialIngest bool
+	// the vector store backend, chroma (the default), local or postgres
+	Store string
+	// the directory of the local store
+	StorePath string
+	// the connection string of the postgres store
+	PostgresDSN string
+	// the file embeddings are cached in, or "" to always embed with OpenAI
+	EmbeddingCachePath string
+	// the most the cached vectors take up before the least recently used are evicted; 0 for no cap
+	EmbeddingCacheMaxBytes int64
+	// the batches of records written at the same time during ingestion
+	IngestWorkers int
+	// the most tokens a minute sent to be embedded during ingestion; 0 for no limit
+	TokensPerMinute int
+	// where ingestion progress is drawn; nil to draw nothing
+	Progress io.Writer
+	// carry on an ingestion with Delete that stopped part way from its checkpoint instead of starting over
+	Resume bool
+	// refuse to ingest a schedule with data-quality issues instead of reporting them and carrying on
+	Strict bool
 }
 
 // handles the start process of the chromaDB db, getting/creating collections, and parsing data into the database
-func Start(deleteFlag bool) (*Db, error) {
-	db, err := initializeDB()
+func Start(opts StartOptions) (*Db, error) {
+	db, err := initializeDB(opts)
 	if err != nil {
-		log.Fatalf("Error creating database: %v\n", err)
-		return nil, err
+		return nil, fmt.Errorf("error creating database: %w", err)
+	}
+	db.partialIngest = opts.PartialIngest
+	db.ingest = ingestSettings{workers: opts.IngestWorkers, limiter: newTokenLimiter(opts.TokensPerMinute), progress: opts.Progress}
+	if opts.DepartmentsPath != "" {
+		if db.departments, err = loadDepartments(opts.DepartmentsPath); err != nil {
+			return nil, fmt.Errorf("error loading departments: %w", err)
+		}
 	}
 
-	if deleteFlag {
-		err := db.deleteCollections()
+	// the schedule is checked before anything is deleted, so a strict ingestion that refuses it
+	// leaves the collections as they were
+	if opts.Delete || opts.CatalogPath != "" {
+		validation, err := validateSchedule(scheduleCSVPath)
 		if err != nil {
-			log.Fatalf("Error deleting collections: %v", err)
-			return nil, err
+			return nil, fmt.Errorf("error validating the schedule: %w", err)
+		}
+		if len(validation.Issues) > 0 {
+			fmt.Print(validation.Summary())
+			if opts.Strict {
+				return nil, &ValidationError{Path: validation.Path, Issues: len(validation.Issues)}
+			}
 		}
-		log.Printf("Successfully deleted collections")
+	}
 
-		err = db.createCollections()
+	if opts.Delete {
+		location, err := storeLocation(opts.Store, opts.StorePath, opts.PostgresDSN)
+		if err != nil {
+			return nil, err
+		}
+		fingerprint, err := ingestFingerprint(location, scheduleCSVPath, opts.CatalogPath, opts.DepartmentsPath)
+		if err != nil {
+			return nil, fmt.Errorf("error reading the schedule: %w", err)
+		}
+		ingestCheckpointPath := filepath.Join(db.stateDir, ingestCheckpointFile)
+		checkpoint, resumable, err := openIngestCheckpoint(ingestCheckpointPath, fingerprint)
 		if err != nil {
-			return nil, fmt.Errorf("error creating collections: %w", err)
+			return nil, fmt.Errorf("error reading checkpoint: %w", err)
 		}
-		log.Printf("Successfully created collections")
+		db.ingest.checkpoint = checkpoint
 
-		err = db.parseCSVIntoDatabase("Fall 2024 Class Schedule 08082024.csv")
+		// the batches already written can only be skipped while their collections are still there
+		if opts.Resume && resumable && db.coursesCollection != nil && db.instructorsCollection != nil && db.subjectsCollection != nil && db.courseCodesCollection != nil {
+			log.Printf("Resuming the ingestion from %s, skipping the %d batches already written", ingestCheckpointPath, checkpoint.count())
+		} else {
+			checkpoint.Written = map[string][]int{}
+			err := db.deleteCollections()
+			if err != nil {
+				return nil, fmt.Errorf("error deleting collections: %w", err)
+			}
+			log.Printf("Successfully deleted collections")
+
+			err = db.createCollections()
+			if err != nil {
+				return nil, fmt.Errorf("error creating collections: %w", err)
+			}
+			log.Printf("Successfully created collections")
+		}
+
+		report, err := db.parseCSVIntoDatabase(scheduleCSVPath, opts.CatalogPath)
+		fmt.Print(report)
 		if err != nil {
-			log.Fatalf("Error parsing CSV and/or inserting into database: %v\n", err)
-			return nil, err
+			return nil, fmt.Errorf("error parsing CSV and/or inserting into database: %w", err)
+		}
+		// batches skipped by a partial ingestion are written by the next one, which resumes from here
+		if len(report.FailedBatches) == 0 {
+			if err := checkpoint.remove(); err != nil {
+				return nil, fmt.Errorf("error removing checkpoint: %w", err)
+			}
+		}
+		db.ingest.checkpoint = nil
+	} else {
+		// collections written with another model or in an older schema are refused or migrated before use
+		if err := db.checkCollectionSchemas(); err != nil {
+			return nil, fmt.Errorf("error checking collections: %w", err)
+		}
+
+		if opts.CatalogPath != "" {
+			report, err := db.importCatalog(scheduleCSVPath, opts.CatalogPath)
+			fmt.Print(report)
+			if err != nil {
+				return nil, fmt.Errorf("error importing catalog: %w", err)
+			}
+		}
+	}
+
+	if db.lexicalIndex == nil {
+		if err := db.loadLexicalIndex(scheduleCSVPath); err != nil {
+			return nil, fmt.Errorf("error loading lexical index: %w", err)
+		}
+	}
+
+	if err := db.loadPrereqGraph(opts.PrereqPath); err != nil {
+		return nil, fmt.Errorf("error loading prerequisite graph: %w", err)
+	}
+
+	if opts.OfficeHoursPath != "" {
+		if db.officeHours, err = loadOfficeHours(opts.OfficeHoursPath); err != nil {
+			return nil, fmt.Errorf("error loading office hours: %w", err)
 		}
 	}
 
+	if db.embeddingCache != nil {
+		log.Printf("Embedding cache: %s", db.embeddingCache.Stats())
+	}
+
 	return db, nil
 }
 
 // initialize the 'db' struct
-func initializeDB() (*Db, error) {
+func initializeDB(opts StartOptions) (*Db, error) {
 	ct := context.Background()
-
-	// Initialize ChromaDB client
-	client, err := chroma.NewClient()
-	if err != nil {
-		log.Fatalf("Failed to create ChromaDB client: %v", err)
+	httpClient := opts.HTTPClient
+	if httpClient == nil {
+		httpClient = &http.Client{}
 	}
 
-	// Initialize OpenAI embedding function
-	openaiEf, err := openai.NewOpenAIEmbeddingFunction(os.Getenv("OPENAI_API_KEY"))
+	// Initialize the vector store, embedding with OpenAI through the cache if there is one
+	openaiEmbeddings, err := newEmbeddingFunction(httpClient)
 	if err != nil {
-		log.Fatalf("Error creating OpenAI embedding function: %v", err)
+		return nil, err
 	}
-
-	// Define collection names
-	coursesCollectionName := "usf-courses"
-	instructorsCollectionName := "instructors"
-	subjectsCollectionName := "subjects"
-
-	// Get or create collections
-	coursesCollection, err := client.GetCollection(ct, coursesCollectionName, openaiEf)
-	if err != nil {
-		if IsNotFoundError(err) {
-			log.Printf("Courses collection '%s' does not exist, creating later.", coursesCollectionName)
-			coursesCollection = nil
+	var embeddings types.EmbeddingFunction = openaiEmbeddings
+	var cache *embeddingCache
+	if opts.EmbeddingCachePath != "" {
+		// a cache that can't be opened, e.g. because another chatbot has it, only costs the savings
+		cache, err = openEmbeddingCache(opts.EmbeddingCachePath, string(embeddingModel), opts.EmbeddingCacheMaxBytes, openaiEmbeddings)
+		if err != nil {
+			log.Printf("Embedding without a cache: %v", err)
 		} else {
-			log.Fatalf("Failed to get courses collection: %v", err)
+			embeddings = cache
 		}
 	}
-
-	instructorsCollection, err := client.GetCollection(ct, instructorsCollectionName, openaiEf)
+	store, err := newVectorStore(opts.Store, opts.StorePath, opts.PostgresDSN, embeddings, httpClient)
 	if err != nil {
-		if IsNotFoundError(err) {
-			log.Printf("Instructors collection '%s' does not exist, reating later.", instructorsCollectionName)
-			instructorsCollection = nil
-		} else {
-			log.Fatalf("Failed to get instructors collection: %v", err)
-		}
+		return nil, err
 	}
-
-	subjectsCollection, err := client.GetCollection(ct, subjectsCollectionName, openaiEf)
+	stateDir, err := storeStateDir(opts.Store, opts.StorePath, opts.PostgresDSN)
 	if err != nil {
-		if IsNotFoundError(err) {
-			log.Printf("Subjects collection '%s' does not exist, creating later.", subjectsCollectionName)
-			subjectsCollection = nil
-		} else {
-			log.Fatalf("Failed to get subjects collection: %v", err)
-		}
+		return nil, err
+	}
+	if err := os.MkdirAll(stateDir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating the store's index directory: %w", err)
 	}
 
 	db := Db{
 		ctx:                       ct,
-		client:                    client,
-		coursesCollection:         coursesCollection,
-		coursesCollectionName:     coursesCollectionName,
-		instructorsCollection:     instructorsCollection,
-		instructorsCollectionName: instructorsCollectionName,
-		subjectsCollection:        subjectsCollection,
-		subjectsCollectionName:    subjectsCollectionName,
+		store:                     store,
+		httpClient:                httpClient,
+		embeddingCache:            cache,
+		stateDir:                  stateDir,
+		coursesCollectionName:     defaultCoursesCollectionName,
+		instructorsCollectionName: defaultInstructorsCollectionName,
+		subjectsCollectionName:    defaultSubjectsCollectionName,
+		courseCodesCollectionName: defaultCourseCodesCollectionName,
+	}
+
+	// Get the collections, which are created later if they don't exist yet
+	if db.coursesCollection, err = db.getCollection("Courses", db.coursesCollectionName); err != nil {
+		return nil, err
+	}
+	if db.instructorsCollection, err = db.getCollection("Instructors", db.instructorsCollectionName); err != nil {
+		return nil, err
+	}
+	if db.subjectsCollection, err = db.getCollection("Subjects", db.subjectsCollectionName); err != nil {
+		return nil, err
+	}
+	if db.courseCodesCollection, err = db.getCollection("Course codes", db.courseCodesCollectionName); err != nil {
+		return nil, err
 	}
 
 	return &db, nil
 }
 
-// deletes the three collections so that the database can remake these collections with new data
+// the named collection, or nil if it doesn't exist yet
+func (db *Db) getCollection(kind, name string) (VectorCollection, error) {
+	collection, err := db.store.GetCollection(db.ctx, name)
+	if errors.Is(err, ErrCollectionNotFound) {
+		log.Printf("%s collection '%s' does not exist, creating later.", kind, name)
+		return nil, nil
+	}
+	return collection, err
+}
+
+// a copy of the database whose requests are made within ctx
+func (db *Db) withContext(ctx context.Context) *Db {
+	scoped := *db
+	scoped.ctx = ctx
+	return &scoped
+}
+
+// the OpenAI embedding function used by every collection, sending its requests through httpClient
+func newEmbeddingFunction(httpClient *http.Client) (*openai.OpenAIEmbeddingFunction, error) {
+	apiKey := os.Getenv("OPENAI_API_KEY")
+	// the embedding client panics on its first request without a key, so fail here instead
+	if apiKey == "" {
+		return nil, &EmbeddingError{Err: errors.New("OPENAI_API_KEY is not set")}
+	}
+	ef, err := openai.NewOpenAIEmbeddingFunction(apiKey, openai.WithModel(embeddingModel), func(c *openai.OpenAIClient) error {
+		c.Client = httpClient
+		return nil
+	})
+	if err != nil {
+		return nil, &EmbeddingError{Err: err}
+	}
+	return ef, nil
+}
+
+// the OpenAI chat client, sending its requests through the same HTTP client as the database
+func (db *Db) newChatClient() *goopenai.Client {
+	config := goopenai.DefaultConfig(os.Getenv("OPENAI_API_KEY"))
+	if db.httpClient != nil {
+		config.HTTPClient = db.httpClient
+	}
+	return goopenai.NewClientWithConfig(config)
+}
+
+// deletes the collections so that the database can remake these collections with new data
 func (db *Db) deleteCollections() error {
-	if db.client == nil {
-		return fmt.Errorf("ChromaDB client is not initialized")
+	if db.store == nil {
+		return fmt.Errorf("vector store is not initialized")
 	}
 
-	_, err := db.client.DeleteCollection(db.ctx, db.coursesCollectionName)
-	if err != nil && !IsNotFoundError(err) {
-		return fmt.Errorf("Error deleting courses collection: %v", err)
+	err := db.store.DeleteCollection(db.ctx, db.coursesCollectionName)
+	if err != nil && !errors.Is(err, ErrCollectionNotFound) {
+		return fmt.Errorf("Error deleting courses collection: %w", err)
 	}
 
-	_, err = db.client.DeleteCollection(db.ctx, db.instructorsCollectionName)
-	if err != nil && !IsNotFoundError(err) {
-		return fmt.Errorf("Error deleting instructors collection: %v", err)
+	err = db.store.DeleteCollection(db.ctx, db.instructorsCollectionName)
+	if err != nil && !errors.Is(err, ErrCollectionNotFound) {
+		return fmt.Errorf("Error deleting instructors collection: %w", err)
 	}
 
-	_, err = db.client.DeleteCollection(db.ctx, db.subjectsCollectionName)
-	if err != nil && !IsNotFoundError(err) {
-		return fmt.Errorf("Error deleting subjects collection: %v", err)
+	err = db.store.DeleteCollection(db.ctx, db.subjectsCollectionName)
+	if err != nil && !errors.Is(err, ErrCollectionNotFound) {
+		return fmt.Errorf("Error deleting subjects collection: %w", err)
+	}
+
+	err = db.store.DeleteCollection(db.ctx, db.courseCodesCollectionName)
+	if err != nil && !errors.Is(err, ErrCollectionNotFound) {
+		return fmt.Errorf("Error deleting course codes collection: %w", err)
 	}
 
 	return nil
 }
 
-// create the courses, instructors, and subjects collections
+// create the courses, instructors, subjects and course codes collections
 func (db *Db) createCollections() error {
-	if db.client == nil {
-		return fmt.Errorf("ChromaDB client is not initialized")
+	if db.store == nil {
+		return fmt.Errorf("vector store is not initialized")
 	}
 
-	openaiEf, err := openai.NewOpenAIEmbeddingFunction(os.Getenv("OPENAI_API_KEY"))
-	if err != nil {
-		return fmt.Errorf("error creating OpenAI embedding function: %w", err)
+	info := currentCollectionInfo()
+	var err error
+	if db.coursesCollection, err = db.store.CreateCollection(db.ctx, db.coursesCollectionName, info); err != nil {
+		return err
 	}
-
-	coursesCollection, err := db.client.CreateCollection(db.ctx, db.coursesCollectionName, nil, true, openaiEf, types.L2)
-	if err != nil {
-		return fmt.Errorf("failed to create courses collection '%s': %w", db.coursesCollectionName, err)
+	if db.instructorsCollection, err = db.store.CreateCollection(db.ctx, db.instructorsCollectionName, info); err != nil {
+		return err
+	}
+	if db.subjectsCollection, err = db.store.CreateCollection(db.ctx, db.subjectsCollectionName, info); err != nil {
+		return err
+	}
+	if db.courseCodesCollection, err = db.store.CreateCollection(db.ctx, db.courseCodesCollectionName, info); err != nil {
+		return err
 	}
-	db.coursesCollection = coursesCollection
 
-	instructorsCollection, err := db.client.CreateCollection(db.ctx, db.instructorsCollectionName, nil, true, openaiEf, types.L2)
+	return nil
+}
+
+
+// reads the schedule CSV and the optional catalog, joining the catalog onto each section and
+// rebuilding the prerequisite graph from the catalog text. Rows that can't be read are added to the report.
+func (db *Db) readCoursesWithCatalog(filePath, catalogPath string, report *IngestReport) ([]Course, error) {
+	courses, skipped, err := readCourseRows(filePath)
 	if err != nil {
-		return fmt.Errorf("failed to create instructors collection '%s': %w", db.instructorsCollectionName, err)
+		return nil, err
+	}
+	report.SkippedRows = append(report.SkippedRows, skipped...)
+	if catalogPath == "" {
+		return courses, nil
 	}
-	db.instructorsCollection = instructorsCollection
 
-	subjectsCollection, err := db.client.CreateCollection(db.ctx, db.subjectsCollectionName, nil, true, openaiEf, types.L2)
+	catalog, err := readCatalog(catalogPath)
 	if err != nil {
-		return fmt.Errorf("failed to create subjects collection '%s': %w", db.subjectsCollectionName, err)
+		return nil, err
 	}
-	db.subjectsCollection = subjectsCollection
+	matched := joinCatalog(courses, catalog)
+	fmt.Printf("Joined %d catalog entries onto %d of %d sections.\n", len(catalog), matched, len(courses))
 
-	return nil
+	db.prereqGraph = BuildPrereqGraph(catalog)
+	if err := db.prereqGraph.Save(db.prereqGraphPath()); err != nil {
+		return nil, err
+	}
+	fmt.Printf("Built prerequisites for %d courses.\n", len(db.prereqGraph.Prerequisites))
+	return courses, nil
 }
 
-
-func (db *Db) parseCSVIntoDatabase(filePath string) error {
-	courses, err := readCoursesFromCSV(filePath)
-	if err != nil {
-		log.Fatalf("Error reading courses from CSV: %v", err)
+// the metadata stored alongside each course document for querying
+func courseMetadata(course Course) map[string]interface{} {
+	return map[string]interface{}{
+		"CRN":                    course.CRN,
+		"Subject":                course.Subject,
+		"CourseNumber":           course.CourseNumber,
+		"Section":                course.Section,
+		"TitleShortDesc":         course.Title,
+		"PrimaryInstructorEmail": course.InstructorEmail,
+		"College":                course.College,
+		"MeetDays":               course.MeetDays,
+		"BeginTime":              course.BeginTime,
+		"EndTime":                course.EndTime,
+		"Building":               course.Building,
+		"Room":                   course.Room,
+		"ActualEnrollment":       course.ActualEnrollment,
+		"InstructorFirstName":    course.InstructorFirstName,
+		"InstructorLastName":     course.InstructorLastName,
+		"InstructorFullName":     course.instructorFullName(),
+		"InstructorStatus":       course.InstructorStatus,
+		"MeetingStatus":          course.MeetingStatus,
+		"Credits":                course.Credits,
+		"Attributes":             course.Attributes,
 	}
+}
 
-	var (
-		courseDocuments     []string
-		courseMetadatas     []map[string]interface{}
-		courseIDs           []string
-		instructorDocuments []string
-		instructorIDs       []string
-		subjectDocuments    []string
-		subjectIDs          []string
-	)
+// the records written to one collection
+type collectionDocuments struct {
+	ids       []string
+	documents []string
+	metadatas []map[string]interface{}
+}
+
+// the records of every collection ingested from the schedule
+type ingestDocuments struct {
+	courses, instructors, subjects, courseCodes collectionDocuments
+}
 
-	instructorSet := make(map[string]struct{})
-	subjectSet := make(map[string]struct{})
+// builds the documents of the courses, instructors, subjects and course codes collections from the
+// courses, naming the subjects after their departments
+func buildCollectionDocuments(courses []Course, departments map[string]Department) (ingestDocuments, error) {
+	var docs ingestDocuments
 
 	for _, course := range courses {
 		// Process courses collection
 		courseJSON, err := json.Marshal(course)
 		if err != nil {
-			log.Fatalf("Error marshaling course to JSON: %v", err)
+			return docs, fmt.Errorf("error marshaling course to JSON: %w", err)
 		}
 
-		document := string(courseJSON)
-		// Create metadata for querying
-		metadata := map[string]interface{}{
-			"CRN":                    course.CRN,
-			"Subject":                course.Subject,
-			"CourseNumber":           course.CourseNumber,
-			"Section":                course.Section,
-			"TitleShortDesc":         course.Title,
-			"PrimaryInstructorEmail": course.InstructorEmail,
-			"College":                course.College,
-			"MeetDays":               course.MeetDays,
-			"BeginTime":              course.BeginTime,
-			"EndTime":                course.EndTime,
-			"Building":               course.Building,
-			"Room":                   course.Room,
-			"InstructorFirstName":    course.InstructorFirstName,
-			"InstructorLastName":     course.InstructorLastName,
-			"InstructorFullName":     course.InstructorFirstName + " " + course.InstructorLastName,
-		}
+		// Generate a unique ID for the course using CRN, with metadata for querying
+		docs.courses.documents = append(docs.courses.documents, string(courseJSON))
+		docs.courses.metadatas = append(docs.courses.metadatas, courseMetadata(course))
+		docs.courses.ids = append(docs.courses.ids, course.CRN)
+	}
 
-		// Generate a unique ID for the course using CRN
-		id := course.CRN
+	// one record per instructor keyed by their email, leaving out the sections with none assigned
+	// so that no query is matched to a blank instructor. The name is what is embedded.
+	for _, profile := range buildInstructorProfiles(courses) {
+		docs.instructors.documents = append(docs.instructors.documents, profile.Name)
+		docs.instructors.metadatas = append(docs.instructors.metadatas, instructorMetadata(profile))
+		docs.instructors.ids = append(docs.instructors.ids, profile.Key)
+	}
 
-		courseDocuments = append(courseDocuments, document)
-		courseMetadatas = append(courseMetadatas, metadata)
-		courseIDs = append(courseIDs, id)
+	// one record per subject code, embedded as its department name
+	for _, subject := range buildSubjectIndex(courses, departments) {
+		docs.subjects.documents = append(docs.subjects.documents, subject.document())
+		docs.subjects.metadatas = append(docs.subjects.metadatas, subjectMetadata(subject))
+		docs.subjects.ids = append(docs.subjects.ids, subject.Code)
+	}
 
-		// gather all instructors in the csv without repeating names
-		instructorFullName := course.InstructorFirstName + " " + course.InstructorLastName
-		if _, exists := instructorSet[instructorFullName]; !exists {
-			instructorSet[instructorFullName] = struct{}{}
-			instructorDocuments = append(instructorDocuments, instructorFullName)
-			instructorIDs = append(instructorIDs, instructorFullName)
-		}
+	// one record per course code, embedded as its most common title
+	for _, course := range buildCourseIndex(courses) {
+		docs.courseCodes.documents = append(docs.courseCodes.documents, course.Title)
+		docs.courseCodes.metadatas = append(docs.courseCodes.metadatas, courseCodeMetadata(course))
+		docs.courseCodes.ids = append(docs.courseCodes.ids, course.Code)
+	}
 
-		// gather all subjects in the csv without repeating
-		subject := course.Title
-		if _, exists := subjectSet[subject]; !exists {
-			subjectSet[subject] = struct{}{}
-			subjectDocuments = append(subjectDocuments, subject)
-			subjectIDs = append(subjectIDs, subject)
-		}
+	return docs, nil
+}
+
+// the records written to a collection in a single request
+const ingestBatchSize = 500
+
+// hands the records of the named collection to write in batches small enough for a single
+// request, returning how many were written. Batches are written by the configured number of
+// workers within the token budget, and the ones the checkpoint has are skipped. A batch that fails
+// stops the write unless skipFailed is set, in which case it is skipped and the remaining batches
+// are still written; either way the failed batches are returned.
+func (db *Db) writeInBatches(name string, docs collectionDocuments, skipFailed bool, write func(ids, documents []string, metadatas []map[string]interface{}) error) (int, []BatchError) {
+	progress := newProgressBar(db.ingest.progress, name, len(docs.documents))
+	defer progress.finish()
+
+	var mu sync.Mutex
+	written := 0
+	stopped := false
+	var failed []BatchError
+
+	batches := make(chan int)
+	var wg sync.WaitGroup
+	workers := max(db.ingest.workers, 1)
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for start := range batches {
+				mu.Lock()
+				skip := stopped
+				mu.Unlock()
+				if skip {
+					continue
+				}
+
+				end := min(start+ingestBatchSize, len(docs.documents))
+				var metadatas []map[string]interface{}
+				if docs.metadatas != nil {
+					metadatas = docs.metadatas[start:end]
+				}
+				err := db.ingest.limiter.wait(db.ctx, estimateTokens(docs.documents[start:end]))
+				if err == nil {
+					err = write(docs.ids[start:end], docs.documents[start:end], metadatas)
+				}
+
+				mu.Lock()
+				if err != nil {
+					failed = append(failed, BatchError{Start: start, End: end, Err: err})
+					stopped = stopped || !skipFailed
+				} else {
+					written += end - start
+					progress.add(end - start)
+					if err := db.ingest.checkpoint.markWritten(name, start/ingestBatchSize); err != nil {
+						log.Printf("Error saving checkpoint: %v", err)
+					}
+				}
+				mu.Unlock()
+			}
+		}()
 	}
 
-	// Insert into courses collection
-	batchSize := 500
-	for i := 0; i < len(courseDocuments); i += batchSize {
-		end := i + batchSize
-		if end > len(courseDocuments) {
-			end = len(courseDocuments)
+	for start := 0; start < len(docs.documents); start += ingestBatchSize {
+		if db.ingest.checkpoint.written(name, start/ingestBatchSize) {
+			end := min(start+ingestBatchSize, len(docs.documents))
+			mu.Lock()
+			written += end - start
+			mu.Unlock()
+			progress.skip(end - start)
+			continue
 		}
-
-		_, err = db.coursesCollection.Add(
-			db.ctx,
-			nil,
-			courseMetadatas[i:end],
-			courseDocuments[i:end],
-			courseIDs[i:end],
-		)
-		if err != nil {
-			log.Fatalf("Error adding documents to courses collection: %v", err)
+		mu.Lock()
+		stop := stopped
+		mu.Unlock()
+		if stop {
+			break
 		}
+		batches <- start
 	}
-	fmt.Printf("Successfully added %d courses to the courses collection.\n", len(courseDocuments))
+	close(batches)
+	wg.Wait()
 
-	// Insert into instructors collection
-	for i := 0; i < len(instructorDocuments); i += batchSize {
-		end := i + batchSize
-		if end > len(instructorDocuments) {
-			end = len(instructorDocuments)
-		}
+	sort.Slice(failed, func(i, j int) bool { return failed[i].Start < failed[j].Start })
+	return written, failed
+}
 
-		_, err = db.instructorsCollection.Add(
-			db.ctx,
-			nil,
-			nil,
-			instructorDocuments[i:end],
-			instructorIDs[i:end],
-		)
-		if err != nil {
-			log.Fatalf("Error adding documents to instructors collection: %v", err)
-		}
+// adds the records to the named collection in batches, recording what was written and what failed in
+// the report. Failed batches only stop the ingestion when it isn't partial.
+func (db *Db) addDocuments(name string, collection VectorCollection, docs collectionDocuments, report *IngestReport) error {
+	if collection == nil {
+		return &CollectionMissingError{Name: name}
+	}
+	written, failed := db.writeInBatches(name, docs, db.partialIngest, func(ids, documents []string, metadatas []map[string]interface{}) error {
+		return collection.Add(db.ctx, ids, documents, metadatas)
+	})
+	return report.record(name, written, failed, db.partialIngest)
+}
+
+// ingests the schedule CSV, joined with the optional catalog, into the four collections and the lexical index
+func (db *Db) parseCSVIntoDatabase(filePath, catalogPath string) (*IngestReport, error) {
+	report := newIngestReport()
+	courses, err := db.readCoursesWithCatalog(filePath, catalogPath, report)
+	if err != nil {
+		return report, fmt.Errorf("error reading courses from CSV: %w", err)
+	}
+
+	docs, err := buildCollectionDocuments(courses, db.departments)
+	if err != nil {
+		return report, fmt.Errorf("error building documents: %w", err)
+	}
+
+	// Insert into courses collection
+	if err := db.addDocuments(db.coursesCollectionName, db.coursesCollection, docs.courses, report); err != nil {
+		return report, fmt.Errorf("error adding documents to courses collection: %w", err)
+	}
+	fmt.Printf("Successfully added %d courses to the courses collection.\n", report.Written[db.coursesCollectionName])
+
+	// Insert into instructors collection
+	if err := db.addDocuments(db.instructorsCollectionName, db.instructorsCollection, docs.instructors, report); err != nil {
+		return report, fmt.Errorf("error adding documents to instructors collection: %w", err)
 	}
-	fmt.Printf("Successfully added %d instructors to the instructors collection.\n", len(instructorDocuments))
+	fmt.Printf("Successfully added %d instructors to the instructors collection.\n", report.Written[db.instructorsCollectionName])
 
 	// Insert into subjects collection
-	for i := 0; i < len(subjectDocuments); i += batchSize {
-		end := i + batchSize
-		if end > len(subjectDocuments) {
-			end = len(subjectDocuments)
+	if err := db.addDocuments(db.subjectsCollectionName, db.subjectsCollection, docs.subjects, report); err != nil {
+		return report, fmt.Errorf("error adding documents to subjects collection: %w", err)
+	}
+	fmt.Printf("Successfully added %d subjects to the subjects collection.\n", report.Written[db.subjectsCollectionName])
+
+	// Insert into course codes collection
+	if err := db.addDocuments(db.courseCodesCollectionName, db.courseCodesCollection, docs.courseCodes, report); err != nil {
+		return report, fmt.Errorf("error adding documents to course codes collection: %w", err)
+	}
+	fmt.Printf("Successfully added %d courses to the course codes collection.\n", report.Written[db.courseCodesCollectionName])
+
+	// build the lexical index alongside the collections so both describe the same courses
+	db.lexicalIndex = BuildLexicalIndex(courses)
+	if err := db.lexicalIndex.Save(db.lexicalIndexPath()); err != nil {
+		return report, err
+	}
+	fmt.Printf("Successfully built the lexical index over %d courses.\n", len(db.lexicalIndex.DocLengths))
+
+	return report, nil
+}
+
+// re-embeds every course document with its catalog information joined in, without touching the
+// other collections
+func (db *Db) importCatalog(filePath, catalogPath string) (*IngestReport, error) {
+	report := newIngestReport()
+	if db.coursesCollection == nil {
+		return report, &CollectionMissingError{Name: db.coursesCollectionName}
+	}
+
+	courses, err := db.readCoursesWithCatalog(filePath, catalogPath, report)
+	if err != nil {
+		return report, err
+	}
+
+	var courseDocs collectionDocuments
+	seen := make(map[string]struct{})
+	for _, course := range courses {
+		if _, exists := seen[course.CRN]; exists {
+			continue
 		}
+		seen[course.CRN] = struct{}{}
 
-		_, err = db.subjectsCollection.Add(
-			db.ctx,
-			nil,
-			nil,
-			subjectDocuments[i:end],
-			subjectIDs[i:end],
-		)
+		courseJSON, err := json.Marshal(course)
 		if err != nil {
-			log.Fatalf("Error adding documents to subjects collection: %v", err)
+			return report, fmt.Errorf("error marshaling course to JSON: %w", err)
 		}
+		courseDocs.documents = append(courseDocs.documents, string(courseJSON))
+		courseDocs.metadatas = append(courseDocs.metadatas, courseMetadata(course))
+		courseDocs.ids = append(courseDocs.ids, course.CRN)
 	}
-	fmt.Printf("Successfully added %d subjects to the subjects collection.\n", len(subjectDocuments))
 
-	return nil
+	written, failed := db.writeInBatches(db.coursesCollectionName, courseDocs, db.partialIngest, func(ids, documents []string, metadatas []map[string]interface{}) error {
+		return db.coursesCollection.Upsert(db.ctx, ids, documents, metadatas)
+	})
+	if err := report.record(db.coursesCollectionName, written, failed, db.partialIngest); err != nil {
+		return report, fmt.Errorf("error upserting documents into courses collection: %w", err)
+	}
+	fmt.Printf("Successfully imported catalog information for %d courses.\n", written)
+
+	db.lexicalIndex = BuildLexicalIndex(courses)
+	return report, db.lexicalIndex.Save(db.lexicalIndexPath())
+}
+
+// where the store's lexical index is saved
+func (db *Db) lexicalIndexPath() string {
+	return filepath.Join(db.stateDir, lexicalIndexFile)
 }
 
-func IsNotFoundError(err error)bool{
+// where the store's prerequisite graph is saved
+func (db *Db) prereqGraphPath() string {
+	return filepath.Join(db.stateDir, prereqGraphFile)
+}
+
+// loads the lexical index saved by the last ingestion, rebuilding it from the courses in the store
+// if it is missing, or from the CSV before anything has been ingested
+func (db *Db) loadLexicalIndex(filePath string) error {
+	lexicalIndexPath := db.lexicalIndexPath()
+	idx, err := LoadLexicalIndex(lexicalIndexPath)
 	if err == nil {
-		return false
+		db.lexicalIndex = idx
+		return nil
+	}
+	if !os.IsNotExist(err) {
+		return err
+	}
+
+	var courses []Course
+	if db.coursesCollection != nil {
+		log.Printf("Lexical index '%s' does not exist, rebuilding it from the '%s' collection.", lexicalIndexPath, db.coursesCollectionName)
+		courses, err = storedCourses(db.ctx, db.coursesCollection)
+	} else {
+		log.Printf("Lexical index '%s' does not exist, rebuilding it from '%s'.", lexicalIndexPath, filePath)
+		courses, err = readCoursesFromCSV(filePath)
 	}
-	return strings.Contains(err.Error(), "does not exist")
+	if err != nil {
+		return err
+	}
+	db.lexicalIndex = BuildLexicalIndex(courses)
+	return db.lexicalIndex.Save(lexicalIndexPath)
+}
+
+// loads the prerequisite graph saved by the last catalog import and merges the structured file over it
+func (db *Db) loadPrereqGraph(prereqPath string) error {
+	if db.prereqGraph == nil {
+		graph, err := LoadPrereqGraph(db.prereqGraphPath())
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		if graph == nil {
+			graph = newPrereqGraph()
+		}
+		db.prereqGraph = graph
+	}
+
+	if prereqPath != "" {
+		return db.prereqGraph.mergeStructuredFile(prereqPath)
+	}
+	return nil
 }
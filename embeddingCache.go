@@ -0,0 +1,275 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/amikos-tech/chroma-go/types"
+	bolt "go.etcd.io/bbolt"
+)
+
+// the default file and size of the embedding cache
+const (
+	defaultEmbeddingCachePath   = "embedding-cache.db"
+	defaultEmbeddingCacheSizeMB = 256
+)
+
+// the buckets of the cache file: the vectors by key, the last use of each key, and the keys in the
+// order they were last used, oldest first, so the least recently used are evicted first
+var (
+	embeddingsBucket = []byte("embeddings")
+	usedBucket       = []byte("used")
+	lruBucket        = []byte("lru")
+	cacheMetaBucket  = []byte("meta")
+	cacheBytesKey    = []byte("bytes")
+)
+
+// embeddingCache is an embedding function that keeps every vector it gets in a file, keyed by the
+// model and the text, so that the same text is only sent to OpenAI once
+type embeddingCache struct {
+	embeddings types.EmbeddingFunction
+	db         *bolt.DB
+	model      string
+	maxBytes   int64
+	hits       atomic.Int64
+	misses     atomic.Int64
+}
+
+// opens the cache at path in front of embeddings, which embed with model. Once the vectors take up
+// more than maxBytes the least recently used are evicted; 0 means no cap.
+func openEmbeddingCache(path, model string, maxBytes int64, embeddings types.EmbeddingFunction) (*embeddingCache, error) {
+	// only one process can have the file open, so don't wait for another one to close it
+	db, err := bolt.Open(path, 0o644, &bolt.Options{Timeout: time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("opening embedding cache %s: %w", path, err)
+	}
+	err = db.Update(func(tx *bolt.Tx) error {
+		for _, name := range [][]byte{embeddingsBucket, usedBucket, lruBucket, cacheMetaBucket} {
+			if _, err := tx.CreateBucketIfNotExists(name); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		db.Close()
+		return nil, fmt.Errorf("opening embedding cache %s: %w", path, err)
+	}
+	return &embeddingCache{embeddings: embeddings, db: db, model: model, maxBytes: maxBytes}, nil
+}
+
+func (c *embeddingCache) Close() error {
+	return c.db.Close()
+}
+
+// the key of a text: texts that differ only in whitespace share one, but case is kept since it can
+// change the embedding
+func (c *embeddingCache) key(text string) []byte {
+	sum := sha256.Sum256([]byte(c.model + "\n" + strings.Join(strings.Fields(text), " ")))
+	return sum[:]
+}
+
+func (c *embeddingCache) EmbedDocuments(ctx context.Context, texts []string) ([]*types.Embedding, error) {
+	return c.embed(ctx, texts, c.embeddings.EmbedDocuments)
+}
+
+func (c *embeddingCache) EmbedQuery(ctx context.Context, text string) (*types.Embedding, error) {
+	embeddings, err := c.embed(ctx, []string{text}, func(ctx context.Context, texts []string) ([]*types.Embedding, error) {
+		embedding, err := c.embeddings.EmbedQuery(ctx, texts[0])
+		return []*types.Embedding{embedding}, err
+	})
+	if err != nil {
+		return nil, err
+	}
+	return embeddings[0], nil
+}
+
+func (c *embeddingCache) EmbedRecords(ctx context.Context, records []*types.Record, force bool) error {
+	return types.EmbedRecordsDefaultImpl(c, ctx, records, force)
+}
+
+// looks up every text, embeds the missing ones at once with embed and caches them. The cache
+// failing only costs the lookups, so its errors are logged rather than returned.
+func (c *embeddingCache) embed(ctx context.Context, texts []string, embed func(context.Context, []string) ([]*types.Embedding, error)) ([]*types.Embedding, error) {
+	keys := make([][]byte, len(texts))
+	for i, text := range texts {
+		keys[i] = c.key(text)
+	}
+
+	results := make([]*types.Embedding, len(texts))
+	err := c.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(embeddingsBucket)
+		for i, key := range keys {
+			if value := bucket.Get(key); value != nil {
+				results[i] = types.NewEmbeddingFromFloat32(decodeVector(value))
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		log.Printf("Error reading embedding cache: %v", err)
+	}
+
+	// each missing text is embedded once however many times it appears
+	var missing []string
+	missingIndex := map[string]int{}
+	hits := map[string][]byte{}
+	for i, key := range keys {
+		if results[i] != nil {
+			hits[string(key)] = key
+			continue
+		}
+		if _, seen := missingIndex[string(key)]; !seen {
+			missingIndex[string(key)] = len(missing)
+			missing = append(missing, texts[i])
+		}
+	}
+	c.hits.Add(int64(len(texts) - len(missing)))
+	c.misses.Add(int64(len(missing)))
+
+	var embedded []*types.Embedding
+	if len(missing) > 0 {
+		embedded, err = embed(ctx, missing)
+		if err != nil {
+			return nil, err
+		}
+		if len(embedded) != len(missing) {
+			return nil, fmt.Errorf("expected %d embeddings, got %d", len(missing), len(embedded))
+		}
+	}
+	fresh := map[string]*types.Embedding{}
+	for i, key := range keys {
+		if results[i] == nil {
+			results[i] = embedded[missingIndex[string(key)]]
+			fresh[string(key)] = results[i]
+		}
+	}
+
+	if err := c.store(fresh, hits); err != nil {
+		log.Printf("Error writing embedding cache: %v", err)
+	}
+	return results, nil
+}
+
+// writes the new vectors, marks the hits as just used and evicts what no longer fits
+func (c *embeddingCache) store(fresh map[string]*types.Embedding, hits map[string][]byte) error {
+	if len(fresh) == 0 && len(hits) == 0 {
+		return nil
+	}
+	return c.db.Update(func(tx *bolt.Tx) error {
+		vectors := tx.Bucket(embeddingsBucket)
+		size := cachedBytes(tx)
+		for k, embedding := range fresh {
+			key := []byte(k)
+			value := encodeVector(embeddingValues(embedding))
+			if previous := vectors.Get(key); previous != nil {
+				size -= int64(len(previous))
+			}
+			if err := vectors.Put(key, value); err != nil {
+				return err
+			}
+			size += int64(len(value))
+			if err := touch(tx, key); err != nil {
+				return err
+			}
+		}
+		for _, key := range hits {
+			if err := touch(tx, key); err != nil {
+				return err
+			}
+		}
+
+		lru := tx.Bucket(lruBucket)
+		for c.maxBytes > 0 && size > c.maxBytes {
+			oldest, _ := lru.Cursor().First()
+			if oldest == nil {
+				break
+			}
+			oldest = append([]byte(nil), oldest...)
+			key := oldest[8:]
+			size -= int64(len(vectors.Get(key)))
+			if err := errors.Join(vectors.Delete(key), tx.Bucket(usedBucket).Delete(key), lru.Delete(oldest)); err != nil {
+				return err
+			}
+		}
+		return tx.Bucket(cacheMetaBucket).Put(cacheBytesKey, binary.BigEndian.AppendUint64(nil, uint64(size)))
+	})
+}
+
+// marks key as the most recently used
+func touch(tx *bolt.Tx, key []byte) error {
+	used, lru := tx.Bucket(usedBucket), tx.Bucket(lruBucket)
+	if previous := used.Get(key); previous != nil {
+		if err := lru.Delete(append(append([]byte(nil), previous...), key...)); err != nil {
+			return err
+		}
+	}
+	sequence, err := lru.NextSequence()
+	if err != nil {
+		return err
+	}
+	// big endian so that the lru keys sort oldest first
+	order := binary.BigEndian.AppendUint64(nil, sequence)
+	if err := used.Put(key, order); err != nil {
+		return err
+	}
+	return lru.Put(append(order, key...), nil)
+}
+
+func cachedBytes(tx *bolt.Tx) int64 {
+	value := tx.Bucket(cacheMetaBucket).Get(cacheBytesKey)
+	if len(value) != 8 {
+		return 0
+	}
+	return int64(binary.BigEndian.Uint64(value))
+}
+
+func encodeVector(values []float32) []byte {
+	buf := make([]byte, 0, 4*len(values))
+	for _, v := range values {
+		buf = binary.LittleEndian.AppendUint32(buf, math.Float32bits(v))
+	}
+	return buf
+}
+
+func decodeVector(buf []byte) []float32 {
+	values := make([]float32, len(buf)/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(buf[4*i:]))
+	}
+	return values
+}
+
+// embeddingCacheStats is how well the cache has done since it was opened, and what it holds
+type embeddingCacheStats struct {
+	Hits    int64
+	Misses  int64
+	Entries int
+	Bytes   int64
+}
+
+func (c *embeddingCache) Stats() embeddingCacheStats {
+	stats := embeddingCacheStats{Hits: c.hits.Load(), Misses: c.misses.Load()}
+	c.db.View(func(tx *bolt.Tx) error {
+		stats.Entries = tx.Bucket(embeddingsBucket).Stats().KeyN
+		stats.Bytes = cachedBytes(tx)
+		return nil
+	})
+	return stats
+}
+
+func (s embeddingCacheStats) String() string {
+	rate := 0.0
+	if total := s.Hits + s.Misses; total > 0 {
+		rate = 100 * float64(s.Hits) / float64(total)
+	}
+	return fmt.Sprintf("%d hits, %d misses (%.0f%% hit rate), %d vectors in %.1f MB", s.Hits, s.Misses, rate, s.Entries, float64(s.Bytes)/(1<<20))
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/amikos-tech/chroma-go/types"
+)
+
+// an embedding function that counts the texts it is asked to embed
+type countingEmbeddingFunction struct {
+	types.EmbeddingFunction
+	texts []string
+}
+
+func (f *countingEmbeddingFunction) EmbedDocuments(ctx context.Context, texts []string) ([]*types.Embedding, error) {
+	f.texts = append(f.texts, texts...)
+	return f.EmbeddingFunction.EmbedDocuments(ctx, texts)
+}
+
+func (f *countingEmbeddingFunction) EmbedQuery(ctx context.Context, text string) (*types.Embedding, error) {
+	f.texts = append(f.texts, text)
+	return f.EmbeddingFunction.EmbedQuery(ctx, text)
+}
+
+func openTestEmbeddingCache(t *testing.T, path, model string, maxBytes int64) (*embeddingCache, *countingEmbeddingFunction) {
+	t.Helper()
+	counting := &countingEmbeddingFunction{EmbeddingFunction: types.NewConsistentHashEmbeddingFunction()}
+	cache, err := openEmbeddingCache(path, model, maxBytes, counting)
+	if err != nil {
+		t.Fatalf("Error opening cache: %v", err)
+	}
+	t.Cleanup(func() { cache.Close() })
+	return cache, counting
+}
+
+func TestEmbeddingCache(t *testing.T) {
+	ctx := context.Background()
+	path := filepath.Join(t.TempDir(), "embeddings.db")
+	cache, counting := openTestEmbeddingCache(t, path, "ada", 0)
+
+	first, err := cache.EmbedDocuments(ctx, []string{"Software Development", "Ethics", "Software Development"})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"Software Development", "Ethics"}; !reflect.DeepEqual(counting.texts, expected) {
+		t.Errorf("Expected each text to be embedded once, got %v", counting.texts)
+	}
+
+	tests := []struct {
+		name string
+		text string
+		// whether the text is sent to the embedding function
+		embedded bool
+	}{
+		{"same text", "Software Development", false},
+		{"different whitespace", "  Software\tDevelopment ", false},
+		{"different case", "software development", true},
+		{"new text", "Computer Architecture", true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			before := len(counting.texts)
+			embedding, err := cache.EmbedQuery(ctx, test.text)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if embedded := len(counting.texts) > before; embedded != test.embedded {
+				t.Errorf("Expected embedded to be %v, got %v", test.embedded, embedded)
+			}
+			if !test.embedded && !reflect.DeepEqual(embeddingValues(embedding), embeddingValues(first[0])) {
+				t.Errorf("Expected the cached vector, got a different one")
+			}
+		})
+	}
+
+	stats := cache.Stats()
+	if stats.Hits != 3 || stats.Misses != 4 || stats.Entries != 4 {
+		t.Errorf("Expected 3 hits, 4 misses and 4 entries, got %+v", stats)
+	}
+
+	// the vectors are kept across restarts, but only for the model they were embedded with
+	cache.Close()
+	reopened, counting := openTestEmbeddingCache(t, path, "ada", 0)
+	if _, err := reopened.EmbedDocuments(ctx, []string{"Ethics"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(counting.texts) != 0 {
+		t.Errorf("Expected a reopened cache to hit, got %v embedded", counting.texts)
+	}
+	reopened.Close()
+	otherModel, counting := openTestEmbeddingCache(t, path, "text-embedding-3-small", 0)
+	if _, err := otherModel.EmbedDocuments(ctx, []string{"Ethics"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(counting.texts) != 1 {
+		t.Errorf("Expected another model to miss, got %v embedded", counting.texts)
+	}
+}
+
+func TestEmbeddingCacheEvictsLeastRecentlyUsed(t *testing.T) {
+	ctx := context.Background()
+	// the hash embeddings are 378 floats, so this holds two vectors
+	vectorBytes := int64(4 * 378)
+	cache, counting := openTestEmbeddingCache(t, filepath.Join(t.TempDir(), "embeddings.db"), "ada", 2*vectorBytes)
+
+	for _, text := range []string{"Ethics", "Laboratory", "Ethics", "Software Development"} {
+		if _, err := cache.EmbedQuery(ctx, text); err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+	}
+	// Laboratory was used least recently, so it went to make room for Software Development
+	counting.texts = nil
+	if _, err := cache.EmbedDocuments(ctx, []string{"Ethics", "Software Development", "Laboratory"}); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"Laboratory"}; !reflect.DeepEqual(counting.texts, expected) {
+		t.Errorf("Expected only %v to be embedded again, got %v", expected, counting.texts)
+	}
+	if stats := cache.Stats(); stats.Entries != 2 || stats.Bytes != 2*vectorBytes {
+		t.Errorf("Expected the cache to stay at 2 vectors, got %+v", stats)
+	}
+}
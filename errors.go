@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+)
+
+// the kinds of vector store failures callers handle, usable with errors.Is
+var (
+	ErrCollectionNotFound = errors.New("collection not found")
+	ErrDuplicateID        = errors.New("duplicate ID")
+	ErrUnavailable        = errors.New("vector store unavailable")
+)
+
+// StoreError is a vector store request that failed in a way matching one of the sentinel errors
+type StoreError struct {
+	Kind error
+	Op   string
+	Err  error
+}
+
+func (e *StoreError) Error() string {
+	return fmt.Sprintf("%s: %s: %v", e.Kind, e.Op, e.Err)
+}
+
+func (e *StoreError) Is(target error) bool {
+	return target == e.Kind
+}
+
+func (e *StoreError) Unwrap() error {
+	return e.Err
+}
+
+// StoreUnavailableError is a request to the vector store that failed because the store couldn't be
+// reached or couldn't answer it
+type StoreUnavailableError struct {
+	Op  string
+	Err error
+}
+
+func (e *StoreUnavailableError) Error() string {
+	return fmt.Sprintf("vector store unavailable: %s: %v", e.Op, e.Err)
+}
+
+func (e *StoreUnavailableError) Is(target error) bool {
+	return target == ErrUnavailable
+}
+
+func (e *StoreUnavailableError) Unwrap() error {
+	return e.Err
+}
+
+// EmbeddingError is a failure to turn documents into embeddings with OpenAI
+type EmbeddingError struct {
+	Err error
+}
+
+func (e *EmbeddingError) Error() string {
+	return fmt.Sprintf("embedding failed: %v", e.Err)
+}
+
+func (e *EmbeddingError) Unwrap() error {
+	return e.Err
+}
+
+// RowError is a row of the schedule CSV that can't be turned into a course
+type RowError struct {
+	Line   int
+	Reason string
+}
+
+func (e *RowError) Error() string {
+	return fmt.Sprintf("row %d: %s", e.Line, e.Reason)
+}
+
+// ValidationError is a schedule CSV with data-quality issues, refused by a strict ingestion
+type ValidationError struct {
+	Path   string
+	Issues int
+}
+
+func (e *ValidationError) Error() string {
+	return fmt.Sprintf("%s has %d data-quality issues; run 'go run . validate' to list them, or ingest without -strict", e.Path, e.Issues)
+}
+
+// CollectionMissingError is a collection that is needed but doesn't exist in the store
+type CollectionMissingError struct {
+	Name string
+}
+
+func (e *CollectionMissingError) Error() string {
+	return fmt.Sprintf("collection '%s' does not exist, run with -delete to ingest the schedule first", e.Name)
+}
+
+func (e *CollectionMissingError) Is(target error) bool {
+	return target == ErrCollectionNotFound
+}
+
+// BatchError is a batch of records that couldn't be written to a collection
+type BatchError struct {
+	Collection string
+	// the records [Start, End) of the collection's documents
+	Start, End int
+	Err        error
+}
+
+func (e *BatchError) Error() string {
+	return fmt.Sprintf("writing records %d-%d to %s: %v", e.Start, e.End-1, e.Collection, e.Err)
+}
+
+func (e *BatchError) Unwrap() error {
+	return e.Err
+}
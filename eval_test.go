@@ -0,0 +1,196 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// the golden set must only expect sections and names that are actually on the schedule
+func TestGoldenSetMatchesSchedule(t *testing.T) {
+	cases, err := readEvalSet("evals/golden.jsonl")
+	if err != nil {
+		t.Fatalf("Error reading eval set: %v", err)
+	}
+	courses, err := readCoursesFromCSV(scheduleCSVPath)
+	if err != nil {
+		t.Fatalf("Error reading schedule: %v", err)
+	}
+	crns := make(map[string]bool)
+	names := map[string]map[string]bool{"instructor": {}, "subject": {}}
+	for _, course := range courses {
+		crns[course.CRN] = true
+		names["instructor"][course.InstructorFirstName+" "+course.InstructorLastName] = true
+		names["subject"][course.Title] = true
+	}
+
+	ids := make(map[string]bool)
+	for _, c := range cases {
+		if ids[c.ID] {
+			t.Errorf("Duplicate case id '%s'", c.ID)
+		}
+		ids[c.ID] = true
+		for _, crn := range c.ExpectedCRNs {
+			if !crns[crn] {
+				t.Errorf("Case '%s' expects CRN %s, which is not on the schedule", c.ID, crn)
+			}
+		}
+		for kind, name := range c.ExpectedCanonicalNames {
+			if !names[kind][name] {
+				t.Errorf("Case '%s' expects %s '%s', which is not on the schedule", c.ID, kind, name)
+			}
+		}
+	}
+}
+
+func TestScoreEvalCase(t *testing.T) {
+	c := EvalCase{
+		ID:                     "peterson",
+		ExpectedCRNs:           []string{"40646", "42344"},
+		ExpectedTools:          []ExpectedToolCall{{Name: "get_relevant_courses", Arguments: map[string]string{"Subject": "CS", "CourseNumber": "272"}}},
+		ExpectedCanonicalNames: map[string]string{"instructor": "Philip Peterson"},
+	}
+	call := func(name, arguments string) openai.ToolCall {
+		return openai.ToolCall{Function: openai.FunctionCall{Name: name, Arguments: arguments}}
+	}
+
+	tests := []struct {
+		name      string
+		turn      AgentTurn
+		canonical map[string][]string
+		expected  map[string]float64
+	}{
+		{
+			name: "everything right",
+			turn: AgentTurn{
+				Answer:    "Software Development [CRN 40646] and its lab [CRN 42344].",
+				Sources:   map[string]Course{"40646": {CRN: "40646"}, "42344": {CRN: "42344"}},
+				ToolCalls: []openai.ToolCall{call("get_relevant_courses", `{"Subject": "cs", "CourseNumber": "272"}`)},
+			},
+			canonical: map[string][]string{"instructor": {"Philip Peterson"}},
+			expected: map[string]float64{
+				metricRetrievalRecall: 1, metricRetrievalPrecision: 1, metricToolAccuracy: 1,
+				metricArgumentAccuracy: 1, metricCanonicalAccuracy: 1, metricCitationAccuracy: 1,
+			},
+		},
+		{
+			name: "partly right",
+			turn: AgentTurn{
+				Answer:    "Software Development [CRN 40646], Laboratory [CRN 42345] and [CRN 99999].",
+				Sources:   map[string]Course{"40646": {CRN: "40646"}, "42345": {CRN: "42345"}, "40649": {CRN: "40649"}, "40519": {CRN: "40519"}},
+				ToolCalls: []openai.ToolCall{call("get_relevant_courses", `{"Subject": "CS", "CourseNumber": "315"}`)},
+			},
+			canonical: map[string][]string{"instructor": {"Gregory Benson"}},
+			expected: map[string]float64{
+				metricRetrievalRecall: 0.5, metricRetrievalPrecision: 0.25, metricToolAccuracy: 1,
+				metricArgumentAccuracy: 0.5, metricCanonicalAccuracy: 0, metricCitationAccuracy: 1.0 / 3,
+			},
+		},
+		{
+			name: "no tools called",
+			turn: AgentTurn{Answer: "I don't know."},
+			expected: map[string]float64{
+				metricRetrievalRecall: 0, metricRetrievalPrecision: 0, metricToolAccuracy: 0,
+				metricArgumentAccuracy: 0, metricCanonicalAccuracy: 0, metricCitationAccuracy: 0,
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result := scoreEvalCase(c, test.turn, test.canonical)
+			if !reflect.DeepEqual(result.Metrics, test.expected) {
+				t.Errorf("Expected metrics %v, got %v", test.expected, result.Metrics)
+			}
+		})
+	}
+}
+
+func TestAnyCallHasArgument(t *testing.T) {
+	calls := []map[string]interface{}{
+		{"courses": []interface{}{"cs315", "CS 245"}, "Subject": "Cs"},
+	}
+	tests := []struct {
+		name, argument, value string
+		expected              bool
+	}{
+		{"string ignoring case", "Subject", "CS", true},
+		{"list element ignoring spaces", "courses", "CS 315", true},
+		{"missing value", "courses", "CS 272", false},
+		{"missing argument", "CourseNumber", "272", false},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := anyCallHasArgument(calls, test.argument, test.value); got != test.expected {
+				t.Errorf("Expected %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestCompareWithBaseline(t *testing.T) {
+	baseline := &EvalSummary{
+		Metrics:      map[string]float64{metricRetrievalRecall: 0.9, metricToolAccuracy: 1},
+		LatencyP95Ms: 1000,
+	}
+	tests := []struct {
+		name     string
+		summary  EvalSummary
+		baseline *EvalSummary
+		expected []string
+	}{
+		{
+			name:     "within tolerance",
+			summary:  EvalSummary{Metrics: map[string]float64{metricRetrievalRecall: 0.89, metricToolAccuracy: 1}, LatencyP95Ms: 1200},
+			baseline: baseline,
+		},
+		{
+			name:     "regressed",
+			summary:  EvalSummary{Metrics: map[string]float64{metricRetrievalRecall: 0.7, metricToolAccuracy: 1}, LatencyP95Ms: 2000},
+			baseline: baseline,
+			expected: []string{metricRetrievalRecall, "latency_p95_ms"},
+		},
+		{
+			name:    "no baseline",
+			summary: EvalSummary{Metrics: map[string]float64{metricRetrievalRecall: 0.1}},
+		},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, regressions := compareWithBaseline(test.summary, test.baseline)
+			if !reflect.DeepEqual(regressions, test.expected) {
+				t.Errorf("Expected regressions %v, got %v", test.expected, regressions)
+			}
+		})
+	}
+}
+
+func TestRunEval(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+	cases := []EvalCase{{
+		ID:                     "benson",
+		Question:               "What is Greg Benson teaching?",
+		ExpectedCRNs:           []string{"40649", "42345"},
+		ExpectedTools:          []ExpectedToolCall{{Name: "get_relevant_courses"}},
+		ExpectedCanonicalNames: map[string]string{"instructor": "Gregory Benson"},
+	}}
+	chat := &fakeChat{script: []openai.ChatCompletionMessage{
+		toolCallMessage("call_1", "get_relevant_courses", `{"InstructorFullName": "Greg Benson"}`),
+		answerMessage("Computer Architecture [CRN 40649] and Laboratory [CRN 42345]."),
+	}}
+
+	results := runEval(db, cases, func() *Agent { return NewAgent(db, chat) })
+	summary := summarizeEval(results)
+	for _, metric := range evalMetrics {
+		if metric == metricArgumentAccuracy {
+			continue
+		}
+		if summary.Metrics[metric] != 1 {
+			t.Errorf("Expected %s to be 1, got %v", metric, summary.Metrics[metric])
+		}
+	}
+	if summary.Cases != 1 || summary.Errors != 0 {
+		t.Errorf("Expected 1 case without errors, got %+v", summary)
+	}
+}
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestExportImport(t *testing.T) {
+	ctx := context.Background()
+	source := openTestLocalStore(t, t.TempDir())
+	embedding := func(seed float32) []float32 {
+		values := make([]float32, embeddingDimension)
+		for i := range values {
+			values[i] = seed / float32(i+1)
+		}
+		return values
+	}
+	written := map[string][]EmbeddedRecord{
+		defaultCoursesCollectionName: {
+			{ID: "40646", Document: "Software Development", Metadata: map[string]interface{}{"Subject": "CS", "CourseNumber": "272"}, Embedding: embedding(1)},
+			{ID: "41182", Document: "Ethics", Metadata: map[string]interface{}{"Subject": "PHIL", "CourseNumber": "240"}, Embedding: embedding(2)},
+		},
+		defaultSubjectsCollectionName: {
+			{ID: "Computer Science", Document: "Computer Science", Embedding: embedding(3)},
+		},
+	}
+	for name, records := range written {
+		collection, err := source.CreateCollection(ctx, name, currentCollectionInfo())
+		if err != nil {
+			t.Fatalf("Error creating collection: %v", err)
+		}
+		if err := collection.(EmbeddedCollection).UpsertEmbedded(ctx, records); err != nil {
+			t.Fatalf("Error writing records: %v", err)
+		}
+	}
+
+	// the instructors collection doesn't exist, so it's left out of the export
+	var export bytes.Buffer
+	names := []string{defaultCoursesCollectionName, defaultInstructorsCollectionName, defaultSubjectsCollectionName}
+	if err := exportCollections(ctx, source, names, &export); err != nil {
+		t.Fatalf("Error exporting: %v", err)
+	}
+
+	// the destination can't embed, so the import must load the exported vectors as they are
+	destination, err := newLocalStore(t.TempDir(), noEmbeddingFunction{})
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	if _, err := destination.CreateCollection(ctx, defaultCoursesCollectionName, currentCollectionInfo()); err != nil {
+		t.Fatalf("Error creating collection: %v", err)
+	}
+	if err := importCollections(ctx, destination, bytes.NewReader(export.Bytes())); err != nil {
+		t.Fatalf("Error importing: %v", err)
+	}
+
+	for name, records := range written {
+		collection, err := destination.GetCollection(ctx, name)
+		if err != nil {
+			t.Fatalf("Error getting collection: %v", err)
+		}
+		imported, err := collection.(EmbeddedCollection).GetEmbedded(ctx)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(imported, records) {
+			t.Errorf("Expected %s to be imported as %v, got %v", name, records, imported)
+		}
+		if info, _ := destination.CollectionInfo(ctx, name); info != currentCollectionInfo() {
+			t.Errorf("Expected %s to be recorded as %v, got %v", name, currentCollectionInfo(), info)
+		}
+	}
+	if _, err := destination.GetCollection(ctx, defaultInstructorsCollectionName); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected no instructors collection, got %v", err)
+	}
+}
+
+func TestImportRefusesUnusableExports(t *testing.T) {
+	record := `{"type":"record","collection":"subjects","id":"Ethics","document":"Ethics","embedding":[0.1,0.2]}`
+	tests := []struct {
+		name   string
+		export string
+		// whether the error is a SchemaMismatchError rather than a RowError
+		mismatch bool
+	}{
+		{"different model", `{"type":"collection","name":"subjects","schemaVersion":2,"embeddingModel":"text-embedding-3-large","dimension":3072}`, true},
+		{"newer schema", `{"type":"collection","name":"subjects","schemaVersion":99,"embeddingModel":"text-embedding-ada-002","dimension":1536}`, true},
+		{"wrong dimension", `{"type":"collection","name":"subjects","schemaVersion":2,"embeddingModel":"text-embedding-ada-002","dimension":1536}` + "\n" + record, false},
+		{"record before its collection", record, false},
+		{"not JSON", "subjects,Ethics", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			store, err := newLocalStore(t.TempDir(), noEmbeddingFunction{})
+			if err != nil {
+				t.Fatalf("Error opening store: %v", err)
+			}
+			err = importCollections(context.Background(), store, strings.NewReader(test.export))
+			var mismatch *SchemaMismatchError
+			var rowErr *RowError
+			if test.mismatch && !errors.As(err, &mismatch) {
+				t.Errorf("Expected a SchemaMismatchError, got %v", err)
+			}
+			if !test.mismatch && !errors.As(err, &rowErr) {
+				t.Errorf("Expected a RowError, got %v", err)
+			}
+		})
+	}
+}
+
+func TestRebuildIndexesFromStore(t *testing.T) {
+	ctx := context.Background()
+	store := openTestLocalStore(t, t.TempDir())
+	collection, err := store.CreateCollection(ctx, defaultCoursesCollectionName, currentCollectionInfo())
+	if err != nil {
+		t.Fatalf("Error creating collection: %v", err)
+	}
+	courses := []Course{
+		{Subject: "CS", CourseNumber: "315", CRN: "40649", Title: "Computer Architecture", Prerequisites: "CS 245 and CS 221"},
+		{Subject: "PHIL", CourseNumber: "240", CRN: "41182", Title: "Ethics"},
+	}
+	var ids, documents []string
+	for _, course := range courses {
+		document, err := json.Marshal(course)
+		if err != nil {
+			t.Fatalf("Error marshaling course: %v", err)
+		}
+		ids = append(ids, course.CRN)
+		documents = append(documents, string(document))
+	}
+	if err := collection.Add(ctx, ids, documents, nil); err != nil {
+		t.Fatalf("Error adding records: %v", err)
+	}
+
+	// indexes left over from another schedule are replaced
+	dir := t.TempDir()
+	lexicalPath, prereqPath := filepath.Join(dir, "lexical-index.json"), filepath.Join(dir, "prereq-graph.json")
+	if err := BuildLexicalIndex([]Course{{CRN: "99999", Title: "Skateboarding"}}).Save(lexicalPath); err != nil {
+		t.Fatalf("Error saving index: %v", err)
+	}
+	if err := rebuildIndexesFromStore(ctx, store, lexicalPath, prereqPath); err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+
+	idx, err := LoadLexicalIndex(lexicalPath)
+	if err != nil {
+		t.Fatalf("Error loading index: %v", err)
+	}
+	if hits := idx.Search("skateboarding", 10); len(hits) != 0 {
+		t.Errorf("Expected the old courses to be gone, got %v", hits)
+	}
+	if hits := idx.Search("ethics", 10); len(hits) != 1 || hits[0].ID != "41182" {
+		t.Errorf("Expected the imported Ethics course, got %v", hits)
+	}
+	graph, err := LoadPrereqGraph(prereqPath)
+	if err != nil {
+		t.Fatalf("Error loading graph: %v", err)
+	}
+	if _, exists := graph.Prerequisites["CS 315"]; !exists || len(graph.Prerequisites) != 1 {
+		t.Errorf("Expected the prerequisites of CS 315, got %v", graph.Prerequisites)
+	}
+}
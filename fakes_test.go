@@ -0,0 +1,216 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"sort"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// fakeCollection is an in-memory VectorCollection. Similarity is the cosine of the documents' term
+// counts, so queries are deterministic and need no embedding model.
+type fakeCollection struct {
+	ids       []string
+	documents map[string]string
+	metadatas map[string]map[string]interface{}
+}
+
+func newFakeCollection() *fakeCollection {
+	return &fakeCollection{
+		documents: make(map[string]string),
+		metadatas: make(map[string]map[string]interface{}),
+	}
+}
+
+// like Chroma, adding an existing ID keeps the record that is already there
+func (c *fakeCollection) Add(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error {
+	return c.write(ids, documents, metadatas, false)
+}
+
+func (c *fakeCollection) Upsert(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error {
+	return c.write(ids, documents, metadatas, true)
+}
+
+func (c *fakeCollection) write(ids, documents []string, metadatas []map[string]interface{}, replace bool) error {
+	if len(ids) != len(documents) || (metadatas != nil && len(metadatas) != len(ids)) {
+		return fmt.Errorf("ids, documents and metadatas must have the same length")
+	}
+	for i, id := range ids {
+		if _, exists := c.documents[id]; exists {
+			if !replace {
+				continue
+			}
+		} else {
+			c.ids = append(c.ids, id)
+		}
+		c.documents[id] = documents[i]
+		c.metadatas[id] = nil
+		if metadatas != nil {
+			c.metadatas[id] = metadatas[i]
+		}
+	}
+	return nil
+}
+
+func (c *fakeCollection) Query(ctx context.Context, text string, nResults int, where map[string]interface{}) (CollectionResults, error) {
+	type scored struct {
+		id       string
+		distance float32
+	}
+	var candidates []scored
+	for _, id := range c.ids {
+		if where != nil && !matchesWhere(c.metadatas[id], where) {
+			continue
+		}
+		candidates = append(candidates, scored{id, float32(1 - termCosine(text, c.documents[id]))})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	if len(candidates) > nResults {
+		candidates = candidates[:nResults]
+	}
+
+	var results CollectionResults
+	for _, candidate := range candidates {
+		results.IDs = append(results.IDs, candidate.id)
+		results.Documents = append(results.Documents, c.documents[candidate.id])
+		results.Metadatas = append(results.Metadatas, c.metadatas[candidate.id])
+		results.Distances = append(results.Distances, candidate.distance)
+	}
+	return results, nil
+}
+
+func (c *fakeCollection) Get(ctx context.Context, ids []string, where map[string]interface{}) (CollectionResults, error) {
+	if len(ids) == 0 {
+		ids = c.ids
+	}
+	var results CollectionResults
+	for _, id := range ids {
+		document, exists := c.documents[id]
+		if !exists || (where != nil && !matchesWhere(c.metadatas[id], where)) {
+			continue
+		}
+		results.IDs = append(results.IDs, id)
+		results.Documents = append(results.Documents, document)
+		results.Metadatas = append(results.Metadatas, c.metadatas[id])
+	}
+	return results, nil
+}
+
+// the cosine similarity of the term counts of two texts
+func termCosine(a, b string) float64 {
+	counts := func(text string) map[string]float64 {
+		m := make(map[string]float64)
+		for _, term := range tokenize(text) {
+			m[term]++
+		}
+		return m
+	}
+	x, y := counts(a), counts(b)
+	var dot, nx, ny float64
+	for term, n := range x {
+		dot += n * y[term]
+		nx += n * n
+	}
+	for _, n := range y {
+		ny += n * n
+	}
+	if nx == 0 || ny == 0 {
+		return 0
+	}
+	return dot / math.Sqrt(nx*ny)
+}
+
+// a database backed by fake collections holding the courses, ingested the same way as the schedule
+func newFakeDb(t *testing.T, courses []Course) *Db {
+	t.Helper()
+	db := &Db{
+		ctx:                       context.Background(),
+		coursesCollection:         newFakeCollection(),
+		coursesCollectionName:     "usf-courses",
+		instructorsCollection:     newFakeCollection(),
+		instructorsCollectionName: "instructors",
+		subjectsCollection:        newFakeCollection(),
+		subjectsCollectionName:    "subjects",
+		courseCodesCollection:     newFakeCollection(),
+		courseCodesCollectionName: "course-codes",
+		departments:               fakeDepartments,
+		lexicalIndex:              BuildLexicalIndex(courses),
+		prereqGraph:               newPrereqGraph(),
+		stateDir:                  t.TempDir(),
+	}
+
+	docs, err := buildCollectionDocuments(courses, db.departments)
+	if err != nil {
+		t.Fatalf("Error building documents: %v", err)
+	}
+	report := newIngestReport()
+	for _, write := range []struct {
+		name       string
+		collection VectorCollection
+		docs       collectionDocuments
+	}{
+		{db.coursesCollectionName, db.coursesCollection, docs.courses},
+		{db.instructorsCollectionName, db.instructorsCollection, docs.instructors},
+		{db.subjectsCollectionName, db.subjectsCollection, docs.subjects},
+		{db.courseCodesCollectionName, db.courseCodesCollection, docs.courseCodes},
+	} {
+		if err := db.addDocuments(write.name, write.collection, write.docs, report); err != nil {
+			t.Fatalf("Error adding documents: %v", err)
+		}
+	}
+	return db
+}
+
+// a few sections from the Fall 2024 schedule
+var fakeCourses = []Course{
+	{Subject: "CS", CourseNumber: "272", Section: "03", CRN: "40646", Title: "Software Development", MeetDays: "TR", BeginTime: "1440", EndTime: "1625", Building: "LS", Room: "G12", InstructorFirstName: "Philip", InstructorLastName: "Peterson", InstructorEmail: "phpeterson@usfca.edu", College: "SC"},
+	{Subject: "CS", CourseNumber: "272L", Section: "02", CRN: "42344", Title: "Software Development Lab", MeetDays: "W", BeginTime: "1455", EndTime: "1625", Building: "MH", Room: "122", InstructorFirstName: "Philip", InstructorLastName: "Peterson", InstructorEmail: "phpeterson@usfca.edu", College: "SC"},
+	{Subject: "CS", CourseNumber: "315", Section: "02", CRN: "40649", Title: "Computer Architecture", MeetDays: "TR", BeginTime: "1440", EndTime: "1625", Building: "LS", Room: "307", InstructorFirstName: "Gregory", InstructorLastName: "Benson", InstructorEmail: "benson@usfca.edu", College: "SC"},
+	{Subject: "CS", CourseNumber: "315L", Section: "01", CRN: "42345", Title: "Laboratory", MeetDays: "W", BeginTime: "1645", EndTime: "1815", Building: "LS", Room: "307", InstructorFirstName: "Gregory", InstructorLastName: "Benson", InstructorEmail: "benson@usfca.edu", College: "SC"},
+	{Subject: "BIOL", CourseNumber: "422", Section: "01", CRN: "40519", Title: "Bioinformatics", MeetDays: "MW", BeginTime: "0900", EndTime: "1015", Building: "KA", Room: "311", InstructorFirstName: "Naupaka", InstructorLastName: "Zimmerman", InstructorEmail: "nzimmerman@usfca.edu", College: "SC"},
+	{Subject: "PHIL", CourseNumber: "110", Section: "05", CRN: "41167", Title: "Great Philosophical Questions", MeetDays: "TR", BeginTime: "1635", EndTime: "1820", Building: "KA", Room: "263", InstructorFirstName: "Purushottama", InstructorLastName: "Bilimoria", InstructorEmail: "pbilimoria@usfca.edu", College: "LA"},
+	{Subject: "PHIL", CourseNumber: "240", Section: "02", CRN: "41182", Title: "Ethics", MeetDays: "TR", BeginTime: "1440", EndTime: "1625", Building: "LM", Room: "363", InstructorFirstName: "Joshua", InstructorLastName: "Carboni", InstructorEmail: "jcarboni1@usfca.edu", College: "LA"},
+}
+
+// the departments of the subjects of fakeCourses
+var fakeDepartments = map[string]Department{
+	"CS":   {Code: "CS", Name: "Computer Science", Aliases: []string{"CompSci"}},
+	"BIOL": {Code: "BIOL", Name: "Biology"},
+	"PHIL": {Code: "PHIL", Name: "Philosophy"},
+}
+
+// fakeChat plays back a scripted sequence of model messages and records every request it was sent
+type fakeChat struct {
+	script   []openai.ChatCompletionMessage
+	requests []openai.ChatCompletionRequest
+}
+
+func (c *fakeChat) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	c.requests = append(c.requests, request)
+	if len(c.requests) > len(c.script) {
+		return openai.ChatCompletionResponse{}, fmt.Errorf("fake chat: unexpected request %d", len(c.requests))
+	}
+	return openai.ChatCompletionResponse{
+		Choices: []openai.ChatCompletionChoice{{Message: c.script[len(c.requests)-1]}},
+	}, nil
+}
+
+// a scripted assistant message calling a single tool
+func toolCallMessage(id, name, arguments string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{
+		Role: openai.ChatMessageRoleAssistant,
+		ToolCalls: []openai.ToolCall{{
+			ID:       id,
+			Type:     openai.ToolTypeFunction,
+			Function: openai.FunctionCall{Name: name, Arguments: arguments},
+		}},
+	}
+}
+
+// a scripted assistant message answering the user
+func answerMessage(content string) openai.ChatCompletionMessage {
+	return openai.ChatCompletionMessage{Role: openai.ChatMessageRoleAssistant, Content: content}
+}
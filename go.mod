@@ -4,13 +4,22 @@ go 1.23.0
 
 require (
 	github.com/amikos-tech/chroma-go v0.1.5-0.20241103135957-1b1e6ef18500
+	github.com/jackc/pgx/v5 v5.7.6
 	github.com/joho/godotenv v1.5.1
 	github.com/sashabaranov/go-openai v1.32.5
+	go.etcd.io/bbolt v1.4.3
 )
 
 require (
 	github.com/Masterminds/semver v1.5.0 // indirect
 	github.com/google/uuid v1.6.0 // indirect
+	github.com/jackc/pgpassfile v1.0.0 // indirect
+	github.com/jackc/pgservicefile v0.0.0-20240606120523-5a60cdf6a761 // indirect
+	github.com/jackc/puddle/v2 v2.2.2 // indirect
 	github.com/oklog/ulid v1.3.1 // indirect
 	github.com/yalue/onnxruntime_go v1.11.0 // indirect
+	golang.org/x/crypto v0.37.0 // indirect
+	golang.org/x/sync v0.13.0 // indirect
+	golang.org/x/sys v0.32.0 // indirect
+	golang.org/x/text v0.24.0 // indirect
 )
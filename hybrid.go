@@ -0,0 +1,145 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+)
+
+// the constant used by reciprocal-rank fusion to dampen the weight of top ranks
+const rrfK = 60
+
+// the number of candidates each retriever contributes before fusion; the fused candidates are
+// what gets sorted and paged
+const candidatePoolSize = 200
+
+// merges several ranked lists of course IDs into one, scoring every ID by the sum of 1/(k+rank)
+func reciprocalRankFusion(rankings ...[]string) []string {
+	scores := make(map[string]float64)
+	firstSeen := make(map[string]int)
+	order := 0
+	for _, ranking := range rankings {
+		for rank, id := range ranking {
+			scores[id] += 1.0 / float64(rrfK+rank+1)
+			if _, seen := firstSeen[id]; !seen {
+				firstSeen[id] = order
+				order++
+			}
+		}
+	}
+
+	fused := make([]string, 0, len(scores))
+	for id := range scores {
+		fused = append(fused, id)
+	}
+	sort.Slice(fused, func(i, j int) bool {
+		if scores[fused[i]] != scores[fused[j]] {
+			return scores[fused[i]] > scores[fused[j]]
+		}
+		return firstSeen[fused[i]] < firstSeen[fused[j]]
+	})
+	return fused
+}
+
+// runs a vector query and a BM25 query on the user's phrasing, both restricted to the courses
+// matching the where filter, then fuses the rankings. Without any phrasing every course matching
+// the filter is a candidate. Returns the fused course IDs, the documents that were fetched along
+// the way, and whether the IDs are known to be every match rather than a capped pool.
+func hybridSearch(db *Db, whereFilter map[string]interface{}, queryText string) ([]string, map[string]string, bool, error) {
+	documents := make(map[string]string)
+	var rankings [][]string
+	exact := true
+
+	// collects the IDs of a query in rank order, remembering their documents
+	collect := func(ids []string, docs []string) []string {
+		var ranking []string
+		for i, id := range ids {
+			ranking = append(ranking, id)
+			if i < len(docs) {
+				documents[id] = docs[i]
+			}
+		}
+		if len(ranking) >= candidatePoolSize {
+			exact = false
+		}
+		return ranking
+	}
+
+	var filter map[string]interface{}
+	if hasFilterConditions(whereFilter) {
+		filter = whereFilter
+	}
+
+	if queryText == "" {
+		if filter != nil {
+			results, err := db.coursesCollection.Get(db.ctx, nil, filter)
+			if err != nil {
+				return nil, nil, false, fmt.Errorf("error running filtered query: %w", err)
+			}
+			rankings = append(rankings, collect(results.IDs, results.Documents))
+		}
+		return reciprocalRankFusion(rankings...), documents, exact, nil
+	}
+
+	results, err := db.coursesCollection.Query(db.ctx, queryText, candidatePoolSize, filter)
+	if err != nil {
+		return nil, nil, false, fmt.Errorf("error running vector query: %w", err)
+	}
+	rankings = append(rankings, collect(results.IDs, results.Documents))
+
+	var lexicalIDs []string
+	for _, hit := range db.lexicalIndex.Search(queryText, candidatePoolSize) {
+		lexicalIDs = append(lexicalIDs, hit.ID)
+	}
+
+	// the lexical index only knows IDs, so fetch the documents and metadata the vector query
+	// didn't return, and drop the hits that don't match the filter
+	var missing []string
+	for _, id := range lexicalIDs {
+		if _, exists := documents[id]; !exists {
+			missing = append(missing, id)
+		}
+	}
+	rejected := make(map[string]bool)
+	if len(missing) > 0 {
+		results, err := db.coursesCollection.Get(db.ctx, missing, nil)
+		if err != nil {
+			return nil, nil, false, fmt.Errorf("error fetching lexical matches: %w", err)
+		}
+		fetched := make(map[string]bool, len(results.IDs))
+		for i, id := range results.IDs {
+			fetched[id] = true
+			if filter != nil && !matchesWhere(metadataAt(results, i), filter) {
+				rejected[id] = true
+				continue
+			}
+			if i < len(results.Documents) {
+				documents[id] = results.Documents[i]
+			}
+		}
+		// hits the store no longer has
+		for _, id := range missing {
+			if !fetched[id] {
+				rejected[id] = true
+			}
+		}
+	}
+
+	var lexicalRanking []string
+	for _, id := range lexicalIDs {
+		if !rejected[id] {
+			lexicalRanking = append(lexicalRanking, id)
+		}
+	}
+	rankings = append(rankings, collect(lexicalRanking, nil))
+
+	return reciprocalRankFusion(rankings...), documents, exact, nil
+}
+
+// reports whether the where filter built from the tool call has any conditions to apply
+func hasFilterConditions(whereFilter map[string]interface{}) bool {
+	switch conditions := whereFilter["$or"].(type) {
+	case []map[string]interface{}:
+		return len(conditions) > 0
+	}
+	return len(whereFilter) > 0
+}
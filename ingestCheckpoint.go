@@ -0,0 +1,137 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"sync"
+)
+
+// the file in the store's directory where an ingestion records the batches it has written until it
+// finishes
+const ingestCheckpointFile = "ingest-checkpoint.json"
+
+// ingestCheckpoint records which batches of an ingestion have been written, so that one that
+// stopped part way can carry on after them instead of starting over
+type ingestCheckpoint struct {
+	// the input the batches were built from, since batches of a different input can't be skipped
+	Fingerprint string `json:"fingerprint"`
+	// the indexes of the batches written to each collection
+	Written map[string][]int `json:"written"`
+
+	path string
+	mu   sync.Mutex
+}
+
+// a fingerprint of the store an ingestion writes to, the files it reads and how it splits them
+// into batches, since batches written to another store can't be skipped. Paths that are empty are
+// left out.
+func ingestFingerprint(store string, paths ...string) (string, error) {
+	hash := sha256.New()
+	fmt.Fprintf(hash, "schema %d, batches of %d, store %s\n", collectionSchemaVersion, ingestBatchSize, store)
+	for _, path := range paths {
+		if path == "" {
+			continue
+		}
+		file, err := os.Open(path)
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\n", path)
+		_, err = io.Copy(hash, file)
+		file.Close()
+		if err != nil {
+			return "", err
+		}
+	}
+	return hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// reads the checkpoint at path, returning whether it was left by an ingestion of the same input.
+// Otherwise the checkpoint is empty, ready for a new ingestion.
+func openIngestCheckpoint(path, fingerprint string) (*ingestCheckpoint, bool, error) {
+	checkpoint := &ingestCheckpoint{path: path}
+	data, err := os.ReadFile(path)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, false, err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, checkpoint); err != nil {
+			return nil, false, fmt.Errorf("reading checkpoint %s: %w", path, err)
+		}
+	}
+	if checkpoint.Fingerprint == fingerprint && len(checkpoint.Written) > 0 {
+		return checkpoint, true, nil
+	}
+	checkpoint.Fingerprint = fingerprint
+	checkpoint.Written = map[string][]int{}
+	return checkpoint, false, nil
+}
+
+// whether the batch of the collection has been written
+func (c *ingestCheckpoint) written(collection string, batch int) bool {
+	if c == nil {
+		return false
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, written := range c.Written[collection] {
+		if written == batch {
+			return true
+		}
+	}
+	return false
+}
+
+// the number of batches written to every collection
+func (c *ingestCheckpoint) count() int {
+	if c == nil {
+		return 0
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	count := 0
+	for _, batches := range c.Written {
+		count += len(batches)
+	}
+	return count
+}
+
+// records that the batch of the collection has been written, saving the checkpoint before returning
+func (c *ingestCheckpoint) markWritten(collection string, batch int) error {
+	if c == nil {
+		return nil
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.Written[collection] = append(c.Written[collection], batch)
+	sort.Ints(c.Written[collection])
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return err
+	}
+	// replaced in one step, so that a crash while saving leaves the previous checkpoint
+	temp := c.path + ".tmp"
+	if err := os.WriteFile(temp, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(temp, c.path)
+}
+
+// deletes the checkpoint once the ingestion it records has finished
+func (c *ingestCheckpoint) remove() error {
+	if c == nil {
+		return nil
+	}
+	err := os.Remove(c.path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	return err
+}
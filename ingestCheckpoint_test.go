@@ -0,0 +1,95 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestWriteInBatchesResumesFromCheckpoint(t *testing.T) {
+	// six batches, the last one short
+	var docs collectionDocuments
+	for i := 0; i < 2800; i++ {
+		docs.ids = append(docs.ids, fmt.Sprint(i))
+		docs.documents = append(docs.documents, fmt.Sprint("course ", i))
+	}
+
+	for _, workers := range []int{1, 3} {
+		t.Run(fmt.Sprintf("%d workers", workers), func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "checkpoint.json")
+			var mu sync.Mutex
+			var writes []string
+			write := func(crash bool) func(ids, documents []string, metadatas []map[string]interface{}) error {
+				return func(ids, documents []string, metadatas []map[string]interface{}) error {
+					if crash && ids[0] == "1500" {
+						return errors.New("connection reset")
+					}
+					mu.Lock()
+					defer mu.Unlock()
+					writes = append(writes, ids[0])
+					return nil
+				}
+			}
+
+			// the first ingestion stops at batch 4 of 6
+			checkpoint, resumable, err := openIngestCheckpoint(path, "schedule")
+			if err != nil || resumable {
+				t.Fatalf("Expected a new checkpoint, got resumable %v and %v", resumable, err)
+			}
+			db := &Db{ctx: context.Background(), ingest: ingestSettings{workers: workers, checkpoint: checkpoint}}
+			if _, failed := db.writeInBatches("usf-courses", docs, false, write(true)); len(failed) != 1 || failed[0].Start != 1500 {
+				t.Fatalf("Expected batch 4 to fail, got %v", failed)
+			}
+
+			// the next one with the same input carries on after the batches that were written
+			checkpoint, resumable, err = openIngestCheckpoint(path, "schedule")
+			if err != nil || !resumable {
+				t.Fatalf("Expected to resume, got resumable %v and %v", resumable, err)
+			}
+			db.ingest.checkpoint = checkpoint
+			written, failed := db.writeInBatches("usf-courses", docs, false, write(false))
+			if written != len(docs.ids) || len(failed) != 0 {
+				t.Errorf("Expected all %d records written, got %d and failed batches %v", len(docs.ids), written, failed)
+			}
+
+			seen := map[string]bool{}
+			for _, start := range writes {
+				if seen[start] {
+					t.Errorf("Expected the batch starting at %s to be written once, got %v", start, writes)
+				}
+				seen[start] = true
+			}
+			if len(seen) != 6 {
+				t.Errorf("Expected all 6 batches to be written, got %v", writes)
+			}
+
+			// a checkpoint of another input starts over
+			if _, resumable, _ := openIngestCheckpoint(path, "another schedule"); resumable {
+				t.Errorf("Expected a checkpoint of another input not to be resumed")
+			}
+		})
+	}
+}
+
+func TestIngestFingerprintCoversTheStore(t *testing.T) {
+	schedule := filepath.Join("testdata", "catalog.csv")
+	fingerprint := func(store string) string {
+		t.Helper()
+		f, err := ingestFingerprint(store, schedule)
+		if err != nil {
+			t.Fatalf("Error fingerprinting: %v", err)
+		}
+		return f
+	}
+
+	a, b := fingerprint("/data/store-a"), fingerprint("/data/store-b")
+	if a == b {
+		t.Errorf("Expected ingestions into different stores to have different fingerprints")
+	}
+	if a != fingerprint("/data/store-a") {
+		t.Errorf("Expected the same store and input to have the same fingerprint")
+	}
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// IngestReport is what an ingestion wrote to each collection and what it had to skip along the way
+type IngestReport struct {
+	Written       map[string]int
+	SkippedRows   []RowError
+	FailedBatches []BatchError
+}
+
+func newIngestReport() *IngestReport {
+	return &IngestReport{Written: make(map[string]int)}
+}
+
+// adds the outcome of writing to a collection, returning the first failed batch unless failed
+// batches are being skipped
+func (r *IngestReport) record(collection string, written int, failed []BatchError, skipFailed bool) error {
+	r.Written[collection] += written
+	for i := range failed {
+		failed[i].Collection = collection
+	}
+	r.FailedBatches = append(r.FailedBatches, failed...)
+	if !skipFailed && len(failed) > 0 {
+		return &failed[0]
+	}
+	return nil
+}
+
+// whether anything was skipped
+func (r *IngestReport) HasProblems() bool {
+	return r != nil && (len(r.SkippedRows) > 0 || len(r.FailedBatches) > 0)
+}
+
+// lists the skipped rows and failed batches, or nothing if the ingestion had no problems
+func (r *IngestReport) String() string {
+	if !r.HasProblems() {
+		return ""
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "\nIngestion finished with %d skipped rows and %d failed batches.\n", len(r.SkippedRows), len(r.FailedBatches))
+	for _, row := range r.SkippedRows {
+		fmt.Fprintf(&b, "  skipped %s\n", row.Error())
+	}
+	for _, batch := range r.FailedBatches {
+		fmt.Fprintf(&b, "  failed %s\n", batch.Error())
+	}
+
+	collections := make([]string, 0, len(r.Written))
+	for collection := range r.Written {
+		collections = append(collections, collection)
+	}
+	sort.Strings(collections)
+	for _, collection := range collections {
+		fmt.Fprintf(&b, "  wrote %d records to %s\n", r.Written[collection], collection)
+	}
+	return b.String()
+}
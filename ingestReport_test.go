@@ -0,0 +1,113 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestReadCourseRowsSkipsBadRows(t *testing.T) {
+	header := "SUBJ,CRSE NUM,SEC,CRN,Schedule Type Code,Campus Code,Title Short Desc,Instruction Mode Desc,Meeting Type Codes,Meet Days,Begin Time,End Time,Meet Start,Meet End,BLDG,RM,Actual Enrollment,Primary Instructor First Name,Primary Instructor Last Name,Primary Instructor Email,College"
+	rows := []string{
+		header,
+		"CS,272,03,40646,LEC,M,Software Development,In-Person,IP,TR,1440,1625,8/20/24,12/4/24,LS,G12,40,Philip,Peterson,phpeterson@usfca.edu,SC",
+		"CS,315,02,40649,LEC,M",
+		// a second meeting of the first section
+		"CS,272,03,40646,LEC,M,Software Development,In-Person,IP,F,0800,0905,8/20/24,12/4/24,HR,235,40,Philip,Peterson,phpeterson@usfca.edu,SC",
+		`CS,315L,01,42345,LAB,M,"Laboratory,In-Person,IP,W,1645,1815,8/20/24,12/4/24,LS,307,20,Gregory,Benson,benson@usfca.edu,SC`,
+	}
+	path := filepath.Join(t.TempDir(), "schedule.csv")
+	if err := os.WriteFile(path, []byte(strings.Join(rows, "\n")+"\n"), 0644); err != nil {
+		t.Fatalf("Error writing CSV: %v", err)
+	}
+
+	courses, skipped, err := readCourseRows(path)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if len(courses) != 1 || courses[0].CRN != "40646" || courses[0].MeetDays != "TR" {
+		t.Errorf("Expected only the first meeting of CRN 40646, got %+v", courses)
+	}
+	lines := []int{}
+	for _, row := range skipped {
+		lines = append(lines, row.Line)
+	}
+	if !reflect.DeepEqual(lines, []int{3, 4, 5}) {
+		t.Errorf("Expected rows 3, 4 and 5 to be skipped, got %v", skipped)
+	}
+	if reason := skipped[1].Reason; !strings.Contains(reason, "CRN 40646 is also on row 2") {
+		t.Errorf("Expected the repeated CRN to be reported, got %q", reason)
+	}
+}
+
+// failingCollection fails every write that includes one of its IDs
+type failingCollection struct {
+	*fakeCollection
+	failOn map[string]bool
+}
+
+func (c *failingCollection) Add(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error {
+	for _, id := range ids {
+		if c.failOn[id] {
+			return &StoreUnavailableError{Op: "adding records", Err: errors.New("connection refused")}
+		}
+	}
+	return c.fakeCollection.Add(ctx, ids, documents, metadatas)
+}
+
+func TestAddDocumentsPartialIngest(t *testing.T) {
+	var docs collectionDocuments
+	for i := 0; i < 1200; i++ {
+		docs.ids = append(docs.ids, fmt.Sprint(i))
+		docs.documents = append(docs.documents, fmt.Sprint("course ", i))
+	}
+
+	tests := []struct {
+		name          string
+		partial       bool
+		expectErr     bool
+		expectWritten int
+	}{
+		{name: "a failed batch stops the ingestion", expectErr: true, expectWritten: 500},
+		{name: "a failed batch is skipped", partial: true, expectWritten: 700},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			db := &Db{ctx: context.Background(), partialIngest: test.partial}
+			collection := &failingCollection{newFakeCollection(), map[string]bool{"600": true}}
+			report := newIngestReport()
+
+			err := db.addDocuments("usf-courses", collection, docs, report)
+			var batchErr *BatchError
+			if test.expectErr != errors.As(err, &batchErr) {
+				t.Fatalf("Expected a batch error: %v, got %v", test.expectErr, err)
+			}
+			var unavailable *StoreUnavailableError
+			if test.expectErr && !errors.As(err, &unavailable) {
+				t.Errorf("Expected the batch error to wrap the store error, got %v", err)
+			}
+			if report.Written["usf-courses"] != test.expectWritten || len(collection.ids) != test.expectWritten {
+				t.Errorf("Expected %d records written, got %d in the report and %d in the collection",
+					test.expectWritten, report.Written["usf-courses"], len(collection.ids))
+			}
+
+			if len(report.FailedBatches) != 1 || report.FailedBatches[0].Start != 500 || report.FailedBatches[0].End != 1000 {
+				t.Errorf("Expected records 500-999 to fail, got %+v", report.FailedBatches)
+			}
+			if !strings.Contains(report.String(), "writing records 500-999 to usf-courses") {
+				t.Errorf("Expected the report to list the failed batch, got %q", report.String())
+			}
+		})
+	}
+
+	db := &Db{ctx: context.Background()}
+	var missing *CollectionMissingError
+	if err := db.addDocuments("subjects", nil, docs, newIngestReport()); !errors.As(err, &missing) {
+		t.Errorf("Expected a missing collection error, got %v", err)
+	}
+}
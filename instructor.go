@@ -0,0 +1,289 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// InstructorProfile is an instructor of the schedule, told apart by their email so that two people
+// with the same name stay separate, with what they teach aggregated over their sections
+type InstructorProfile struct {
+	// the lowercase email, or the full name of an instructor without one
+	Key   string `json:"key"`
+	Email string `json:"email,omitempty"`
+	// the name on most of their sections, and every spelling seen on the schedule
+	Name         string   `json:"name"`
+	NameVariants []string `json:"nameVariants"`
+	Colleges     []string `json:"colleges"`
+	Subjects     []string `json:"subjects"`
+	Sections     int      `json:"sections"`
+	Enrollment   int      `json:"enrollment"`
+
+	// only filled in by get_instructor
+	OfficeHours *OfficeHours `json:"officeHours,omitempty"`
+	Schedule    []Course     `json:"schedule,omitempty"`
+}
+
+// OfficeHours are where and when an instructor can be found, from the optional office-hours file
+type OfficeHours struct {
+	Email  string `json:"email"`
+	Office string `json:"office,omitempty"`
+	Hours  string `json:"hours,omitempty"`
+	Notes  string `json:"notes,omitempty"`
+}
+
+// the key of the instructor of a section; "" for sections with none assigned
+func instructorKey(course Course) string {
+	if email := strings.ToLower(strings.TrimSpace(course.InstructorEmail)); email != "" {
+		return email
+	}
+	return course.instructorFullName()
+}
+
+// aggregates the sections of every instructor into their profile, ordered by key. Sections listed
+// more than once under the same CRN are counted once.
+func buildInstructorProfiles(courses []Course) []InstructorProfile {
+	profiles := make(map[string]*InstructorProfile)
+	nameCounts := make(map[string]map[string]int)
+	colleges := make(map[string]map[string]bool)
+	subjects := make(map[string]map[string]bool)
+	crns := make(map[string]map[string]bool)
+
+	for _, course := range courses {
+		key := instructorKey(course)
+		if key == "" {
+			continue
+		}
+		profile, exists := profiles[key]
+		if !exists {
+			profile = &InstructorProfile{Key: key, Email: strings.TrimSpace(course.InstructorEmail)}
+			profiles[key] = profile
+			nameCounts[key] = make(map[string]int)
+			colleges[key] = make(map[string]bool)
+			subjects[key] = make(map[string]bool)
+			crns[key] = make(map[string]bool)
+		}
+		if crns[key][course.CRN] {
+			continue
+		}
+		crns[key][course.CRN] = true
+
+		if name := course.instructorFullName(); name != "" {
+			if nameCounts[key][name] == 0 {
+				profile.NameVariants = append(profile.NameVariants, name)
+			}
+			nameCounts[key][name]++
+		}
+		if college := strings.TrimSpace(course.College); college != "" {
+			colleges[key][college] = true
+		}
+		if subject := strings.TrimSpace(course.Subject); subject != "" {
+			subjects[key][subject] = true
+		}
+		profile.Sections++
+		// sections without an enrollment count as empty
+		if enrollment := leadingNumber(course.ActualEnrollment); enrollment > 0 {
+			profile.Enrollment += enrollment
+		}
+	}
+
+	keys := make([]string, 0, len(profiles))
+	for key := range profiles {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	result := make([]InstructorProfile, 0, len(keys))
+	for _, key := range keys {
+		profile := profiles[key]
+		// the most common spelling, or the first one seen when there is a tie
+		for _, name := range profile.NameVariants {
+			if nameCounts[key][name] > nameCounts[key][profile.Name] {
+				profile.Name = name
+			}
+		}
+		profile.Colleges = sortedSet(colleges[key])
+		profile.Subjects = sortedSet(subjects[key])
+		result = append(result, *profile)
+	}
+	return result
+}
+
+func sortedSet(set map[string]bool) []string {
+	keys := make([]string, 0, len(set))
+	for key := range set {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// the metadata stored alongside each instructor's name. Lists are joined into strings since
+// metadata values can't be lists.
+func instructorMetadata(profile InstructorProfile) map[string]interface{} {
+	return map[string]interface{}{
+		"Email":        profile.Email,
+		"Name":         profile.Name,
+		"NameVariants": strings.Join(profile.NameVariants, "; "),
+		"Colleges":     strings.Join(profile.Colleges, ", "),
+		"Subjects":     strings.Join(profile.Subjects, ", "),
+		"Sections":     profile.Sections,
+		"Enrollment":   profile.Enrollment,
+	}
+}
+
+// reads a profile back from an instructors record. Records written before instructors were keyed
+// by email only have their name.
+func instructorFromRecord(id, document string, metadata map[string]interface{}) InstructorProfile {
+	profile := InstructorProfile{Key: id, Name: document}
+	text := func(key string) string {
+		value, _ := metadata[key].(string)
+		return value
+	}
+	profile.Email = text("Email")
+	if name := text("Name"); name != "" {
+		profile.Name = name
+	}
+	profile.NameVariants = metadataList(metadata, "NameVariants", ";")
+	if len(profile.NameVariants) == 0 {
+		profile.NameVariants = []string{profile.Name}
+	}
+	profile.Colleges = metadataList(metadata, "Colleges", ",")
+	profile.Subjects = metadataList(metadata, "Subjects", ",")
+	profile.Sections = metadataInt(metadata["Sections"])
+	profile.Enrollment = metadataInt(metadata["Enrollment"])
+	return profile
+}
+
+// a number stored in metadata, which comes back as a float64 from stores that keep it as JSON
+func metadataInt(value interface{}) int {
+	switch n := value.(type) {
+	case int:
+		return n
+	case int64:
+		return int(n)
+	case float64:
+		return int(n)
+	case float32:
+		return int(n)
+	case json.Number:
+		i, _ := n.Int64()
+		return int(i)
+	case string:
+		i, _ := strconv.Atoi(n)
+		return i
+	}
+	return 0
+}
+
+// reads an office-hours file: a JSON list of {"email", "office", "hours", "notes"}, keyed by the
+// lowercase email
+func loadOfficeHours(filePath string) (map[string]OfficeHours, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open office-hours file: %w", err)
+	}
+
+	var entries []OfficeHours
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal office-hours file: %w", err)
+	}
+
+	officeHours := make(map[string]OfficeHours, len(entries))
+	for _, entry := range entries {
+		entry.Email = strings.TrimSpace(entry.Email)
+		if !isEmailAddress(entry.Email) {
+			return nil, fmt.Errorf("invalid email '%s' in office-hours file", entry.Email)
+		}
+		officeHours[strings.ToLower(entry.Email)] = entry
+	}
+	return officeHours, nil
+}
+
+// the instructors whose name is closest to the text: the closest one along with any others who
+// share their name
+func matchInstructors(db *Db, text string) ([]InstructorProfile, error) {
+	results, err := db.instructorsCollection.Query(db.ctx, text, 5, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error querying instructors collection: %w", err)
+	}
+
+	var profiles []InstructorProfile
+	for i, id := range results.IDs {
+		profile := instructorFromRecord(id, results.Documents[i], metadataAt(results, i))
+		// collections ingested before unassigned instructors were left out have the instructor " "
+		if strings.TrimSpace(profile.Name) == "" {
+			continue
+		}
+		if len(profiles) > 0 && !strings.EqualFold(profile.Name, profiles[0].Name) {
+			continue
+		}
+		profiles = append(profiles, profile)
+	}
+	return profiles, nil
+}
+
+// finds the instructors of a get_instructor call and fills in their teaching schedule and office
+// hours. A name shared by several instructors returns every one of them.
+func getInstructor(db *Db, jsonStr string) (string, []Course, error) {
+	args, err := parseInstructorArgs(jsonStr)
+	if err != nil {
+		return "", nil, err
+	}
+
+	var profiles []InstructorProfile
+	if args.Email != "" {
+		results, err := db.instructorsCollection.Get(db.ctx, []string{strings.ToLower(args.Email)}, nil)
+		if err != nil {
+			return "", nil, fmt.Errorf("error getting instructor: %w", err)
+		}
+		for i, id := range results.IDs {
+			profiles = append(profiles, instructorFromRecord(id, results.Documents[i], metadataAt(results, i)))
+		}
+	} else if profiles, err = matchInstructors(db, args.Name); err != nil {
+		return "", nil, err
+	}
+
+	var sections []Course
+	for i := range profiles {
+		where := map[string]interface{}{"InstructorFullName": profiles[i].Name}
+		if profiles[i].Email != "" {
+			where = map[string]interface{}{"PrimaryInstructorEmail": profiles[i].Email}
+		}
+		schedule, err := getSections(db, where)
+		if err != nil {
+			return "", nil, err
+		}
+		sortCourses(schedule, sortByCourseNumber)
+		profiles[i].Schedule = schedule
+		sections = append(sections, schedule...)
+
+		if hours, exists := db.officeHours[strings.ToLower(profiles[i].Email)]; exists && profiles[i].Email != "" {
+			profiles[i].OfficeHours = &hours
+		}
+	}
+
+	response := map[string]interface{}{
+		"instructors": profiles,
+	}
+	if len(profiles) == 0 {
+		response["message"] = "no instructor matches; they may not be teaching this term"
+	}
+	resultJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal instructor: %w", err)
+	}
+	return string(resultJSON), sections, nil
+}
+
+// the metadata of the i-th record, or nil when the store returned none
+func metadataAt(results CollectionResults, i int) map[string]interface{} {
+	if i < len(results.Metadatas) {
+		return results.Metadatas[i]
+	}
+	return nil
+}
@@ -0,0 +1,106 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestBuildInstructorProfiles(t *testing.T) {
+	courses := []Course{
+		{CRN: "40646", Subject: "CS", College: "SC", ActualEnrollment: "31", InstructorFirstName: "Philip", InstructorLastName: "Peterson", InstructorEmail: "phpeterson@usfca.edu"},
+		{CRN: "42344", Subject: "CS", College: "SC", ActualEnrollment: "18", InstructorFirstName: "Phil", InstructorLastName: "Peterson", InstructorEmail: "PHPeterson@usfca.edu"},
+		{CRN: "41500", Subject: "DS", College: "AS", ActualEnrollment: "25", InstructorFirstName: "Philip", InstructorLastName: "Peterson", InstructorEmail: "phpeterson@usfca.edu"},
+		// the same section listed twice
+		{CRN: "41500", Subject: "DS", College: "AS", ActualEnrollment: "25", InstructorFirstName: "Philip", InstructorLastName: "Peterson", InstructorEmail: "phpeterson@usfca.edu"},
+		// another person with the same name
+		{CRN: "40100", Subject: "MATH", College: "AS", ActualEnrollment: "40", InstructorFirstName: "Philip", InstructorLastName: "Peterson", InstructorEmail: "ppeterson2@usfca.edu"},
+		// no email, and no instructor at all
+		{CRN: "40200", Subject: "ART", College: "AS", InstructorFirstName: "Ana", InstructorLastName: "Ruiz"},
+		{CRN: "40300", Subject: "ART", College: "AS"},
+	}
+
+	expected := []InstructorProfile{
+		{Key: "Ana Ruiz", Name: "Ana Ruiz", NameVariants: []string{"Ana Ruiz"}, Colleges: []string{"AS"}, Subjects: []string{"ART"}, Sections: 1},
+		{Key: "phpeterson@usfca.edu", Email: "phpeterson@usfca.edu", Name: "Philip Peterson", NameVariants: []string{"Philip Peterson", "Phil Peterson"}, Colleges: []string{"AS", "SC"}, Subjects: []string{"CS", "DS"}, Sections: 3, Enrollment: 74},
+		{Key: "ppeterson2@usfca.edu", Email: "ppeterson2@usfca.edu", Name: "Philip Peterson", NameVariants: []string{"Philip Peterson"}, Colleges: []string{"AS"}, Subjects: []string{"MATH"}, Sections: 1, Enrollment: 40},
+	}
+	profiles := buildInstructorProfiles(courses)
+	if !reflect.DeepEqual(profiles, expected) {
+		t.Fatalf("Expected %+v, got %+v", expected, profiles)
+	}
+
+	// the profile survives being stored as metadata, including as JSON
+	data, err := json.Marshal(instructorMetadata(profiles[1]))
+	if err != nil {
+		t.Fatalf("Error marshaling metadata: %v", err)
+	}
+	var metadata map[string]interface{}
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		t.Fatalf("Error unmarshaling metadata: %v", err)
+	}
+	if got := instructorFromRecord(profiles[1].Key, profiles[1].Name, metadata); !reflect.DeepEqual(got, profiles[1]) {
+		t.Errorf("Expected %+v back from the metadata, got %+v", profiles[1], got)
+	}
+}
+
+func TestGetInstructor(t *testing.T) {
+	courses := append([]Course{
+		{Subject: "MATH", CourseNumber: "201", Section: "01", CRN: "40100", Title: "Discrete Mathematics", MeetDays: "MWF", BeginTime: "0900", EndTime: "1005", Building: "HR", Room: "148", InstructorFirstName: "Philip", InstructorLastName: "Peterson", InstructorEmail: "ppeterson2@usfca.edu", College: "AS"},
+	}, fakeCourses...)
+	db := newFakeDb(t, courses)
+
+	path := filepath.Join(t.TempDir(), "office-hours.json")
+	if err := os.WriteFile(path, []byte(`[{"email": "Benson@usfca.edu", "office": "HR 530", "hours": "Tuesdays 1-3 PM"}]`), 0644); err != nil {
+		t.Fatalf("Error writing office hours: %v", err)
+	}
+	officeHours, err := loadOfficeHours(path)
+	if err != nil {
+		t.Fatalf("Error loading office hours: %v", err)
+	}
+	db.officeHours = officeHours
+
+	tests := []struct {
+		name      string
+		args      string
+		expected  []string
+		crns      []string
+		officeHrs string
+	}{
+		{"by email", `{"email": "benson@usfca.edu"}`, []string{"benson@usfca.edu"}, []string{"40649", "42345"}, "Tuesdays 1-3 PM"},
+		{"by fuzzy name", `{"name": "Gregory Benson"}`, []string{"benson@usfca.edu"}, []string{"40649", "42345"}, "Tuesdays 1-3 PM"},
+		{"shared name", `{"name": "Philip Peterson"}`, []string{"phpeterson@usfca.edu", "ppeterson2@usfca.edu"}, []string{"40646", "42344", "40100"}, ""},
+		{"unknown email", `{"email": "nobody@usfca.edu"}`, nil, nil, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			result, sections, err := getInstructor(db, test.args)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			var response struct {
+				Instructors []InstructorProfile `json:"instructors"`
+			}
+			if err := json.Unmarshal([]byte(result), &response); err != nil {
+				t.Fatalf("Error unmarshaling result: %v", err)
+			}
+
+			var keys, crns []string
+			for _, instructor := range response.Instructors {
+				keys = append(keys, instructor.Key)
+			}
+			for _, section := range sections {
+				crns = append(crns, section.CRN)
+			}
+			if !reflect.DeepEqual(keys, test.expected) || !reflect.DeepEqual(crns, test.crns) {
+				t.Errorf("Expected instructors %v teaching %v, got %v teaching %v", test.expected, test.crns, keys, crns)
+			}
+			if test.officeHrs != "" && (response.Instructors[0].OfficeHours == nil || response.Instructors[0].OfficeHours.Hours != test.officeHrs) {
+				t.Errorf("Expected office hours %q, got %+v", test.officeHrs, response.Instructors[0].OfficeHours)
+			}
+		})
+	}
+}
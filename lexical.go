@@ -0,0 +1,168 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+// where the lexical index is saved after ingestion so it can be loaded on startup
+const lexicalIndexFile = "lexical-index.json"
+
+// BM25 tuning parameters
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+)
+
+// words that carry no meaning when searching for a course
+var stopWords = map[string]struct{}{
+	"a": {}, "an": {}, "and": {}, "about": {}, "are": {}, "as": {}, "at": {}, "be": {}, "by": {},
+	"class": {}, "classes": {}, "course": {}, "courses": {}, "for": {}, "from": {}, "i": {},
+	"in": {}, "into": {}, "is": {}, "it": {}, "of": {}, "on": {}, "or": {}, "something": {},
+	"that": {}, "the": {}, "this": {}, "to": {}, "want": {}, "what": {}, "with": {},
+}
+
+// LexicalIndex is a BM25 inverted index over the searchable text of each course
+type LexicalIndex struct {
+	// term -> course ID -> number of times the term appears in that course
+	Postings map[string]map[string]int `json:"postings"`
+	// course ID -> number of terms in that course
+	DocLengths   map[string]int `json:"docLengths"`
+	AvgDocLength float64        `json:"avgDocLength"`
+}
+
+// a single course ID and its BM25 score
+type lexicalHit struct {
+	ID    string
+	Score float64
+}
+
+// builds the index from the title, subject, instructor name and catalog text of every course
+func BuildLexicalIndex(courses []Course) *LexicalIndex {
+	idx := &LexicalIndex{
+		Postings:   make(map[string]map[string]int),
+		DocLengths: make(map[string]int),
+	}
+
+	totalLength := 0
+	for _, course := range courses {
+		id := course.CRN
+		if _, exists := idx.DocLengths[id]; exists {
+			continue
+		}
+
+		terms := tokenize(lexicalText(course))
+		for _, term := range terms {
+			if idx.Postings[term] == nil {
+				idx.Postings[term] = make(map[string]int)
+			}
+			idx.Postings[term][id]++
+		}
+		idx.DocLengths[id] = len(terms)
+		totalLength += len(terms)
+	}
+
+	if len(idx.DocLengths) > 0 {
+		idx.AvgDocLength = float64(totalLength) / float64(len(idx.DocLengths))
+	}
+	return idx
+}
+
+// the text of a course that takes part in lexical search
+func lexicalText(course Course) string {
+	return strings.Join([]string{
+		course.Title,
+		course.Subject,
+		course.InstructorFirstName,
+		course.InstructorLastName,
+		course.Description,
+		course.Attributes,
+	}, " ")
+}
+
+// lowercases the text, splits it on anything that isn't a letter or digit and drops stop words
+func tokenize(text string) []string {
+	fields := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := make([]string, 0, len(fields))
+	for _, field := range fields {
+		if _, isStopWord := stopWords[field]; isStopWord {
+			continue
+		}
+		terms = append(terms, field)
+	}
+	return terms
+}
+
+// returns up to 'limit' course IDs ranked by their BM25 score for the query
+func (idx *LexicalIndex) Search(query string, limit int) []lexicalHit {
+	if idx == nil || len(idx.DocLengths) == 0 {
+		return nil
+	}
+
+	n := float64(len(idx.DocLengths))
+	scores := make(map[string]float64)
+	for _, term := range tokenize(query) {
+		postings, exists := idx.Postings[term]
+		if !exists {
+			continue
+		}
+
+		df := float64(len(postings))
+		idf := math.Log(1 + (n-df+0.5)/(df+0.5))
+		for id, tf := range postings {
+			docLength := float64(idx.DocLengths[id])
+			norm := float64(tf) * (bm25K1 + 1) / (float64(tf) + bm25K1*(1-bm25B+bm25B*docLength/idx.AvgDocLength))
+			scores[id] += idf * norm
+		}
+	}
+
+	hits := make([]lexicalHit, 0, len(scores))
+	for id, score := range scores {
+		hits = append(hits, lexicalHit{ID: id, Score: score})
+	}
+	sort.Slice(hits, func(i, j int) bool {
+		if hits[i].Score != hits[j].Score {
+			return hits[i].Score > hits[j].Score
+		}
+		return hits[i].ID < hits[j].ID
+	})
+
+	if limit > 0 && len(hits) > limit {
+		hits = hits[:limit]
+	}
+	return hits
+}
+
+// writes the index to disk as JSON
+func (idx *LexicalIndex) Save(filePath string) error {
+	data, err := json.Marshal(idx)
+	if err != nil {
+		return fmt.Errorf("failed to marshal lexical index: %w", err)
+	}
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write lexical index: %w", err)
+	}
+	return nil
+}
+
+// reads an index previously written by Save
+func LoadLexicalIndex(filePath string) (*LexicalIndex, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	var idx LexicalIndex
+	if err := json.Unmarshal(data, &idx); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal lexical index: %w", err)
+	}
+	return &idx, nil
+}
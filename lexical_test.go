@@ -0,0 +1,115 @@
+package main
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestLexicalSearch(t *testing.T) {
+	courses := []Course{
+		{CRN: "1", Subject: "CS", Title: "Foundations of AI", InstructorFirstName: "Jackson", InstructorLastName: "Williams"},
+		{CRN: "2", Subject: "PHIL", Title: "Ethics", InstructorFirstName: "Richie", InstructorLastName: "Kim"},
+		{CRN: "3", Subject: "PHIL", Title: "Environmental Ethics", InstructorFirstName: "Stephen", InstructorLastName: "Friesen"},
+		{CRN: "4", Subject: "MUS", Title: "Guitar and Bass Lessons", InstructorFirstName: "Christopher", InstructorLastName: "Ruscoe"},
+	}
+	idx := BuildLexicalIndex(courses)
+
+	tests := []struct {
+		name     string
+		query    string
+		expected []string
+	}{
+		{name: "title words", query: "something about ethics", expected: []string{"2", "3"}},
+		{name: "instructor name", query: "Williams", expected: []string{"1"}},
+		{name: "subject code", query: "mus", expected: []string{"4"}},
+		{name: "no match", query: "skateboarding", expected: nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var got []string
+			for _, hit := range idx.Search(test.query, 10) {
+				got = append(got, hit.ID)
+			}
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("Search(%q) = %v, expected %v", test.query, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestReciprocalRankFusion(t *testing.T) {
+	tests := []struct {
+		name     string
+		rankings [][]string
+		expected []string
+	}{
+		{
+			name:     "agreement wins",
+			rankings: [][]string{{"a", "b", "c"}, {"b", "a"}, {"b"}},
+			expected: []string{"b", "a", "c"},
+		},
+		{
+			name:     "single list keeps its order",
+			rankings: [][]string{{"x", "y", "z"}},
+			expected: []string{"x", "y", "z"},
+		},
+		{
+			name:     "no rankings",
+			rankings: nil,
+			expected: []string{},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			got := reciprocalRankFusion(test.rankings...)
+			if !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("reciprocalRankFusion(%v) = %v, expected %v", test.rankings, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestHybridSearchKeepsTheFilter(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+	whereFilter := map[string]interface{}{
+		"$or": []map[string]interface{}{{"Subject": "PHIL"}, {"Section": "999"}},
+	}
+
+	// the phrasing matches CS courses far better than PHIL ones, in both retrievers
+	for _, query := range []string{"Software Development", "Peterson", "ethics"} {
+		t.Run(query, func(t *testing.T) {
+			page, err := queryCourses(db, courseQuery{Where: whereFilter, Query: query, PageSize: 10}.normalized())
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			for _, course := range page.Courses {
+				if course.Subject != "PHIL" {
+					t.Errorf("Expected only PHIL courses, got %s %s (CRN %s)", course.Subject, course.CourseNumber, course.CRN)
+				}
+			}
+			if page.Total > 2 {
+				t.Errorf("Expected at most the 2 PHIL sections in total, got %d", page.Total)
+			}
+		})
+	}
+}
+
+func TestLoadLexicalIndexRebuildsFromTheStore(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+	db.lexicalIndex = nil
+
+	// the CSV doesn't exist, so the index can only come from the courses collection
+	if err := db.loadLexicalIndex("missing.csv"); err != nil {
+		t.Fatalf("Error loading the index: %v", err)
+	}
+	if len(db.lexicalIndex.DocLengths) != len(fakeCourses) {
+		t.Errorf("Expected an index of the %d stored courses, got %d", len(fakeCourses), len(db.lexicalIndex.DocLengths))
+	}
+	saved, err := LoadLexicalIndex(filepath.Join(db.stateDir, lexicalIndexFile))
+	if err != nil || !reflect.DeepEqual(saved.DocLengths, db.lexicalIndex.DocLengths) {
+		t.Errorf("Expected the index to be saved in the store's directory, got %v", err)
+	}
+}
@@ -0,0 +1,393 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"encoding/gob"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/amikos-tech/chroma-go/types"
+)
+
+// the extension of the file each collection of the local store is kept in
+const localCollectionExt = ".vectors"
+
+// localStore is a VectorStore that keeps its collections in memory and in a directory on disk, so
+// the chatbot runs as a single binary without a Chroma server. Queries compare the query's
+// embedding with every record, which is fast enough for a semester's schedule.
+type localStore struct {
+	dir        string
+	embeddings types.EmbeddingFunction
+
+	mu          sync.Mutex
+	collections map[string]*localCollection
+}
+
+func newLocalStore(dir string, embeddings types.EmbeddingFunction) (*localStore, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, &StoreUnavailableError{Op: "creating store directory", Err: err}
+	}
+	return &localStore{dir: dir, embeddings: embeddings, collections: make(map[string]*localCollection)}, nil
+}
+
+func (s *localStore) GetCollection(ctx context.Context, name string) (VectorCollection, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if collection, exists := s.collections[name]; exists {
+		return collection, nil
+	}
+
+	op := fmt.Sprintf("getting collection '%s'", name)
+	collection, err := loadLocalCollection(s.path(name), s.embeddings)
+	if os.IsNotExist(err) {
+		return nil, &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: fmt.Errorf("no collection file in '%s'", s.dir)}
+	}
+	if err != nil {
+		return nil, &StoreUnavailableError{Op: op, Err: err}
+	}
+	s.collections[name] = collection
+	return collection, nil
+}
+
+func (s *localStore) CreateCollection(ctx context.Context, name string, info CollectionInfo) (VectorCollection, error) {
+	collection, err := s.GetCollection(ctx, name)
+	if !errors.Is(err, ErrCollectionNotFound) {
+		return collection, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := fmt.Sprintf("creating collection '%s'", name)
+	if err := s.writeInfo(name, info); err != nil {
+		return nil, &StoreUnavailableError{Op: op, Err: err}
+	}
+	created := newLocalCollection(s.path(name), s.embeddings)
+	if err := os.WriteFile(created.path, nil, 0o644); err != nil {
+		return nil, &StoreUnavailableError{Op: op, Err: err}
+	}
+	s.collections[name] = created
+	return created, nil
+}
+
+func (s *localStore) DeleteCollection(ctx context.Context, name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.collections, name)
+	os.Remove(s.infoPath(name))
+	err := os.Remove(s.path(name))
+	op := fmt.Sprintf("deleting collection '%s'", name)
+	if os.IsNotExist(err) {
+		return &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: err}
+	}
+	if err != nil {
+		return &StoreUnavailableError{Op: op, Err: err}
+	}
+	return nil
+}
+
+// the file the collection is kept in; names are used as they are since the chatbot picks them
+func (s *localStore) path(name string) string {
+	return filepath.Join(s.dir, name+localCollectionExt)
+}
+
+// the collection's info is kept as its metadata in a file next to its records
+func (s *localStore) CollectionInfo(ctx context.Context, name string) (CollectionInfo, error) {
+	op := fmt.Sprintf("getting collection '%s'", name)
+	if _, err := os.Stat(s.path(name)); os.IsNotExist(err) {
+		return CollectionInfo{}, &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: fmt.Errorf("no collection file in '%s'", s.dir)}
+	}
+	var metadata map[string]interface{}
+	data, err := os.ReadFile(s.infoPath(name))
+	if err == nil {
+		err = json.Unmarshal(data, &metadata)
+	}
+	if err != nil && !os.IsNotExist(err) {
+		return CollectionInfo{}, &StoreUnavailableError{Op: op, Err: err}
+	}
+	return collectionInfoFromMetadata(metadata), nil
+}
+
+func (s *localStore) SetCollectionInfo(ctx context.Context, name string, info CollectionInfo) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	op := fmt.Sprintf("updating collection '%s'", name)
+	if _, err := os.Stat(s.path(name)); os.IsNotExist(err) {
+		return &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: fmt.Errorf("no collection file in '%s'", s.dir)}
+	}
+	if err := s.writeInfo(name, info); err != nil {
+		return &StoreUnavailableError{Op: op, Err: err}
+	}
+	return nil
+}
+
+func (s *localStore) writeInfo(name string, info CollectionInfo) error {
+	data, err := json.MarshalIndent(info.metadata(), "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(s.infoPath(name), data, 0o644)
+}
+
+func (s *localStore) infoPath(name string) string {
+	return filepath.Join(s.dir, name+".json")
+}
+
+// localCollection is one collection of the local store. Its file is a log of the batches written
+// to it, each framed by its length, which is replayed when the collection is loaded; appending a
+// batch is cheap however large the collection grows.
+type localCollection struct {
+	path       string
+	embeddings types.EmbeddingFunction
+
+	mu      sync.RWMutex
+	records []localRecord
+	// the index of each record by ID
+	index map[string]int
+}
+
+// localRecord is a record of a local collection as it is written to disk
+type localRecord struct {
+	ID        string
+	Document  string
+	Metadata  map[string]interface{}
+	Embedding []float32
+}
+
+// localBatch is one write to a local collection
+type localBatch struct {
+	// replace the records with the same IDs instead of keeping them
+	Replace bool
+	Records []localRecord
+}
+
+func newLocalCollection(path string, embeddings types.EmbeddingFunction) *localCollection {
+	return &localCollection{path: path, embeddings: embeddings, index: make(map[string]int)}
+}
+
+// reads the collection by replaying every batch in its file. A batch cut short, e.g. by the program
+// being killed while writing it, is cut off the file so that later batches are appended after the
+// last complete one.
+func loadLocalCollection(path string, embeddings types.EmbeddingFunction) (*localCollection, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	collection := newLocalCollection(path, embeddings)
+	reader := bufio.NewReader(file)
+	// where the last complete batch ends
+	var offset int64
+	for {
+		var size uint32
+		err := binary.Read(reader, binary.LittleEndian, &size)
+		if err == io.EOF {
+			return collection, nil
+		}
+		frame := make([]byte, size)
+		if err == nil {
+			_, err = io.ReadFull(reader, frame)
+		}
+		if errors.Is(err, io.ErrUnexpectedEOF) {
+			log.Printf("Collection file '%s' ends with an incomplete batch, which was dropped.", path)
+			return collection, os.Truncate(path, offset)
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		var batch localBatch
+		if err := gob.NewDecoder(bytes.NewReader(frame)).Decode(&batch); err != nil {
+			return nil, fmt.Errorf("failed to read collection file '%s': %w", path, err)
+		}
+		collection.apply(batch)
+		offset += 4 + int64(size)
+	}
+}
+
+// like Chroma, adding an existing ID keeps the record that is already there
+func (c *localCollection) Add(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error {
+	return c.write(ctx, ids, documents, metadatas, false)
+}
+
+func (c *localCollection) Upsert(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error {
+	return c.write(ctx, ids, documents, metadatas, true)
+}
+
+func (c *localCollection) write(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}, replace bool) error {
+	if len(ids) != len(documents) || (metadatas != nil && len(metadatas) != len(ids)) {
+		return fmt.Errorf("ids, documents and metadatas must have the same length")
+	}
+	embeddings, err := c.embeddings.EmbedDocuments(ctx, documents)
+	if err != nil {
+		return &EmbeddingError{Err: err}
+	}
+
+	batch := localBatch{Replace: replace}
+	for i, id := range ids {
+		record := localRecord{ID: id, Document: documents[i], Embedding: embeddingValues(embeddings[i])}
+		if metadatas != nil {
+			record.Metadata = metadatas[i]
+		}
+		batch.Records = append(batch.Records, record)
+	}
+	return c.writeBatch(batch)
+}
+
+func (c *localCollection) GetEmbedded(ctx context.Context) ([]EmbeddedRecord, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	records := make([]EmbeddedRecord, len(c.records))
+	for i, record := range c.records {
+		records[i] = EmbeddedRecord(record)
+	}
+	return records, nil
+}
+
+func (c *localCollection) UpsertEmbedded(ctx context.Context, records []EmbeddedRecord) error {
+	batch := localBatch{Replace: true}
+	for _, record := range records {
+		batch.Records = append(batch.Records, localRecord(record))
+	}
+	return c.writeBatch(batch)
+}
+
+func (c *localCollection) writeBatch(batch localBatch) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	// the batch is on disk before it can be read, so what a query sees survives a restart
+	if err := c.append(batch); err != nil {
+		return &StoreUnavailableError{Op: "writing records", Err: err}
+	}
+	c.apply(batch)
+	return nil
+}
+
+// appends the batch to the collection's file
+func (c *localCollection) append(batch localBatch) error {
+	var frame bytes.Buffer
+	if err := gob.NewEncoder(&frame).Encode(batch); err != nil {
+		return err
+	}
+	file, err := os.OpenFile(c.path, os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	if err := binary.Write(file, binary.LittleEndian, uint32(frame.Len())); err != nil {
+		file.Close()
+		return err
+	}
+	if _, err := file.Write(frame.Bytes()); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func (c *localCollection) apply(batch localBatch) {
+	for _, record := range batch.Records {
+		if i, exists := c.index[record.ID]; exists {
+			if batch.Replace {
+				c.records[i] = record
+			}
+			continue
+		}
+		c.index[record.ID] = len(c.records)
+		c.records = append(c.records, record)
+	}
+}
+
+func (c *localCollection) Query(ctx context.Context, text string, nResults int, where map[string]interface{}) (CollectionResults, error) {
+	embedding, err := c.embeddings.EmbedQuery(ctx, text)
+	if err != nil {
+		return CollectionResults{}, &EmbeddingError{Err: err}
+	}
+	query := embeddingValues(embedding)
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	type scored struct {
+		record   *localRecord
+		distance float32
+	}
+	var candidates []scored
+	for i := range c.records {
+		record := &c.records[i]
+		if where != nil && !matchesWhere(record.Metadata, where) {
+			continue
+		}
+		if len(record.Embedding) != len(query) {
+			return CollectionResults{}, fmt.Errorf("querying records: the query has %d dimensions but record '%s' has %d", len(query), record.ID, len(record.Embedding))
+		}
+		candidates = append(candidates, scored{record, squaredL2(query, record.Embedding)})
+	}
+	sort.SliceStable(candidates, func(i, j int) bool { return candidates[i].distance < candidates[j].distance })
+	if len(candidates) > nResults {
+		candidates = candidates[:nResults]
+	}
+
+	var results CollectionResults
+	for _, candidate := range candidates {
+		results.IDs = append(results.IDs, candidate.record.ID)
+		results.Documents = append(results.Documents, candidate.record.Document)
+		results.Metadatas = append(results.Metadatas, candidate.record.Metadata)
+		results.Distances = append(results.Distances, candidate.distance)
+	}
+	return results, nil
+}
+
+func (c *localCollection) Get(ctx context.Context, ids []string, where map[string]interface{}) (CollectionResults, error) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	var records []*localRecord
+	if len(ids) == 0 {
+		for i := range c.records {
+			records = append(records, &c.records[i])
+		}
+	}
+	for _, id := range ids {
+		if i, exists := c.index[id]; exists {
+			records = append(records, &c.records[i])
+		}
+	}
+
+	var results CollectionResults
+	for _, record := range records {
+		if where != nil && !matchesWhere(record.Metadata, where) {
+			continue
+		}
+		results.IDs = append(results.IDs, record.ID)
+		results.Documents = append(results.Documents, record.Document)
+		results.Metadatas = append(results.Metadatas, record.Metadata)
+	}
+	return results, nil
+}
+
+// the values of an embedding, which the OpenAI embedding function returns as float32
+func embeddingValues(embedding *types.Embedding) []float32 {
+	if embedding == nil || embedding.GetFloat32() == nil {
+		return nil
+	}
+	return *embedding.GetFloat32()
+}
+
+// the squared Euclidean distance, which is what Chroma's l2 space returns, so that the distance
+// thresholds of the lookups mean the same with either store
+func squaredL2(a, b []float32) float32 {
+	var sum float32
+	for i := range a {
+		d := a[i] - b[i]
+		sum += d * d
+	}
+	return sum
+}
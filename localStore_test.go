@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+
+	"github.com/amikos-tech/chroma-go/types"
+)
+
+// a local store in dir that embeds with hashes instead of OpenAI
+func openTestLocalStore(t *testing.T, dir string) *localStore {
+	t.Helper()
+	store, err := newLocalStore(dir, types.NewConsistentHashEmbeddingFunction())
+	if err != nil {
+		t.Fatalf("Error opening store: %v", err)
+	}
+	return store
+}
+
+func TestLocalStore(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	store := openTestLocalStore(t, dir)
+
+	if _, err := store.GetCollection(ctx, "usf-courses"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Fatalf("Expected a missing collection, got %v", err)
+	}
+	collection, err := store.CreateCollection(ctx, "usf-courses", currentCollectionInfo())
+	if err != nil {
+		t.Fatalf("Error creating collection: %v", err)
+	}
+	metadatas := []map[string]interface{}{
+		{"Subject": "CS", "CourseNumber": "272"},
+		{"Subject": "CS", "CourseNumber": "315"},
+		{"Subject": "PHIL", "CourseNumber": "240"},
+	}
+	if err := collection.Add(ctx, []string{"40646", "40649", "41182"}, []string{"Software Development", "Computer Architecture", "Ethics"}, metadatas); err != nil {
+		t.Fatalf("Error adding records: %v", err)
+	}
+	// adding an existing ID keeps the record, upserting replaces it
+	if err := collection.Add(ctx, []string{"40646"}, []string{"Not Software Development"}, nil); err != nil {
+		t.Fatalf("Error adding records: %v", err)
+	}
+	if err := collection.Upsert(ctx, []string{"41182"}, []string{"Ethics in Technology"}, []map[string]interface{}{{"Subject": "PHIL", "CourseNumber": "240"}}); err != nil {
+		t.Fatalf("Error upserting records: %v", err)
+	}
+
+	// the records are read back the same from a store opened on the same directory
+	for _, opened := range []*localStore{store, openTestLocalStore(t, dir)} {
+		collection, err := opened.GetCollection(ctx, "usf-courses")
+		if err != nil {
+			t.Fatalf("Error getting collection: %v", err)
+		}
+
+		tests := []struct {
+			name     string
+			results  func() (CollectionResults, error)
+			expected []string
+		}{
+			{"get everything", func() (CollectionResults, error) { return collection.Get(ctx, nil, nil) }, []string{"Software Development", "Computer Architecture", "Ethics in Technology"}},
+			{"get by ID", func() (CollectionResults, error) { return collection.Get(ctx, []string{"41182", "00000"}, nil) }, []string{"Ethics in Technology"}},
+			{"get by filter", func() (CollectionResults, error) {
+				return collection.Get(ctx, nil, map[string]interface{}{"CourseNumber": map[string]interface{}{"$in": []interface{}{"315", "240"}}})
+			}, []string{"Computer Architecture", "Ethics in Technology"}},
+			{"query ranks the same document first", func() (CollectionResults, error) {
+				return collection.Query(ctx, "Computer Architecture", 1, nil)
+			}, []string{"Computer Architecture"}},
+			{"query with a filter", func() (CollectionResults, error) {
+				return collection.Query(ctx, "Computer Architecture", 5, map[string]interface{}{"Subject": "PHIL"})
+			}, []string{"Ethics in Technology"}},
+		}
+		for _, test := range tests {
+			t.Run(test.name, func(t *testing.T) {
+				results, err := test.results()
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(results.Documents, test.expected) {
+					t.Errorf("Expected %v, got %v", test.expected, results.Documents)
+				}
+			})
+		}
+	}
+
+	if err := store.DeleteCollection(ctx, "usf-courses"); err != nil {
+		t.Fatalf("Error deleting collection: %v", err)
+	}
+	if _, err := openTestLocalStore(t, dir).GetCollection(ctx, "usf-courses"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected the deleted collection to be gone, got %v", err)
+	}
+	if err := store.DeleteCollection(ctx, "usf-courses"); !errors.Is(err, ErrCollectionNotFound) {
+		t.Errorf("Expected deleting a missing collection to fail with ErrCollectionNotFound, got %v", err)
+	}
+}
+
+func TestLocalStoreDropsIncompleteBatch(t *testing.T) {
+	ctx := context.Background()
+	dir := t.TempDir()
+	collection, err := openTestLocalStore(t, dir).CreateCollection(ctx, "subjects", currentCollectionInfo())
+	if err != nil {
+		t.Fatalf("Error creating collection: %v", err)
+	}
+	if err := collection.Add(ctx, []string{"Ethics"}, []string{"Ethics"}, nil); err != nil {
+		t.Fatalf("Error adding records: %v", err)
+	}
+
+	// a batch whose write was cut short after its length
+	file, err := os.OpenFile(openTestLocalStore(t, dir).path("subjects"), os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		t.Fatalf("Error opening collection file: %v", err)
+	}
+	file.Write([]byte{200, 0, 0, 0, 1, 2, 3})
+	file.Close()
+
+	collection, err = openTestLocalStore(t, dir).GetCollection(ctx, "subjects")
+	if err != nil {
+		t.Fatalf("Error getting collection: %v", err)
+	}
+	if err := collection.Add(ctx, []string{"Laboratory"}, []string{"Laboratory"}, nil); err != nil {
+		t.Fatalf("Error adding records: %v", err)
+	}
+
+	collection, err = openTestLocalStore(t, dir).GetCollection(ctx, "subjects")
+	if err != nil {
+		t.Fatalf("Error getting collection: %v", err)
+	}
+	results, err := collection.Get(ctx, nil, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if expected := []string{"Ethics", "Laboratory"}; !reflect.DeepEqual(results.IDs, expected) {
+		t.Errorf("Expected %v, got %v", expected, results.IDs)
+	}
+}
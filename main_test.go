@@ -6,8 +6,10 @@ import (
     "os"
     "testing"
 
+    "net/http"
+    "path/filepath"
+
     chroma "github.com/amikos-tech/chroma-go"
-    "github.com/amikos-tech/chroma-go/pkg/embeddings/openai"
 )
 
 func TestVectorQuery(t *testing.T) {
@@ -24,13 +26,15 @@ func TestVectorQuery(t *testing.T) {
             expectedCRNs: []string{"40646"},
         },
 	}
+    httpClient := testHTTPClient(t)
+
     ctx := context.Background()
-    client, err := chroma.NewClient()
+    client, err := chroma.NewClient(chroma.WithHTTPClient(httpClient))
     if err != nil {
         t.Fatalf("Failed to create ChromaDB client: %v", err)
     }
 
-    openaiEf, err := openai.NewOpenAIEmbeddingFunction(os.Getenv("OPENAI_API_KEY"))
+    openaiEf, err := newEmbeddingFunction(httpClient)
     if err != nil {
         t.Fatalf("Error creating OpenAI embedding function: %v", err)
     }
@@ -87,4 +91,50 @@ func TestVectorQuery(t *testing.T) {
             }
         })
     }
-}
\ No newline at end of file
+}
+
+// skips a test that needs a running Chroma on localhost:8000 and a real OpenAI key when either is missing
+func requireLiveServices(t *testing.T) {
+    t.Helper()
+    if os.Getenv("OPENAI_API_KEY") == "" {
+        t.Skip("OPENAI_API_KEY is not set")
+    }
+    client, err := chroma.NewClient()
+    if err != nil {
+        t.Skipf("Chroma client unavailable: %v", err)
+    }
+    if _, err := client.Heartbeat(context.Background()); err != nil {
+        t.Skipf("Chroma is not reachable: %v", err)
+    }
+}
+
+// the HTTP client of a test that talks to Chroma and OpenAI. The traffic is replayed from
+// testdata/http/<test> when that directory exists, and recorded there when RAG_HTTP_MODE=record;
+// otherwise the test needs the live services.
+func testHTTPClient(t *testing.T) *http.Client {
+    t.Helper()
+    dir := filepath.Join("testdata", "http", t.Name())
+    mode := os.Getenv("RAG_HTTP_MODE")
+    if mode == "" {
+        mode = httpLive
+        if _, err := os.Stat(dir); err == nil {
+            mode = httpReplay
+        }
+    }
+
+    switch mode {
+    case httpReplay:
+        // the clients refuse to send requests without a key, even though nothing is sent
+        if os.Getenv("OPENAI_API_KEY") == "" {
+            t.Setenv("OPENAI_API_KEY", "replay")
+        }
+    default:
+        requireLiveServices(t)
+    }
+
+    client, err := newHTTPClient(mode, dir)
+    if err != nil {
+        t.Fatalf("Error creating HTTP client: %v", err)
+    }
+    return client
+}
@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// the number of courses on a page when the model doesn't ask for a size
+const defaultPageSize = 20
+
+// the largest page the model can ask for
+const maxPageSize = 50
+
+// a rough cap on the size of a single tool result so that it fits in the model's context
+const toolResultTokenBudget = 6000
+
+// the orders the model can ask courses to be sorted in
+const (
+	sortByRelevance    = "relevance"
+	sortByTime         = "time"
+	sortByCourseNumber = "course_number"
+	sortByEnrollment   = "enrollment"
+)
+
+var sortOrders = []string{sortByRelevance, sortByTime, sortByCourseNumber, sortByEnrollment}
+
+// courseQuery is everything needed to recompute a page of results
+type courseQuery struct {
+	// the validated arguments of the tool call, which is what a cursor carries so that the filter
+	// is rebuilt rather than trusted
+	Args     courseSearchArgs
+	Where    map[string]interface{}
+	Query    string
+	SortBy   string
+	Offset   int
+	PageSize int
+}
+
+// pageCursor is what a cursor encodes: the arguments of the first call and where the next page starts
+type pageCursor struct {
+	Args   courseSearchArgs `json:"args"`
+	Offset int              `json:"offset,omitempty"`
+}
+
+// CoursePage is one page of a course search, as returned to the model
+type CoursePage struct {
+	Courses []Course `json:"courses"`
+	// the number of matching courses found; a lower bound when TotalIsExact is false
+	Total        int  `json:"total"`
+	TotalIsExact bool `json:"totalIsExact"`
+	Offset       int  `json:"offset"`
+	Returned     int  `json:"returned"`
+	// true when more matching courses exist beyond this page
+	Truncated  bool   `json:"truncated"`
+	NextCursor string `json:"nextCursor,omitempty"`
+	SortBy     string `json:"sortBy"`
+}
+
+// encodes the next page of a query as an opaque string the model can hand back
+func encodeCursor(q courseQuery) (string, error) {
+	cursor := pageCursor{Args: q.Args, Offset: q.Offset}
+	cursor.Args.Cursor = ""
+	data, err := json.Marshal(cursor)
+	if err != nil {
+		return "", fmt.Errorf("failed to marshal cursor: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(data), nil
+}
+
+// decodes a cursor, validating its arguments again since the model may hand back anything
+func decodeCursor(cursor string) (pageCursor, error) {
+	var decoded pageCursor
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimSpace(cursor))
+	if err != nil {
+		return decoded, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if err := json.Unmarshal(data, &decoded); err != nil {
+		return decoded, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if decoded.Args.Cursor != "" || decoded.Offset < 0 {
+		return decoded, fmt.Errorf("invalid cursor")
+	}
+
+	arguments, err := json.Marshal(decoded.Args)
+	if err != nil {
+		return decoded, fmt.Errorf("invalid cursor: %w", err)
+	}
+	if decoded.Args, err = parseCourseSearchArgs(string(arguments)); err != nil {
+		return decoded, fmt.Errorf("invalid cursor: %w", err)
+	}
+	return decoded, nil
+}
+
+// fills in defaults and clamps the page size of a query
+func (q courseQuery) normalized() courseQuery {
+	if q.SortBy == "" {
+		q.SortBy = sortByRelevance
+	}
+	if q.PageSize <= 0 {
+		q.PageSize = defaultPageSize
+	}
+	if q.PageSize > maxPageSize {
+		q.PageSize = maxPageSize
+	}
+	if q.Offset < 0 {
+		q.Offset = 0
+	}
+	return q
+}
+
+func isSortOrder(sortBy string) bool {
+	for _, order := range sortOrders {
+		if sortBy == order {
+			return true
+		}
+	}
+	return false
+}
+
+// sorts courses in place; relevance keeps the order they were retrieved in
+func sortCourses(courses []Course, sortBy string) {
+	switch sortBy {
+	case sortByTime:
+		sort.SliceStable(courses, func(i, j int) bool {
+			a, b := clockMinutes(courses[i].BeginTime), clockMinutes(courses[j].BeginTime)
+			if a != b {
+				return a < b
+			}
+			return courses[i].MeetDays < courses[j].MeetDays
+		})
+	case sortByCourseNumber:
+		sort.SliceStable(courses, func(i, j int) bool {
+			if courses[i].Subject != courses[j].Subject {
+				return courses[i].Subject < courses[j].Subject
+			}
+			a, b := leadingNumber(courses[i].CourseNumber), leadingNumber(courses[j].CourseNumber)
+			if a != b {
+				return a < b
+			}
+			if courses[i].CourseNumber != courses[j].CourseNumber {
+				return courses[i].CourseNumber < courses[j].CourseNumber
+			}
+			return courses[i].Section < courses[j].Section
+		})
+	case sortByEnrollment:
+		sort.SliceStable(courses, func(i, j int) bool {
+			return leadingNumber(courses[i].ActualEnrollment) > leadingNumber(courses[j].ActualEnrollment)
+		})
+	}
+}
+
+// minutes past midnight of a 24-hour time such as "1645"; courses without a time sort last
+func clockMinutes(value string) int {
+	value = strings.ReplaceAll(strings.TrimSpace(value), ":", "")
+	n, err := strconv.Atoi(value)
+	if err != nil || value == "" {
+		return 24 * 60
+	}
+	return n/100*60 + n%100
+}
+
+// the number at the start of a value such as "272L", or -1 if there is none
+func leadingNumber(value string) int {
+	end := 0
+	for end < len(value) && unicode.IsDigit(rune(value[end])) {
+		end++
+	}
+	n, err := strconv.Atoi(value[:end])
+	if err != nil {
+		return -1
+	}
+	return n
+}
+
+// cuts a page out of the sorted courses, stopping early if the page would exceed the token budget
+func buildPage(courses []Course, q courseQuery, totalIsExact bool) (CoursePage, error) {
+	page := CoursePage{
+		Courses:      []Course{},
+		Total:        len(courses),
+		TotalIsExact: totalIsExact,
+		Offset:       q.Offset,
+		SortBy:       q.SortBy,
+	}
+
+	usedTokens := 0
+	for i := q.Offset; i < len(courses) && len(page.Courses) < q.PageSize; i++ {
+		courseJSON, err := json.Marshal(courses[i])
+		if err != nil {
+			return page, fmt.Errorf("failed to marshal course: %w", err)
+		}
+		// roughly four characters per token
+		tokens := len(courseJSON)/4 + 1
+		if len(page.Courses) > 0 && usedTokens+tokens > toolResultTokenBudget {
+			break
+		}
+		usedTokens += tokens
+		page.Courses = append(page.Courses, courses[i])
+	}
+
+	page.Returned = len(page.Courses)
+	next := q.Offset + page.Returned
+	page.Truncated = next < len(courses)
+	if page.Truncated {
+		nextQuery := q
+		nextQuery.Offset = next
+		cursor, err := encodeCursor(nextQuery)
+		if err != nil {
+			return page, err
+		}
+		page.NextCursor = cursor
+	}
+	return page, nil
+}
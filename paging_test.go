@@ -0,0 +1,100 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func crns(courses []Course) []string {
+	ids := make([]string, len(courses))
+	for i, course := range courses {
+		ids[i] = course.CRN
+	}
+	return ids
+}
+
+func TestSortCourses(t *testing.T) {
+	courses := []Course{
+		{CRN: "1", Subject: "CS", CourseNumber: "315L", Section: "01", BeginTime: "1645", ActualEnrollment: "12"},
+		{CRN: "2", Subject: "CS", CourseNumber: "110", Section: "02", BeginTime: "0915", ActualEnrollment: "40"},
+		{CRN: "3", Subject: "CS", CourseNumber: "315", Section: "01", BeginTime: "", ActualEnrollment: "7"},
+		{CRN: "4", Subject: "ART", CourseNumber: "101", Section: "01", BeginTime: "1300", ActualEnrollment: "22"},
+	}
+
+	tests := []struct {
+		sortBy   string
+		expected []string
+	}{
+		{sortBy: sortByRelevance, expected: []string{"1", "2", "3", "4"}},
+		{sortBy: sortByTime, expected: []string{"2", "4", "1", "3"}},
+		{sortBy: sortByCourseNumber, expected: []string{"4", "2", "3", "1"}},
+		{sortBy: sortByEnrollment, expected: []string{"2", "4", "1", "3"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.sortBy, func(t *testing.T) {
+			sorted := append([]Course{}, courses...)
+			sortCourses(sorted, test.sortBy)
+			if got := crns(sorted); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("sortCourses(%s) = %v, expected %v", test.sortBy, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestBuildPage(t *testing.T) {
+	var courses []Course
+	for _, crn := range []string{"1", "2", "3", "4", "5"} {
+		courses = append(courses, Course{CRN: crn})
+	}
+
+	args := courseSearchArgs{Query: "ethics", PageSize: 2}
+	q := courseQuery{Args: args, Query: args.Query, PageSize: args.PageSize}.normalized()
+	var seen []string
+	for page := 0; ; page++ {
+		result, err := buildPage(courses, q, true)
+		if err != nil {
+			t.Fatalf("Error building page: %v", err)
+		}
+		if result.Total != 5 {
+			t.Errorf("Total = %d, expected 5", result.Total)
+		}
+		seen = append(seen, crns(result.Courses)...)
+		if !result.Truncated {
+			if result.NextCursor != "" {
+				t.Errorf("Last page has a cursor")
+			}
+			break
+		}
+
+		cursor, err := decodeCursor(result.NextCursor)
+		if err != nil {
+			t.Fatalf("Error decoding cursor: %v", err)
+		}
+		if cursor.Args != args {
+			t.Errorf("Cursor lost the arguments: %+v", cursor.Args)
+		}
+		q.Offset = cursor.Offset
+		if page > 5 {
+			t.Fatalf("Paging never finished")
+		}
+	}
+
+	if !reflect.DeepEqual(seen, []string{"1", "2", "3", "4", "5"}) {
+		t.Errorf("Paged through %v", seen)
+	}
+}
+
+func TestBuildPageTokenBudget(t *testing.T) {
+	longTitle := strings.Repeat("x", toolResultTokenBudget*2)
+	courses := []Course{{CRN: "1", Title: longTitle}, {CRN: "2", Title: longTitle}, {CRN: "3"}}
+
+	page, err := buildPage(courses, courseQuery{PageSize: 50}.normalized(), true)
+	if err != nil {
+		t.Fatalf("Error building page: %v", err)
+	}
+	if page.Returned != 1 || !page.Truncated || page.NextCursor == "" {
+		t.Errorf("Expected the budget to cut the page after one course, got returned=%d truncated=%v", page.Returned, page.Truncated)
+	}
+}
@@ -0,0 +1,618 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/amikos-tech/chroma-go/types"
+	"github.com/jackc/pgx/v5"
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/jackc/pgx/v5/pgxpool"
+)
+
+// the table that lists the collections of the postgres store and the tables they are kept in
+const pgCollectionsTable = "vector_collections"
+
+// pgColumn is a metadata key kept in a column of its own, so that it can be filtered, sorted and
+// joined on in SQL like any other column
+type pgColumn struct {
+	Key  string
+	Name string
+	// text, integer or time; times are written as HHMM in the metadata, like the schedule
+	Type string
+}
+
+// the columns of each collection besides its ID, document, embedding and metadata. Keys without a
+// column are still kept in the metadata and can be filtered on through it.
+var pgCollectionColumns = map[string][]pgColumn{
+	defaultCoursesCollectionName: {
+		{"CRN", "crn", "text"},
+		{"Subject", "subject", "text"},
+		{"CourseNumber", "course_number", "text"},
+		{"Section", "section", "text"},
+		{"TitleShortDesc", "title", "text"},
+		{"PrimaryInstructorEmail", "instructor_email", "text"},
+		{"College", "college", "text"},
+		{"MeetDays", "meet_days", "text"},
+		{"BeginTime", "begin_time", "time"},
+		{"EndTime", "end_time", "time"},
+		{"Building", "building", "text"},
+		{"Room", "room", "text"},
+		{"InstructorFirstName", "instructor_first_name", "text"},
+		{"InstructorLastName", "instructor_last_name", "text"},
+		{"InstructorFullName", "instructor_full_name", "text"},
+		{"InstructorStatus", "instructor_status", "text"},
+		{"MeetingStatus", "meeting_status", "text"},
+		{"ActualEnrollment", "enrollment", "integer"},
+		{"Credits", "credits", "text"},
+		{"Attributes", "attributes", "text"},
+	},
+	defaultInstructorsCollectionName: {
+		{"Email", "email", "text"},
+		{"Name", "name", "text"},
+		{"NameVariants", "name_variants", "text"},
+		{"Colleges", "colleges", "text"},
+		{"Subjects", "subjects", "text"},
+		{"Sections", "sections", "integer"},
+		{"Enrollment", "enrollment", "integer"},
+	},
+	defaultSubjectsCollectionName: {
+		{"Code", "code", "text"},
+		{"Department", "department", "text"},
+		{"Aliases", "aliases", "text"},
+		{"College", "college", "text"},
+		{"Colleges", "colleges", "text"},
+	},
+	defaultCourseCodesCollectionName: {
+		{"Code", "code", "text"},
+		{"Subject", "subject", "text"},
+		{"CourseNumber", "course_number", "text"},
+		{"Titles", "titles", "text"},
+	},
+}
+
+// pgvector's default and largest hnsw.ef_search, the number of neighbours an HNSW index scan finds;
+// a query returning more than the largest scans every row instead
+const (
+	pgDefaultEfSearch = 40
+	pgMaxEfSearch     = 1000
+)
+
+// pgvectorStore is a VectorStore kept in Postgres with the pgvector extension. Every collection is
+// a table listed in vector_collections, with its metadata in real columns.
+type pgvectorStore struct {
+	pool       *pgxpool.Pool
+	embeddings types.EmbeddingFunction
+}
+
+// connects to the database at dsn, creating the pgvector extension and the collection list if needed
+func newPgvectorStore(ctx context.Context, dsn string, embeddings types.EmbeddingFunction) (*pgvectorStore, error) {
+	if dsn == "" {
+		return nil, errors.New("a connection string is needed for the postgres store, set -pg-dsn or DATABASE_URL")
+	}
+	pool, err := pgxpool.New(ctx, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid postgres connection string: %w", err)
+	}
+	if err := pool.Ping(ctx); err != nil {
+		pool.Close()
+		return nil, &StoreUnavailableError{Op: "connecting to postgres", Err: err}
+	}
+
+	_, err = pool.Exec(ctx, `CREATE EXTENSION IF NOT EXISTS vector;
+		CREATE TABLE IF NOT EXISTS `+pgCollectionsTable+` (
+			name       text PRIMARY KEY,
+			table_name text NOT NULL UNIQUE,
+			created_at timestamptz NOT NULL DEFAULT now()
+		);
+		ALTER TABLE `+pgCollectionsTable+` ADD COLUMN IF NOT EXISTS metadata jsonb NOT NULL DEFAULT '{}'`)
+	if err != nil {
+		pool.Close()
+		return nil, pgStoreError("creating the collection list", err)
+	}
+	return &pgvectorStore{pool: pool, embeddings: embeddings}, nil
+}
+
+func (s *pgvectorStore) GetCollection(ctx context.Context, name string) (VectorCollection, error) {
+	var table string
+	err := s.pool.QueryRow(ctx, `SELECT table_name FROM `+pgCollectionsTable+` WHERE name = $1`, name).Scan(&table)
+	op := fmt.Sprintf("getting collection '%s'", name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: fmt.Errorf("no collection named '%s' in %s", name, pgCollectionsTable)}
+	}
+	if err != nil {
+		return nil, pgStoreError(op, err)
+	}
+	return s.collection(name, table), nil
+}
+
+// creates the collection's table with an embedding column of the collection's dimension, so that
+// it can have an HNSW index for the nearest-neighbour queries
+func (s *pgvectorStore) CreateCollection(ctx context.Context, name string, info CollectionInfo) (VectorCollection, error) {
+	collection := s.collection(name, pgTableName(name))
+	dimension := info.Dimension
+	if dimension <= 0 {
+		dimension = embeddingDimension
+	}
+	columns := []string{
+		"seq bigserial",
+		"id text PRIMARY KEY",
+		"document text NOT NULL",
+		"metadata jsonb NOT NULL DEFAULT '{}'",
+		fmt.Sprintf("embedding vector(%d) NOT NULL", dimension),
+	}
+	for _, column := range collection.columns {
+		columns = append(columns, pgx.Identifier{column.Name}.Sanitize()+" "+column.Type)
+	}
+
+	op := fmt.Sprintf("creating collection '%s'", name)
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return nil, pgStoreError(op, err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, `CREATE TABLE IF NOT EXISTS `+collection.table+` (`+strings.Join(columns, ", ")+`)`); err != nil {
+		return nil, pgStoreError(op, err)
+	}
+	// queries order by L2 distance (<->), so the index uses the L2 operator class
+	index := pgx.Identifier{pgTableName(name) + "_embedding_idx"}.Sanitize()
+	if _, err := tx.Exec(ctx, `CREATE INDEX IF NOT EXISTS `+index+` ON `+collection.table+` USING hnsw (embedding vector_l2_ops)`); err != nil {
+		return nil, pgStoreError(op, err)
+	}
+	if _, err := tx.Exec(ctx, `INSERT INTO `+pgCollectionsTable+` (name, table_name, metadata) VALUES ($1, $2, $3) ON CONFLICT (name) DO NOTHING`, name, pgTableName(name), info.metadata()); err != nil {
+		return nil, pgStoreError(op, err)
+	}
+	if err := tx.Commit(ctx); err != nil {
+		return nil, pgStoreError(op, err)
+	}
+	return collection, nil
+}
+
+func (s *pgvectorStore) DeleteCollection(ctx context.Context, name string) error {
+	op := fmt.Sprintf("deleting collection '%s'", name)
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return pgStoreError(op, err)
+	}
+	defer tx.Rollback(ctx)
+
+	var table string
+	err = tx.QueryRow(ctx, `DELETE FROM `+pgCollectionsTable+` WHERE name = $1 RETURNING table_name`, name).Scan(&table)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: fmt.Errorf("no collection named '%s' in %s", name, pgCollectionsTable)}
+	}
+	if err != nil {
+		return pgStoreError(op, err)
+	}
+	if _, err := tx.Exec(ctx, `DROP TABLE IF EXISTS `+pgx.Identifier{table}.Sanitize()); err != nil {
+		return pgStoreError(op, err)
+	}
+	return pgStoreError(op, tx.Commit(ctx))
+}
+
+// the info is kept in the collection's metadata in vector_collections
+func (s *pgvectorStore) CollectionInfo(ctx context.Context, name string) (CollectionInfo, error) {
+	var metadata map[string]interface{}
+	err := s.pool.QueryRow(ctx, `SELECT metadata FROM `+pgCollectionsTable+` WHERE name = $1`, name).Scan(&metadata)
+	op := fmt.Sprintf("getting collection '%s'", name)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return CollectionInfo{}, &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: fmt.Errorf("no collection named '%s' in %s", name, pgCollectionsTable)}
+	}
+	if err != nil {
+		return CollectionInfo{}, pgStoreError(op, err)
+	}
+	return collectionInfoFromMetadata(metadata), nil
+}
+
+func (s *pgvectorStore) SetCollectionInfo(ctx context.Context, name string, info CollectionInfo) error {
+	tag, err := s.pool.Exec(ctx, `UPDATE `+pgCollectionsTable+` SET metadata = metadata || $2 WHERE name = $1`, name, info.metadata())
+	op := fmt.Sprintf("updating collection '%s'", name)
+	if err != nil {
+		return pgStoreError(op, err)
+	}
+	if tag.RowsAffected() == 0 {
+		return &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: fmt.Errorf("no collection named '%s' in %s", name, pgCollectionsTable)}
+	}
+	return nil
+}
+
+func (s *pgvectorStore) collection(name, table string) *pgCollection {
+	return &pgCollection{
+		pool:       s.pool,
+		embeddings: s.embeddings,
+		table:      pgx.Identifier{table}.Sanitize(),
+		columns:    pgCollectionColumns[name],
+	}
+}
+
+// the table a collection is kept in, e.g. usf_courses for usf-courses
+func pgTableName(name string) string {
+	return strings.Trim(unsafeFileChars.ReplaceAllString(strings.ToLower(name), "_"), "_")
+}
+
+// pgCollection is one collection of the postgres store
+type pgCollection struct {
+	pool       *pgxpool.Pool
+	embeddings types.EmbeddingFunction
+	// the table's name, quoted for SQL
+	table   string
+	columns []pgColumn
+}
+
+// like Chroma, adding an existing ID keeps the record that is already there
+func (c *pgCollection) Add(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error {
+	return c.write(ctx, ids, documents, metadatas, false)
+}
+
+func (c *pgCollection) Upsert(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error {
+	return c.write(ctx, ids, documents, metadatas, true)
+}
+
+func (c *pgCollection) write(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}, replace bool) error {
+	if len(ids) != len(documents) || (metadatas != nil && len(metadatas) != len(ids)) {
+		return fmt.Errorf("ids, documents and metadatas must have the same length")
+	}
+	embeddings, err := c.embeddings.EmbedDocuments(ctx, documents)
+	if err != nil {
+		return &EmbeddingError{Err: err}
+	}
+	records := make([]EmbeddedRecord, len(ids))
+	for i, id := range ids {
+		records[i] = EmbeddedRecord{ID: id, Document: documents[i], Embedding: embeddingValues(embeddings[i])}
+		if metadatas != nil {
+			records[i].Metadata = metadatas[i]
+		}
+	}
+	return c.writeRecords(ctx, records, replace)
+}
+
+func (c *pgCollection) GetEmbedded(ctx context.Context) ([]EmbeddedRecord, error) {
+	rows, err := c.pool.Query(ctx, `SELECT id, document, metadata, embedding::text FROM `+c.table+` ORDER BY seq`)
+	if err != nil {
+		return nil, pgStoreError("getting records", err)
+	}
+	defer rows.Close()
+	var records []EmbeddedRecord
+	for rows.Next() {
+		var record EmbeddedRecord
+		var embedding string
+		if err := rows.Scan(&record.ID, &record.Document, &record.Metadata, &embedding); err != nil {
+			return nil, pgStoreError("reading records", err)
+		}
+		if record.Embedding, err = parsePgVector(embedding); err != nil {
+			return nil, fmt.Errorf("reading the embedding of '%s': %w", record.ID, err)
+		}
+		records = append(records, record)
+	}
+	return records, pgStoreError("reading records", rows.Err())
+}
+
+func (c *pgCollection) UpsertEmbedded(ctx context.Context, records []EmbeddedRecord) error {
+	return c.writeRecords(ctx, records, true)
+}
+
+func (c *pgCollection) writeRecords(ctx context.Context, records []EmbeddedRecord, replace bool) error {
+	names := []string{"id", "document", "metadata", "embedding"}
+	placeholders := []string{"$1", "$2", "$3", "$4::text::vector"}
+	updates := []string{"document = excluded.document", "metadata = excluded.metadata", "embedding = excluded.embedding"}
+	for i, column := range c.columns {
+		name := pgx.Identifier{column.Name}.Sanitize()
+		names = append(names, name)
+		placeholders = append(placeholders, fmt.Sprintf("$%d::text::%s", i+5, column.Type))
+		updates = append(updates, name+" = excluded."+name)
+	}
+	statement := `INSERT INTO ` + c.table + ` (` + strings.Join(names, ", ") + `) VALUES (` + strings.Join(placeholders, ", ") + `) ON CONFLICT (id) DO NOTHING`
+	if replace {
+		statement = strings.TrimSuffix(statement, "NOTHING") + "UPDATE SET " + strings.Join(updates, ", ")
+	}
+
+	batch := &pgx.Batch{}
+	for _, record := range records {
+		metadata := map[string]interface{}{}
+		if record.Metadata != nil {
+			metadata = record.Metadata
+		}
+		args := []interface{}{record.ID, record.Document, metadata, pgVector(record.Embedding)}
+		for _, column := range c.columns {
+			args = append(args, pgColumnValue(column, metadata[column.Key]))
+		}
+		batch.Queue(statement, args...)
+	}
+	return pgStoreError("writing records", c.pool.SendBatch(ctx, batch).Close())
+}
+
+func (c *pgCollection) Query(ctx context.Context, text string, nResults int, where map[string]interface{}) (CollectionResults, error) {
+	embedding, err := c.embeddings.EmbedQuery(ctx, text)
+	if err != nil {
+		return CollectionResults{}, &EmbeddingError{Err: err}
+	}
+
+	args := []interface{}{pgVector(embeddingValues(embedding)), nResults}
+	condition, err := pgWhere(where, c.columns, &args)
+	if err != nil {
+		return CollectionResults{}, err
+	}
+	// the HNSW index only finds hnsw.ef_search neighbours, and a filter is applied to those
+	// afterwards, so a filtered query scans every row to get all of its matches, and an unfiltered
+	// one has the index look for as many neighbours as it returns
+	setting := "SET LOCAL enable_indexscan = off"
+	if condition == "TRUE" && nResults <= pgMaxEfSearch {
+		setting = fmt.Sprintf("SET LOCAL hnsw.ef_search = %d", max(nResults, pgDefaultEfSearch))
+	}
+	tx, err := c.pool.Begin(ctx)
+	if err != nil {
+		return CollectionResults{}, pgStoreError("querying records", err)
+	}
+	defer tx.Rollback(ctx)
+	if _, err := tx.Exec(ctx, setting); err != nil {
+		return CollectionResults{}, pgStoreError("querying records", err)
+	}
+	// squared like the distances of Chroma's l2 space, so that the lookup thresholds mean the same
+	rows, err := tx.Query(ctx, `SELECT id, document, metadata, power(embedding <-> $1::text::vector, 2)
+		FROM `+c.table+` WHERE `+condition+` ORDER BY embedding <-> $1::text::vector LIMIT $2`, args...)
+	if err != nil {
+		return CollectionResults{}, pgStoreError("querying records", err)
+	}
+	results, err := scanPgResults(rows, true)
+	if err != nil {
+		return CollectionResults{}, err
+	}
+	return results, pgStoreError("querying records", tx.Commit(ctx))
+}
+
+func (c *pgCollection) Get(ctx context.Context, ids []string, where map[string]interface{}) (CollectionResults, error) {
+	var args []interface{}
+	condition, err := pgWhere(where, c.columns, &args)
+	if err != nil {
+		return CollectionResults{}, err
+	}
+	order := "seq"
+	if len(ids) > 0 {
+		args = append(args, ids)
+		condition += fmt.Sprintf(" AND id = ANY($%d::text[])", len(args))
+		order = fmt.Sprintf("array_position($%d::text[], id)", len(args))
+	}
+	rows, err := c.pool.Query(ctx, `SELECT id, document, metadata FROM `+c.table+` WHERE `+condition+` ORDER BY `+order, args...)
+	if err != nil {
+		return CollectionResults{}, pgStoreError("getting records", err)
+	}
+	return scanPgResults(rows, false)
+}
+
+func scanPgResults(rows pgx.Rows, withDistances bool) (CollectionResults, error) {
+	defer rows.Close()
+	var results CollectionResults
+	for rows.Next() {
+		var id, document string
+		var metadata map[string]interface{}
+		var distance float64
+		dest := []interface{}{&id, &document, &metadata}
+		if withDistances {
+			dest = append(dest, &distance)
+		}
+		if err := rows.Scan(dest...); err != nil {
+			return CollectionResults{}, pgStoreError("reading records", err)
+		}
+		results.IDs = append(results.IDs, id)
+		results.Documents = append(results.Documents, document)
+		results.Metadatas = append(results.Metadatas, metadata)
+		if withDistances {
+			results.Distances = append(results.Distances, float32(distance))
+		}
+	}
+	return results, pgStoreError("reading records", rows.Err())
+}
+
+// a vector in pgvector's text form, e.g. [0.1,0.2]
+func pgVector(values []float32) string {
+	parts := make([]string, len(values))
+	for i, v := range values {
+		parts[i] = strconv.FormatFloat(float64(v), 'g', -1, 32)
+	}
+	return "[" + strings.Join(parts, ",") + "]"
+}
+
+// reads a vector from its text form
+func parsePgVector(text string) ([]float32, error) {
+	text = strings.TrimSuffix(strings.TrimPrefix(text, "["), "]")
+	if text == "" {
+		return nil, nil
+	}
+	parts := strings.Split(text, ",")
+	values := make([]float32, len(parts))
+	for i, part := range parts {
+		v, err := strconv.ParseFloat(part, 32)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vector: %w", err)
+		}
+		values[i] = float32(v)
+	}
+	return values, nil
+}
+
+// the text a metadata value is written to its column as, or nil for NULL when it has no value of
+// the column's type, e.g. the begin time of an online course
+func pgColumnValue(column pgColumn, value interface{}) interface{} {
+	if value == nil {
+		return nil
+	}
+	text := strings.TrimSpace(fmt.Sprint(value))
+	switch column.Type {
+	case "time":
+		clock, err := pgTime(text)
+		if err != nil {
+			return nil
+		}
+		return clock
+	case "integer":
+		if _, err := strconv.Atoi(text); err != nil {
+			return nil
+		}
+	}
+	return text
+}
+
+// a time of the schedule, e.g. 1440, as Postgres reads it, e.g. 14:40
+func pgTime(clock string) (string, error) {
+	if len(clock) != 4 {
+		return "", fmt.Errorf("'%s' is not a time like 1440", clock)
+	}
+	hours, err := strconv.Atoi(clock[:2])
+	if err != nil || hours > 23 {
+		return "", fmt.Errorf("'%s' is not a time like 1440", clock)
+	}
+	minutes, err := strconv.Atoi(clock[2:])
+	if err != nil || minutes > 59 {
+		return "", fmt.Errorf("'%s' is not a time like 1440", clock)
+	}
+	return fmt.Sprintf("%02d:%02d", hours, minutes), nil
+}
+
+// the SQL operators of the where filter's comparisons
+var pgOperators = map[string]string{"$eq": "=", "$ne": "IS DISTINCT FROM", "$gt": ">", "$gte": ">=", "$lt": "<", "$lte": "<="}
+
+// translates a Chroma where filter into a SQL condition, appending its values to args. Keys with a
+// column are compared as the column's type, and any other key as text from the metadata.
+func pgWhere(where map[string]interface{}, columns []pgColumn, args *[]interface{}) (string, error) {
+	if len(where) == 0 {
+		return "TRUE", nil
+	}
+	// sorted so that the same filter always makes the same statement
+	keys := make([]string, 0, len(where))
+	for key := range where {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var conditions []string
+	for _, key := range keys {
+		condition := where[key]
+		switch key {
+		case "$and", "$or":
+			var parts []string
+			for _, sub := range whereConditions(condition) {
+				part, err := pgWhere(sub, columns, args)
+				if err != nil {
+					return "", err
+				}
+				parts = append(parts, "("+part+")")
+			}
+			if len(parts) == 0 {
+				parts = []string{"TRUE"}
+			}
+			conditions = append(conditions, "("+strings.Join(parts, strings.ToUpper(" "+strings.TrimPrefix(key, "$")+" "))+")")
+		default:
+			part, err := pgField(key, condition, columns, args)
+			if err != nil {
+				return "", err
+			}
+			conditions = append(conditions, part)
+		}
+	}
+	return strings.Join(conditions, " AND "), nil
+}
+
+// the condition on one metadata key, a literal or operators such as {"$in": [...]}
+func pgField(key string, condition interface{}, columns []pgColumn, args *[]interface{}) (string, error) {
+	expression, columnType := "", "text"
+	for _, column := range columns {
+		if column.Key == key {
+			expression, columnType = pgx.Identifier{column.Name}.Sanitize(), column.Type
+		}
+	}
+	if expression == "" {
+		*args = append(*args, key)
+		expression = fmt.Sprintf("(metadata ->> $%d::text)", len(*args))
+	}
+
+	// the placeholder of a value, converted to the column's type
+	value := func(operand interface{}) (string, error) {
+		text := fmt.Sprint(operand)
+		if columnType == "time" {
+			var err error
+			if text, err = pgTime(text); err != nil {
+				return "", fmt.Errorf("invalid %s: %w", key, err)
+			}
+		}
+		*args = append(*args, text)
+		return fmt.Sprintf("$%d::text::%s", len(*args), columnType), nil
+	}
+
+	operators, ok := condition.(map[string]interface{})
+	if !ok {
+		operators = map[string]interface{}{"$eq": condition}
+	}
+	names := make([]string, 0, len(operators))
+	for operator := range operators {
+		names = append(names, operator)
+	}
+	sort.Strings(names)
+
+	var parts []string
+	for _, operator := range names {
+		operand := operators[operator]
+		switch operator {
+		case "$in", "$nin":
+			var placeholders []string
+			for _, candidate := range operandList(operand) {
+				placeholder, err := value(candidate)
+				if err != nil {
+					return "", err
+				}
+				placeholders = append(placeholders, placeholder)
+			}
+			in := "FALSE"
+			if len(placeholders) > 0 {
+				in = fmt.Sprintf("COALESCE(%s IN (%s), FALSE)", expression, strings.Join(placeholders, ", "))
+			}
+			if operator == "$nin" {
+				in = "NOT " + in
+			}
+			parts = append(parts, in)
+		default:
+			sqlOperator, known := pgOperators[operator]
+			if !known {
+				return "", fmt.Errorf("unsupported where operator '%s'", operator)
+			}
+			placeholder, err := value(operand)
+			if err != nil {
+				return "", err
+			}
+			parts = append(parts, expression+" "+sqlOperator+" "+placeholder)
+		}
+	}
+	return strings.Join(parts, " AND "), nil
+}
+
+// classifies the error of a Postgres request like storeError does for Chroma: the server being out
+// of reach or shutting down becomes StoreUnavailableError, missing tables and duplicate keys become
+// a StoreError of their kind, and anything else is returned as it is
+func pgStoreError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch {
+		case pgErr.Code == "42P01": // undefined_table
+			return &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: err}
+		case pgErr.Code == "23505": // unique_violation
+			return &StoreError{Kind: ErrDuplicateID, Op: op, Err: err}
+		case strings.HasPrefix(pgErr.Code, "08"), strings.HasPrefix(pgErr.Code, "53"), strings.HasPrefix(pgErr.Code, "57P"):
+			// connection exceptions, insufficient resources and the server shutting down
+			return &StoreUnavailableError{Op: op, Err: err}
+		}
+		return err
+	}
+	var connectErr *pgconn.ConnectError
+	var netErr net.Error
+	if errors.As(err, &connectErr) || errors.As(err, &netErr) || pgconn.Timeout(err) {
+		return &StoreUnavailableError{Op: op, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,400 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+// where the prerequisite graph is saved after a catalog is ingested so it can be loaded on startup
+const prereqGraphFile = "prereq-graph.json"
+
+// how deep to explain the prerequisites of a missing prerequisite
+const maxEvidenceDepth = 4
+
+// a course code such as "CS 110", "cs110" or "MATH 109L"
+var courseCodePattern = regexp.MustCompile(`(?i)^([A-Z]{2,5})\s*-?\s*(\d{2,4}[A-Z]?)$`)
+
+// a course number on its own such as "112" or "272L", which borrows the subject written before it
+var courseNumberPattern = regexp.MustCompile(`(?i)^\d{2,4}[A-Z]?$`)
+
+// phrases in catalog text that contain "or"/"and" without being part of the requirement
+var prereqNoise = []*regexp.Regexp{
+	regexp.MustCompile(`(?i)with a (minimum )?grade of [A-F][+-]? or (better|higher|above)`),
+	regexp.MustCompile(`(?i)(or|and) (permission|consent) of (the )?(instructor|department|chair)`),
+	regexp.MustCompile(`(?i)or equivalent`),
+	regexp.MustCompile(`(?i)pre-?requisites?:?|co-?requisites?:?`),
+}
+
+// a token of prerequisite text: a course code, "and", "or", "(" or ")"
+var prereqTokenPattern = regexp.MustCompile(`(?i)\(|\)|,|;|\band\b|\bor\b|[A-Z]{2,5}\s*-?\s*\d{2,4}[A-Z]?\b|\b\d{2,4}[A-Z]?\b`)
+
+// requirement is a node of a prerequisite expression: either a single course or an and/or of children
+type requirement struct {
+	Course   string         `json:"course,omitempty"`
+	Op       string         `json:"op,omitempty"`
+	Children []*requirement `json:"children,omitempty"`
+}
+
+// PrereqGraph maps each course code to the courses it requires before and alongside it
+type PrereqGraph struct {
+	Prerequisites map[string]*requirement `json:"prerequisites"`
+	Corequisites  map[string]*requirement `json:"corequisites"`
+}
+
+// a course with its prerequisites written as a list of groups: every group is required and any
+// course within a group satisfies it, e.g. [["CS 221"], ["CS 245", "CS 210"]]
+type structuredPrereq struct {
+	Course        string     `json:"course"`
+	Prerequisites [][]string `json:"prerequisites"`
+	Corequisites  [][]string `json:"corequisites"`
+}
+
+// turns "cs110", "CS-110" or "Cs 110" into "CS 110"
+func normalizeCourseCode(code string) (string, bool) {
+	matches := courseCodePattern.FindStringSubmatch(strings.TrimSpace(code))
+	if matches == nil {
+		return "", false
+	}
+	return catalogKey(matches[1], matches[2]), true
+}
+
+func newPrereqGraph() *PrereqGraph {
+	return &PrereqGraph{
+		Prerequisites: make(map[string]*requirement),
+		Corequisites:  make(map[string]*requirement),
+	}
+}
+
+// builds the graph from the prerequisite and corequisite text of each catalog entry
+func BuildPrereqGraph(catalog map[string]CatalogEntry) *PrereqGraph {
+	graph := newPrereqGraph()
+	for key, entry := range catalog {
+		subject := strings.ToUpper(strings.TrimSpace(entry.Subject))
+		if req := parseRequirement(entry.Prerequisites, subject); req != nil {
+			graph.Prerequisites[key] = req
+		}
+		if req := parseRequirement(entry.Corequisites, subject); req != nil {
+			graph.Corequisites[key] = req
+		}
+	}
+	return graph
+}
+
+// parses free-text such as "CS 110 and (MATH 109 or 112)" into a requirement. Bare course numbers
+// borrow the most recent subject, and commas are read as "and".
+func parseRequirement(text, defaultSubject string) *requirement {
+	for _, noise := range prereqNoise {
+		text = noise.ReplaceAllString(text, " ")
+	}
+
+	var tokens []string
+	subject := defaultSubject
+	for _, token := range prereqTokenPattern.FindAllString(text, -1) {
+		lower := strings.ToLower(token)
+		switch {
+		case lower == "and" || lower == "," || lower == ";":
+			tokens = append(tokens, "and")
+		case lower == "or" || lower == "(" || lower == ")":
+			tokens = append(tokens, lower)
+		case courseNumberPattern.MatchString(token):
+			if subject == "" {
+				continue
+			}
+			tokens = append(tokens, catalogKey(subject, token))
+		default:
+			code, ok := normalizeCourseCode(token)
+			if !ok {
+				continue
+			}
+			subject = strings.Fields(code)[0]
+			tokens = append(tokens, code)
+		}
+	}
+
+	p := &requirementParser{tokens: tokens}
+	return p.parseOr()
+}
+
+// a recursive-descent parser where "and" binds tighter than "or"
+type requirementParser struct {
+	tokens []string
+	pos    int
+}
+
+func (p *requirementParser) peek() string {
+	if p.pos >= len(p.tokens) {
+		return ""
+	}
+	return p.tokens[p.pos]
+}
+
+func (p *requirementParser) parseOr() *requirement {
+	return p.parseList("or", p.parseAnd)
+}
+
+func (p *requirementParser) parseAnd() *requirement {
+	return p.parseList("and", p.parseFactor)
+}
+
+// parses operands joined by op, skipping dangling connectors left behind by removed noise
+func (p *requirementParser) parseList(op string, operand func() *requirement) *requirement {
+	var children []*requirement
+	for {
+		for p.peek() == op {
+			p.pos++
+		}
+		child := operand()
+		if child == nil {
+			break
+		}
+		children = append(children, child)
+		if p.peek() != op {
+			break
+		}
+	}
+	return combine(op, children)
+}
+
+func (p *requirementParser) parseFactor() *requirement {
+	switch token := p.peek(); token {
+	case "", ")", "and", "or":
+		return nil
+	case "(":
+		p.pos++
+		req := p.parseOr()
+		if p.peek() == ")" {
+			p.pos++
+		}
+		return req
+	default:
+		p.pos++
+		return &requirement{Course: token}
+	}
+}
+
+// collapses a list of operands into a single node, flattening nested nodes of the same op
+func combine(op string, children []*requirement) *requirement {
+	var flat []*requirement
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+		if child.Op == op {
+			flat = append(flat, child.Children...)
+		} else {
+			flat = append(flat, child)
+		}
+	}
+	switch len(flat) {
+	case 0:
+		return nil
+	case 1:
+		return flat[0]
+	}
+	return &requirement{Op: op, Children: flat}
+}
+
+// turns structured groups into a requirement: every group is required, any course in a group satisfies it
+func requirementFromGroups(groups [][]string) *requirement {
+	var all []*requirement
+	for _, group := range groups {
+		var any []*requirement
+		for _, code := range group {
+			if normalized, ok := normalizeCourseCode(code); ok {
+				any = append(any, &requirement{Course: normalized})
+			}
+		}
+		all = append(all, combine("or", any))
+	}
+	return combine("and", all)
+}
+
+// reads a structured prerequisite file and merges it into the graph, replacing existing entries
+func (graph *PrereqGraph) mergeStructuredFile(filePath string) error {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to open prerequisite file: %v", err)
+	}
+
+	var entries []structuredPrereq
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return fmt.Errorf("failed to unmarshal prerequisite file: %v", err)
+	}
+
+	for _, entry := range entries {
+		code, ok := normalizeCourseCode(entry.Course)
+		if !ok {
+			return fmt.Errorf("invalid course code '%s' in prerequisite file", entry.Course)
+		}
+		if req := requirementFromGroups(entry.Prerequisites); req != nil {
+			graph.Prerequisites[code] = req
+		}
+		if req := requirementFromGroups(entry.Corequisites); req != nil {
+			graph.Corequisites[code] = req
+		}
+	}
+	return nil
+}
+
+// writes the graph to disk as JSON
+func (graph *PrereqGraph) Save(filePath string) error {
+	data, err := json.Marshal(graph)
+	if err != nil {
+		return fmt.Errorf("failed to marshal prerequisite graph: %w", err)
+	}
+	if err := os.WriteFile(filePath, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write prerequisite graph: %w", err)
+	}
+	return nil
+}
+
+// reads a graph previously written by Save
+func LoadPrereqGraph(filePath string) (*PrereqGraph, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	graph := newPrereqGraph()
+	if err := json.Unmarshal(data, graph); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal prerequisite graph: %w", err)
+	}
+	return graph, nil
+}
+
+// renders a requirement as readable text, e.g. "CS 221 and (CS 245 or CS 210)"
+func (req *requirement) String() string {
+	if req == nil {
+		return "nothing"
+	}
+	if req.Course != "" {
+		return req.Course
+	}
+	parts := make([]string, len(req.Children))
+	for i, child := range req.Children {
+		parts[i] = child.String()
+		if child.Op != "" {
+			parts[i] = "(" + parts[i] + ")"
+		}
+	}
+	return strings.Join(parts, " "+req.Op+" ")
+}
+
+// Eligibility is the outcome of checking one course against the courses a student has completed
+type Eligibility struct {
+	Course                string   `json:"course"`
+	Eligible              bool     `json:"eligible"`
+	Prerequisites         string   `json:"prerequisites"`
+	MissingPrerequisites  []string `json:"missingPrerequisites,omitempty"`
+	Corequisites          string   `json:"corequisites,omitempty"`
+	MissingCorequisites   []string `json:"missingCorequisitesToTakeConcurrently,omitempty"`
+	Evidence              []string `json:"evidence"`
+	Sections              []Course `json:"sections,omitempty"`
+	PrerequisiteDataFound bool     `json:"prerequisiteDataFound"`
+}
+
+// checks whether a student who completed the given courses may take the course, recording the
+// path through the graph that led to the answer
+func (graph *PrereqGraph) CheckEligibility(course string, completed map[string]bool) Eligibility {
+	result := Eligibility{Course: course, Prerequisites: "none"}
+
+	req, hasPrereqs := graph.Prerequisites[course]
+	coreq, hasCoreqs := graph.Corequisites[course]
+	result.PrerequisiteDataFound = hasPrereqs || hasCoreqs
+
+	if !hasPrereqs {
+		result.Eligible = true
+		if result.PrerequisiteDataFound {
+			result.Evidence = append(result.Evidence, course+" has no prerequisites")
+		} else {
+			result.Evidence = append(result.Evidence, course+" has no prerequisite information in the catalog")
+		}
+	} else {
+		result.Prerequisites = req.String()
+		result.Evidence = append(result.Evidence, course+" requires "+req.String())
+		satisfied, missing, evidence := graph.evaluate(req, completed, []string{course}, map[string]bool{course: true})
+		result.Eligible = satisfied
+		result.MissingPrerequisites = missing
+		result.Evidence = append(result.Evidence, evidence...)
+	}
+
+	if hasCoreqs {
+		result.Corequisites = coreq.String()
+		satisfied, missing, evidence := graph.evaluate(coreq, completed, []string{course + " (corequisite)"}, map[string]bool{course: true})
+		if !satisfied {
+			result.MissingCorequisites = missing
+		}
+		result.Evidence = append(result.Evidence, evidence...)
+	}
+
+	return result
+}
+
+// evaluates a requirement, returning whether it is met, what is missing and one evidence line per
+// course visited. For a missing course the courses it requires are explained too, so the student
+// can see the whole chain.
+func (graph *PrereqGraph) evaluate(req *requirement, completed map[string]bool, path []string, visiting map[string]bool) (bool, []string, []string) {
+	if req.Course != "" {
+		chain := strings.Join(append(append([]string{}, path...), req.Course), " → ")
+		if completed[req.Course] {
+			return true, nil, []string{chain + ": completed"}
+		}
+
+		evidence := []string{chain + ": not completed"}
+		next, hasPrereqs := graph.Prerequisites[req.Course]
+		if hasPrereqs && len(path) < maxEvidenceDepth && !visiting[req.Course] {
+			visiting[req.Course] = true
+			_, _, nested := graph.evaluate(next, completed, append(append([]string{}, path...), req.Course), visiting)
+			delete(visiting, req.Course)
+			evidence = append(evidence, nested...)
+		}
+		return false, []string{req.Course}, evidence
+	}
+
+	var (
+		missing  []string
+		evidence []string
+		met      int
+	)
+	for _, child := range req.Children {
+		satisfied, childMissing, childEvidence := graph.evaluate(child, completed, path, visiting)
+		evidence = append(evidence, childEvidence...)
+		if satisfied {
+			met++
+		} else if req.Op == "and" {
+			missing = append(missing, childMissing...)
+		}
+	}
+
+	if req.Op == "or" {
+		if met > 0 {
+			return true, nil, evidence
+		}
+		// any one of the alternatives would do, so report them together
+		return false, []string{req.String()}, evidence
+	}
+	return met == len(req.Children), missing, evidence
+}
+
+// normalizes a list of completed courses into a set, carrying the subject over to bare numbers
+// so that "CS 110, 112" means CS 110 and CS 112
+func completedCourseSet(courses []string) (map[string]bool, []string) {
+	completed := make(map[string]bool)
+	var invalid []string
+	subject := ""
+	for _, course := range courses {
+		course = strings.TrimSpace(course)
+		if code, ok := normalizeCourseCode(course); ok {
+			completed[code] = true
+			subject = strings.Fields(code)[0]
+		} else if courseNumberPattern.MatchString(course) && subject != "" {
+			completed[catalogKey(subject, course)] = true
+		} else {
+			invalid = append(invalid, course)
+		}
+	}
+	return completed, invalid
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParseRequirement(t *testing.T) {
+	tests := []struct {
+		name     string
+		text     string
+		subject  string
+		expected string
+	}{
+		{name: "single course", text: "CS 110", subject: "CS", expected: "CS 110"},
+		{name: "bare numbers borrow the subject", text: "CS 110 and 112", subject: "CS", expected: "CS 110 and CS 112"},
+		{name: "and binds tighter than or", text: "CS 221 and CS 245 or CS 210", subject: "CS", expected: "(CS 221 and CS 245) or CS 210"},
+		{name: "parentheses", text: "CS 221 and (MATH 201 or MATH 202)", subject: "CS", expected: "CS 221 and (MATH 201 or MATH 202)"},
+		{name: "grade noise", text: "Prerequisite: CS 112 with a grade of C or better", subject: "CS", expected: "CS 112"},
+		{name: "permission noise", text: "CS 245 or permission of instructor", subject: "CS", expected: "CS 245"},
+		{name: "commas", text: "cs110, cs112, MATH 201", subject: "CS", expected: "CS 110 and CS 112 and MATH 201"},
+		{name: "nothing", text: "None", subject: "CS", expected: "nothing"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if got := parseRequirement(test.text, test.subject).String(); got != test.expected {
+				t.Errorf("parseRequirement(%q) = %q, expected %q", test.text, got, test.expected)
+			}
+		})
+	}
+}
+
+func TestCheckEligibility(t *testing.T) {
+	graph := BuildPrereqGraph(map[string]CatalogEntry{
+		"CS 112": {Subject: "CS", CourseNumber: "112", Prerequisites: "CS 110"},
+		"CS 245": {Subject: "CS", CourseNumber: "245", Prerequisites: "CS 112"},
+		"CS 315": {Subject: "CS", CourseNumber: "315", Prerequisites: "CS 221 and CS 245", Corequisites: "CS 315L"},
+		"CS 221": {Subject: "CS", CourseNumber: "221", Prerequisites: "CS 112 or CS 210"},
+	})
+
+	tests := []struct {
+		name      string
+		course    string
+		completed []string
+		eligible  bool
+		missing   []string
+		evidence  []string
+	}{
+		{
+			name:      "eligible",
+			course:    "CS 315",
+			completed: []string{"CS 110", "112", "CS 221", "CS 245"},
+			eligible:  true,
+			evidence: []string{
+				"CS 315 requires CS 221 and CS 245",
+				"CS 315 → CS 221: completed",
+				"CS 315 → CS 245: completed",
+				"CS 315 (corequisite) → CS 315L: not completed",
+			},
+		},
+		{
+			name:      "missing prerequisites explained down the graph",
+			course:    "CS 315",
+			completed: []string{"CS 110", "CS 112"},
+			eligible:  false,
+			missing:   []string{"CS 221", "CS 245"},
+			evidence: []string{
+				"CS 315 requires CS 221 and CS 245",
+				"CS 315 → CS 221: not completed",
+				"CS 315 → CS 221 → CS 112: completed",
+				"CS 315 → CS 221 → CS 210: not completed",
+				"CS 315 → CS 245: not completed",
+				"CS 315 → CS 245 → CS 112: completed",
+				"CS 315 (corequisite) → CS 315L: not completed",
+			},
+		},
+		{
+			name:      "no prerequisite data",
+			course:    "MUS 100",
+			completed: nil,
+			eligible:  true,
+			evidence:  []string{"MUS 100 has no prerequisite information in the catalog"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			completed, invalid := completedCourseSet(test.completed)
+			if len(invalid) != 0 {
+				t.Fatalf("Unexpected invalid courses: %v", invalid)
+			}
+
+			result := graph.CheckEligibility(test.course, completed)
+			if result.Eligible != test.eligible {
+				t.Errorf("Eligible = %v, expected %v", result.Eligible, test.eligible)
+			}
+			if !reflect.DeepEqual(result.MissingPrerequisites, test.missing) {
+				t.Errorf("MissingPrerequisites = %v, expected %v", result.MissingPrerequisites, test.missing)
+			}
+			if !reflect.DeepEqual(result.Evidence, test.evidence) {
+				t.Errorf("Evidence = %#v, expected %#v", result.Evidence, test.evidence)
+			}
+		})
+	}
+}
+
+func TestMergeStructuredFile(t *testing.T) {
+	graph := newPrereqGraph()
+	if err := graph.mergeStructuredFile("testdata/prereqs.json"); err != nil {
+		t.Fatalf("Error reading prerequisite file: %v", err)
+	}
+
+	if got := graph.Prerequisites["CS 315"].String(); got != "CS 221 and (CS 245 or CS 210)" {
+		t.Errorf("CS 315 prerequisites = %q", got)
+	}
+	if got := graph.Corequisites["CS 315"].String(); got != "CS 315L" {
+		t.Errorf("CS 315 corequisites = %q", got)
+	}
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// the width of the bar in characters
+const progressBarWidth = 30
+
+// progressBar draws how many of the records of a collection have been written, and how long the
+// rest should take at the rate so far, on a single line that is redrawn as batches finish
+type progressBar struct {
+	out   io.Writer
+	label string
+	total int
+
+	mu      sync.Mutex
+	done    int
+	started time.Time
+	// records that were already written before the bar started, which don't count toward the rate
+	skipped int
+	now     func() time.Time
+}
+
+// a bar drawn to out, or nil to draw nothing
+func newProgressBar(out io.Writer, label string, total int) *progressBar {
+	if out == nil {
+		return nil
+	}
+	bar := &progressBar{out: out, label: label, total: total}
+	bar.started = bar.clock()
+	return bar
+}
+
+// counts records that were written before this run, e.g. by an ingestion that is being resumed
+func (p *progressBar) skip(records int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += records
+	p.skipped += records
+	p.draw()
+}
+
+func (p *progressBar) add(records int) {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.done += records
+	p.draw()
+}
+
+// ends the line the bar is drawn on
+func (p *progressBar) finish() {
+	if p == nil {
+		return
+	}
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	fmt.Fprintln(p.out)
+}
+
+func (p *progressBar) draw() {
+	fmt.Fprintf(p.out, "\r%s", p.line())
+}
+
+func (p *progressBar) line() string {
+	fraction := 1.0
+	if p.total > 0 {
+		fraction = float64(p.done) / float64(p.total)
+	}
+	filled := int(fraction * progressBarWidth)
+	bar := strings.Repeat("=", filled) + strings.Repeat(" ", progressBarWidth-filled)
+
+	eta := "--:--"
+	elapsed := p.clock().Sub(p.started)
+	if written := p.done - p.skipped; written > 0 && elapsed > 0 {
+		remaining := time.Duration(float64(elapsed) / float64(written) * float64(p.total-p.done))
+		eta = formatETA(remaining)
+	}
+	return fmt.Sprintf("%-12s [%s] %d/%d %3.0f%% ETA %s", p.label, bar, p.done, p.total, 100*fraction, eta)
+}
+
+func (p *progressBar) clock() time.Time {
+	if p.now != nil {
+		return p.now()
+	}
+	return time.Now()
+}
+
+// minutes and seconds, e.g. 02:05
+func formatETA(d time.Duration) string {
+	seconds := int(d.Round(time.Second).Seconds())
+	return fmt.Sprintf("%02d:%02d", seconds/60, seconds%60)
+}
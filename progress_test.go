@@ -0,0 +1,36 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestProgressBar(t *testing.T) {
+	seconds := 0
+	bar := &progressBar{label: "usf-courses", total: 2000}
+	bar.now = func() time.Time { return time.Unix(int64(seconds), 0) }
+	bar.started = bar.clock()
+
+	tests := []struct {
+		name    string
+		seconds int
+		skip    int
+		add     int
+		line    string
+	}{
+		{"before anything is written", 0, 0, 0, "usf-courses  [                              ] 0/2000   0% ETA --:--"},
+		{"resumed batches don't count toward the rate", 0, 500, 0, "usf-courses  [=======                       ] 500/2000  25% ETA --:--"},
+		{"rate of the batches written", 10, 0, 500, "usf-courses  [===============               ] 1000/2000  50% ETA 00:20"},
+		{"done", 40, 0, 1000, "usf-courses  [==============================] 2000/2000 100% ETA 00:00"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			seconds = test.seconds
+			bar.done += test.skip + test.add
+			bar.skipped += test.skip
+			if line := bar.line(); line != test.line {
+				t.Errorf("Expected %q, got %q", test.line, line)
+			}
+		})
+	}
+}
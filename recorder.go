@@ -0,0 +1,260 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// what the HTTP client does with the traffic to OpenAI and Chroma
+const (
+	httpLive   = "live"
+	httpRecord = "record"
+	httpReplay = "replay"
+)
+
+var httpModes = []string{httpLive, httpRecord, httpReplay}
+
+// characters that can't be used in a fixture file name
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9]+`)
+
+// RecordingTransport sends requests through Next, saving every exchange to a fixture file when
+// recording, or answers them from the fixture files without any network when replaying. Requests
+// are matched on their method, URL and normalized body.
+type RecordingTransport struct {
+	Mode string
+	Dir  string
+	Next http.RoundTripper
+
+	mu sync.Mutex
+	// fixtures written during this recording, which later exchanges are appended to
+	recorded map[string]bool
+	// the number of times each fixture has been replayed
+	replayed map[string]int
+}
+
+// httpFixture is every response recorded for one request; repeated requests are served in order
+type httpFixture struct {
+	Method    string            `json:"method"`
+	URL       string            `json:"url"`
+	Body      json.RawMessage   `json:"body,omitempty"`
+	Responses []fixtureResponse `json:"responses"`
+}
+
+type fixtureResponse struct {
+	Status      int    `json:"status"`
+	ContentType string `json:"contentType,omitempty"`
+	// kept so that a replayed rate limit is retried after the same wait as when it was recorded
+	RetryAfter string `json:"retryAfter,omitempty"`
+	// the body when it is JSON, kept as is so that fixtures are readable
+	JSON json.RawMessage `json:"json,omitempty"`
+	Text string          `json:"text,omitempty"`
+}
+
+// builds the HTTP client shared by the OpenAI and Chroma clients
+func newHTTPClient(mode, dir string) (*http.Client, error) {
+	switch mode {
+	case "", httpLive:
+		return &http.Client{Transport: newRetryTransport(nil)}, nil
+	case httpRecord, httpReplay:
+		if dir == "" {
+			return nil, fmt.Errorf("a fixture directory is needed to %s HTTP traffic", mode)
+		}
+		// retries are recorded like any other exchange, so a replay retries the same way
+		return &http.Client{Transport: newRetryTransport(&RecordingTransport{Mode: mode, Dir: dir})}, nil
+	}
+	return nil, fmt.Errorf("unknown HTTP mode '%s', expected one of %v", mode, httpModes)
+}
+
+func (t *RecordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+		req.Body = io.NopCloser(bytes.NewReader(body))
+	}
+
+	key, path := t.fixturePath(req, body)
+	if t.Mode == httpReplay {
+		return t.replay(req, key, path)
+	}
+
+	resp, err := t.next().RoundTrip(req)
+	if err != nil || t.Mode != httpRecord {
+		return resp, err
+	}
+	if err := t.record(req, body, resp, key, path); err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+	return resp, nil
+}
+
+func (t *RecordingTransport) next() http.RoundTripper {
+	if t.Next != nil {
+		return t.Next
+	}
+	return http.DefaultTransport
+}
+
+// the key a request is matched on and the fixture file it is stored in
+func (t *RecordingTransport) fixturePath(req *http.Request, body []byte) (string, string) {
+	key := req.Method + " " + normalizeURL(req) + "\n" + string(normalizeBody(body))
+	sum := sha256.Sum256([]byte(key))
+	name := strings.Trim(unsafeFileChars.ReplaceAllString(req.URL.Host+req.URL.Path, "-"), "-")
+	return key, filepath.Join(t.Dir, fmt.Sprintf("%s-%s.json", name, hex.EncodeToString(sum[:6])))
+}
+
+// the URL without its scheme, with the query parameters sorted
+func normalizeURL(req *http.Request) string {
+	url := req.URL.Host + req.URL.Path
+	if query := req.URL.Query().Encode(); query != "" {
+		url += "?" + query
+	}
+	return url
+}
+
+// JSON bodies are re-encoded so that key order and whitespace don't matter
+func normalizeBody(body []byte) []byte {
+	var value interface{}
+	if err := json.Unmarshal(body, &value); err != nil {
+		return bytes.TrimSpace(body)
+	}
+	normalized, err := json.Marshal(value)
+	if err != nil {
+		return bytes.TrimSpace(body)
+	}
+	return normalized
+}
+
+// saves the response, appending it to the fixture if the same request was already recorded
+func (t *RecordingTransport) record(req *http.Request, body []byte, resp *http.Response, key, path string) error {
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read response body: %w", err)
+	}
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.recorded == nil {
+		t.recorded = make(map[string]bool)
+	}
+
+	// a fixture left over from an earlier recording is replaced rather than appended to
+	fixture := httpFixture{Method: req.Method, URL: normalizeURL(req)}
+	if t.recorded[key] {
+		existing, err := readFixture(path)
+		if err != nil {
+			return err
+		}
+		fixture = existing
+	}
+	if normalized := normalizeBody(body); len(normalized) > 0 {
+		if json.Valid(normalized) {
+			fixture.Body = normalized
+		} else {
+			fixture.Body, _ = json.Marshal(string(normalized))
+		}
+	}
+
+	recorded := fixtureResponse{
+		Status:      resp.StatusCode,
+		ContentType: resp.Header.Get("Content-Type"),
+		RetryAfter:  resp.Header.Get("Retry-After"),
+	}
+	if len(bytes.TrimSpace(respBody)) > 0 && json.Valid(respBody) {
+		recorded.JSON = json.RawMessage(respBody)
+	} else {
+		recorded.Text = string(respBody)
+	}
+	fixture.Responses = append(fixture.Responses, recorded)
+
+	if err := os.MkdirAll(t.Dir, 0o755); err != nil {
+		return fmt.Errorf("failed to create fixture directory: %w", err)
+	}
+	data, err := json.MarshalIndent(fixture, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to marshal fixture: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		return fmt.Errorf("failed to write fixture: %w", err)
+	}
+	t.recorded[key] = true
+	return nil
+}
+
+// serves the next recorded response for the request; the last one is repeated once they run out
+func (t *RecordingTransport) replay(req *http.Request, key, path string) (*http.Response, error) {
+	fixture, err := readFixture(path)
+	if os.IsNotExist(err) {
+		return nil, fmt.Errorf("no recorded response for %s %s in '%s'", req.Method, normalizeURL(req), t.Dir)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(fixture.Responses) == 0 {
+		return nil, fmt.Errorf("fixture '%s' has no responses", path)
+	}
+
+	t.mu.Lock()
+	if t.replayed == nil {
+		t.replayed = make(map[string]int)
+	}
+	n := t.replayed[key]
+	t.replayed[key]++
+	t.mu.Unlock()
+	if n >= len(fixture.Responses) {
+		n = len(fixture.Responses) - 1
+	}
+	recorded := fixture.Responses[n]
+
+	body := []byte(recorded.Text)
+	if len(recorded.JSON) > 0 {
+		body = recorded.JSON
+	}
+	header := make(http.Header)
+	if recorded.ContentType != "" {
+		header.Set("Content-Type", recorded.ContentType)
+	}
+	if recorded.RetryAfter != "" {
+		header.Set("Retry-After", recorded.RetryAfter)
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", recorded.Status, http.StatusText(recorded.Status)),
+		StatusCode:    recorded.Status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        header,
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+func readFixture(path string) (httpFixture, error) {
+	var fixture httpFixture
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fixture, err
+	}
+	if err := json.Unmarshal(data, &fixture); err != nil {
+		return fixture, fmt.Errorf("failed to parse fixture '%s': %w", path, err)
+	}
+	return fixture, nil
+}
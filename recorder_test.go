@@ -0,0 +1,113 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRecordingTransportRecordAndReplay(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		body, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, `{"call": %d, "path": %q, "body": %q}`, calls, r.URL.Path, string(body))
+	}))
+	dir := t.TempDir()
+
+	send := func(client *http.Client, path, body string) (string, error) {
+		resp, err := client.Post(server.URL+path, "application/json", strings.NewReader(body))
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := io.ReadAll(resp.Body)
+		return string(data), err
+	}
+
+	recorder, err := newHTTPClient(httpRecord, dir)
+	if err != nil {
+		t.Fatalf("Error creating recorder: %v", err)
+	}
+	var recorded []string
+	for _, request := range []struct{ path, body string }{
+		{"/embeddings", `{"input": ["CS 272"], "model": "ada"}`},
+		{"/embeddings", `{"input": ["CS 272"], "model": "ada"}`},
+		{"/chat", `{"messages": []}`},
+	} {
+		resp, err := send(recorder, request.path, request.body)
+		if err != nil {
+			t.Fatalf("Error recording: %v", err)
+		}
+		recorded = append(recorded, resp)
+	}
+	server.Close()
+
+	replayer, err := newHTTPClient(httpReplay, dir)
+	if err != nil {
+		t.Fatalf("Error creating replayer: %v", err)
+	}
+	tests := []struct {
+		name     string
+		path     string
+		body     string
+		expected string
+	}{
+		{"first response", "/embeddings", `{"model": "ada", "input": ["CS 272"]}`, recorded[0]},
+		{"repeated request gets the next response", "/embeddings", `{"input":["CS 272"],"model":"ada"}`, recorded[1]},
+		{"last response is repeated", "/embeddings", `{"input": ["CS 272"], "model": "ada"}`, recorded[1]},
+		{"other request", "/chat", `{"messages": []}`, recorded[2]},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			resp, err := send(replayer, test.path, test.body)
+			if err != nil {
+				t.Fatalf("Error replaying: %v", err)
+			}
+			// fixtures keep JSON bodies readable, so only the JSON value is replayed exactly
+			if string(normalizeBody([]byte(resp))) != string(normalizeBody([]byte(test.expected))) {
+				t.Errorf("Expected %s, got %s", test.expected, resp)
+			}
+		})
+	}
+
+	if _, err := send(replayer, "/embeddings", `{"input": ["CS 315"], "model": "ada"}`); err == nil {
+		t.Errorf("Expected an error for a request that was never recorded")
+	}
+}
+
+func TestRecordingTransportReplaysRetryAfter(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Retry-After", "3")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	dir := t.TempDir()
+
+	// without a retrying transport in front, so that the 429 itself is recorded and replayed
+	for _, mode := range []string{httpRecord, httpReplay} {
+		client := &http.Client{Transport: &RecordingTransport{Mode: mode, Dir: dir}}
+		resp, err := client.Get(server.URL + "/embeddings")
+		if err != nil {
+			t.Fatalf("Error in %s mode: %v", mode, err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusTooManyRequests || resp.Header.Get("Retry-After") != "3" {
+			t.Errorf("Expected a 429 with Retry-After 3 in %s mode, got %d with %q", mode, resp.StatusCode, resp.Header.Get("Retry-After"))
+		}
+		// the replay mustn't need the server
+		server.Close()
+	}
+}
+
+func TestNewHTTPClientRejectsUnknownModes(t *testing.T) {
+	if _, err := newHTTPClient("rewind", t.TempDir()); err == nil {
+		t.Errorf("Expected an error for an unknown mode")
+	}
+	if _, err := newHTTPClient(httpReplay, ""); err == nil {
+		t.Errorf("Expected an error for a missing fixture directory")
+	}
+}
@@ -0,0 +1,305 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// the defaults of the transport every request to OpenAI and Chroma goes through
+const (
+	// how long a single attempt of a request may take
+	defaultRequestTimeout = 60 * time.Second
+	defaultMaxAttempts    = 4
+	defaultBaseDelay      = 500 * time.Millisecond
+	// the longest wait between attempts; a server asking for a longer wait isn't retried
+	defaultMaxDelay = 20 * time.Second
+	// how many failed attempts in a row open a host's circuit, and for how long
+	defaultBreakerThreshold = 5
+	defaultBreakerCooldown  = 30 * time.Second
+)
+
+// returned without sending the request while the host's circuit is open
+var ErrCircuitOpen = errors.New("circuit breaker open")
+
+// RetryTransport sends requests through Next with a timeout per attempt, retrying rate limits,
+// server errors and network failures with exponential backoff and jitter, or after the wait the
+// server asks for in Retry-After. A host that keeps failing trips a circuit breaker, which fails
+// its requests straight away until the cooldown has passed and a trial request succeeds.
+type RetryTransport struct {
+	Next             http.RoundTripper
+	Timeout          time.Duration
+	MaxAttempts      int
+	BaseDelay        time.Duration
+	MaxDelay         time.Duration
+	BreakerThreshold int
+	BreakerCooldown  time.Duration
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker
+	// replaced in tests so that they don't have to wait
+	now    func() time.Time
+	sleep  func(ctx context.Context, d time.Duration) error
+	jitter func(d time.Duration) time.Duration
+}
+
+func newRetryTransport(next http.RoundTripper) *RetryTransport {
+	return &RetryTransport{
+		Next:             next,
+		Timeout:          defaultRequestTimeout,
+		MaxAttempts:      defaultMaxAttempts,
+		BaseDelay:        defaultBaseDelay,
+		MaxDelay:         defaultMaxDelay,
+		BreakerThreshold: defaultBreakerThreshold,
+		BreakerCooldown:  defaultBreakerCooldown,
+	}
+}
+
+// circuitBreaker counts the failed attempts in a row to one host
+type circuitBreaker struct {
+	failures  int
+	openUntil time.Time
+	// a trial request is in flight after the cooldown, and every other request waits for it
+	probing bool
+}
+
+func (t *RetryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// keep the body so that it can be sent again
+	var body []byte
+	if req.Body != nil {
+		var err error
+		body, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read request body: %w", err)
+		}
+	}
+
+	for attempt := 1; ; attempt++ {
+		if wait, allowed := t.allow(req.URL.Host); !allowed {
+			return nil, fmt.Errorf("%w: %s keeps failing, try again in %s", ErrCircuitOpen, req.URL.Host, wait.Round(time.Second))
+		}
+
+		resp, err := t.attempt(req, body)
+		retryable, delay := t.retryable(req.Context(), resp, err)
+		if err != nil && !retryable {
+			// the caller gave up or the request couldn't be sent, which says nothing about the host
+			t.release(req.URL.Host)
+			return nil, err
+		}
+		t.record(req.URL.Host, retryable)
+		if !retryable || attempt >= t.MaxAttempts {
+			return resp, err
+		}
+		if delay == 0 {
+			delay = t.backoff(attempt)
+		}
+		if delay > t.MaxDelay {
+			// the server wants a longer break than is worth waiting for in a conversation
+			return resp, err
+		}
+
+		if resp != nil {
+			io.Copy(io.Discard, resp.Body)
+			resp.Body.Close()
+		}
+		if err := t.wait(req.Context(), delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// sends the request once, giving up after the timeout
+func (t *RetryTransport) attempt(req *http.Request, body []byte) (*http.Response, error) {
+	ctx, cancel := context.WithTimeout(req.Context(), t.Timeout)
+	attempt := req.Clone(ctx)
+	if body != nil {
+		attempt.Body = io.NopCloser(bytes.NewReader(body))
+		attempt.GetBody = func() (io.ReadCloser, error) {
+			return io.NopCloser(bytes.NewReader(body)), nil
+		}
+	}
+
+	next := t.Next
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	resp, err := next.RoundTrip(attempt)
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	// the timeout covers reading the body too, so it is only released once the body is closed
+	resp.Body = &cancelOnClose{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+type cancelOnClose struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (c *cancelOnClose) Close() error {
+	err := c.ReadCloser.Close()
+	c.cancel()
+	return err
+}
+
+// whether the outcome of an attempt is worth retrying, and how long the server asked to wait
+func (t *RetryTransport) retryable(ctx context.Context, resp *http.Response, err error) (bool, time.Duration) {
+	if err != nil {
+		// the caller gave up, as opposed to an attempt timing out or failing to connect
+		if ctx.Err() != nil {
+			return false, 0
+		}
+		return isNetworkFailure(err), 0
+	}
+	switch resp.StatusCode {
+	case http.StatusTooManyRequests, http.StatusInternalServerError, http.StatusBadGateway,
+		http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return true, t.retryAfter(resp.Header.Get("Retry-After"))
+	}
+	return false, 0
+}
+
+// the wait a Retry-After header asks for, given in seconds or as a date
+func (t *RetryTransport) retryAfter(value string) time.Duration {
+	if value == "" {
+		return 0
+	}
+	if seconds, err := strconv.Atoi(value); err == nil && seconds >= 0 {
+		return time.Duration(seconds) * time.Second
+	}
+	if date, err := http.ParseTime(value); err == nil {
+		if wait := date.Sub(t.clock()); wait > 0 {
+			return wait
+		}
+	}
+	return 0
+}
+
+// the exponential wait before the next attempt, with jitter so that clients don't retry in lockstep
+func (t *RetryTransport) backoff(attempt int) time.Duration {
+	delay := t.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > t.MaxDelay {
+		delay = t.MaxDelay
+	}
+	if t.jitter != nil {
+		return t.jitter(delay)
+	}
+	// somewhere between half and all of the delay
+	return delay/2 + time.Duration(rand.Int63n(int64(delay/2)+1))
+}
+
+func (t *RetryTransport) wait(ctx context.Context, d time.Duration) error {
+	if t.sleep != nil {
+		return t.sleep(ctx, d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (t *RetryTransport) clock() time.Time {
+	if t.now != nil {
+		return t.now()
+	}
+	return time.Now()
+}
+
+// whether a request to the host may be sent, or how long until its circuit lets a trial request through
+func (t *RetryTransport) allow(host string) (time.Duration, bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	breaker := t.breaker(host)
+	if breaker.failures < t.BreakerThreshold {
+		return 0, true
+	}
+	if wait := breaker.openUntil.Sub(t.clock()); wait > 0 {
+		return wait, false
+	}
+	if breaker.probing {
+		return t.BreakerCooldown, false
+	}
+	breaker.probing = true
+	return 0, true
+}
+
+// counts a failed attempt towards opening the host's circuit, or closes it again after a success
+func (t *RetryTransport) record(host string, failed bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	breaker := t.breaker(host)
+	breaker.probing = false
+	if !failed {
+		breaker.failures = 0
+		return
+	}
+	breaker.failures++
+	if breaker.failures >= t.BreakerThreshold {
+		breaker.openUntil = t.clock().Add(t.BreakerCooldown)
+	}
+}
+
+// lets the next trial request through without counting this one
+func (t *RetryTransport) release(host string) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.breaker(host).probing = false
+}
+
+func (t *RetryTransport) breaker(host string) *circuitBreaker {
+	if t.breakers == nil {
+		t.breakers = make(map[string]*circuitBreaker)
+	}
+	if t.breakers[host] == nil {
+		t.breakers[host] = &circuitBreaker{}
+	}
+	return t.breakers[host]
+}
+
+// whether the error means OpenAI or Chroma is overloaded or out of reach, rather than that the
+// request was wrong, so that asking again later may work
+func isBusyError(err error) bool {
+	if errors.Is(err, ErrCircuitOpen) || errors.Is(err, ErrUnavailable) || errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+	busy := func(status int) bool {
+		return status == http.StatusTooManyRequests || status >= http.StatusInternalServerError
+	}
+	var apiErr *openai.APIError
+	if errors.As(err, &apiErr) {
+		return busy(apiErr.HTTPStatusCode)
+	}
+	var requestErr *openai.RequestError
+	if errors.As(err, &requestErr) {
+		return busy(requestErr.HTTPStatusCode)
+	}
+	// the request never got an answer; a bad URL or a request missing from the fixtures is not busy
+	return isNetworkFailure(err)
+}
+
+// whether the request timed out or the connection was refused or dropped
+func isNetworkFailure(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, syscall.ECONNREFUSED) || errors.Is(err, syscall.ECONNRESET) {
+		return true
+	}
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
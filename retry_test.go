@@ -0,0 +1,187 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+// a transport that doesn't wait, keeping the waits it was asked for
+func testRetryTransport(waits *[]time.Duration) *RetryTransport {
+	transport := newRetryTransport(nil)
+	transport.jitter = func(d time.Duration) time.Duration { return d }
+	transport.sleep = func(ctx context.Context, d time.Duration) error {
+		*waits = append(*waits, d)
+		return ctx.Err()
+	}
+	return transport
+}
+
+func TestRetryTransport(t *testing.T) {
+	type response struct {
+		status     int
+		retryAfter string
+	}
+	tests := []struct {
+		name      string
+		responses []response
+		status    int
+		calls     int
+		waits     []time.Duration
+	}{
+		{"success isn't retried", []response{{200, ""}}, 200, 1, nil},
+		{"client errors aren't retried", []response{{400, ""}}, 400, 1, nil},
+		{"rate limit waits for Retry-After", []response{{429, "3"}, {200, ""}}, 200, 2, []time.Duration{3 * time.Second}},
+		{"server errors back off exponentially", []response{{503, ""}, {502, ""}, {500, ""}, {200, ""}}, 200, 4,
+			[]time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second}},
+		{"gives up after the last attempt", []response{{503, ""}}, 503, defaultMaxAttempts,
+			[]time.Duration{500 * time.Millisecond, time.Second, 2 * time.Second}},
+		{"a long Retry-After isn't waited for", []response{{429, "120"}, {200, ""}}, 429, 1, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			calls := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				body, _ := io.ReadAll(r.Body)
+				if string(body) != `{"input": "CS 272"}` {
+					t.Errorf("Attempt %d sent body %q", calls+1, body)
+				}
+				resp := test.responses[min(calls, len(test.responses)-1)]
+				calls++
+				if resp.retryAfter != "" {
+					w.Header().Set("Retry-After", resp.retryAfter)
+				}
+				w.WriteHeader(resp.status)
+			}))
+			defer server.Close()
+
+			var waits []time.Duration
+			client := &http.Client{Transport: testRetryTransport(&waits)}
+			resp, err := client.Post(server.URL, "application/json", strings.NewReader(`{"input": "CS 272"}`))
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.status {
+				t.Errorf("Expected status %d, got %d", test.status, resp.StatusCode)
+			}
+			if calls != test.calls {
+				t.Errorf("Expected %d attempts, got %d", test.calls, calls)
+			}
+			if fmt.Sprint(waits) != fmt.Sprint(test.waits) {
+				t.Errorf("Expected waits %v, got %v", test.waits, waits)
+			}
+		})
+	}
+}
+
+func TestRetryTransportTimesOutAttempts(t *testing.T) {
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if calls == 1 {
+			<-r.Context().Done()
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	var waits []time.Duration
+	transport := testRetryTransport(&waits)
+	transport.Timeout = 50 * time.Millisecond
+	resp, err := (&http.Client{Transport: transport}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	resp.Body.Close()
+	if calls != 2 || resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected the timed out attempt to be retried, got %d attempts and status %d", calls, resp.StatusCode)
+	}
+}
+
+func TestRetryTransportCircuitBreaker(t *testing.T) {
+	healthy := false
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		if !healthy {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	now := time.Date(2024, 12, 15, 12, 0, 0, 0, time.UTC)
+	var waits []time.Duration
+	transport := testRetryTransport(&waits)
+	transport.MaxAttempts = 1
+	transport.now = func() time.Time { return now }
+	client := &http.Client{Transport: transport}
+
+	for i := 0; i < defaultBreakerThreshold; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Unexpected error: %v", err)
+		}
+		resp.Body.Close()
+	}
+	if _, err := client.Get(server.URL); !errors.Is(err, ErrCircuitOpen) {
+		t.Fatalf("Expected the circuit to be open, got %v", err)
+	}
+	if calls != defaultBreakerThreshold {
+		t.Errorf("Expected no request while the circuit is open, got %d", calls)
+	}
+
+	// after the cooldown a trial request goes through, and closes the circuit when it succeeds
+	now = now.Add(defaultBreakerCooldown)
+	healthy = true
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatalf("Expected the circuit to let requests through, got %v", err)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestIsBusyError(t *testing.T) {
+	tests := []struct {
+		name     string
+		err      error
+		expected bool
+	}{
+		{"open circuit", fmt.Errorf("%w: api.openai.com keeps failing", ErrCircuitOpen), true},
+		{"unavailable store", &StoreUnavailableError{Op: "querying", Err: errors.New("503")}, true},
+		{"timeout", fmt.Errorf("asking: %w", context.DeadlineExceeded), true},
+		{"rate limit", &openai.APIError{HTTPStatusCode: http.StatusTooManyRequests}, true},
+		{"server error", &openai.RequestError{HTTPStatusCode: http.StatusBadGateway}, true},
+		{"bad request", &openai.APIError{HTTPStatusCode: http.StatusBadRequest}, false},
+		{"missing collection", &CollectionMissingError{Name: "usf-courses"}, false},
+		{"canceled", context.Canceled, false},
+		{"connection refused", &url.Error{Op: "Post", URL: "http://localhost:8000", Err: &net.OpError{Op: "dial", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}}, true},
+		{"connection reset", &url.Error{Op: "Post", URL: "http://localhost:8000", Err: syscall.ECONNRESET}, true},
+		{"replay miss", &url.Error{Op: "Post", URL: "http://localhost:8000", Err: errors.New("no recorded response for POST localhost:8000/api/v2")}, false},
+		{"bad scheme", &url.Error{Op: "Get", URL: "htp://localhost:8000", Err: errors.New("unsupported protocol scheme \"htp\"")}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if busy := isBusyError(test.err); busy != test.expected {
+				t.Errorf("Expected isBusyError(%v) to be %v", test.err, test.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+
+	"github.com/amikos-tech/chroma-go/pkg/embeddings/openai"
+)
+
+// the version of what ingestion writes to the collections. Bump it and add a migration below
+// whenever parseCSVIntoDatabase changes the documents, metadata or IDs it writes.
+const collectionSchemaVersion = 5
+
+// the OpenAI model every document and query is embedded with, and the size of its vectors
+const (
+	embeddingModel     = openai.TextEmbeddingAda002
+	embeddingDimension = 1536
+)
+
+// the collection metadata keys a CollectionInfo is kept under
+const (
+	schemaVersionKey  = "schema_version"
+	embeddingModelKey = "embedding_model"
+	dimensionKey      = "dimension"
+)
+
+// CollectionInfo is what a collection records about how its records were written, so that vectors
+// built by a different model or metadata in an older layout are never queried by mistake
+type CollectionInfo struct {
+	SchemaVersion  int
+	EmbeddingModel string
+	Dimension      int
+}
+
+// the info of collections written by this version of the chatbot
+func currentCollectionInfo() CollectionInfo {
+	return CollectionInfo{
+		SchemaVersion:  collectionSchemaVersion,
+		EmbeddingModel: string(embeddingModel),
+		Dimension:      embeddingDimension,
+	}
+}
+
+func (info CollectionInfo) metadata() map[string]interface{} {
+	return map[string]interface{}{
+		schemaVersionKey:  info.SchemaVersion,
+		embeddingModelKey: info.EmbeddingModel,
+		dimensionKey:      info.Dimension,
+	}
+}
+
+func (info CollectionInfo) String() string {
+	return fmt.Sprintf("schema %d, %s with %d dimensions", info.SchemaVersion, info.EmbeddingModel, info.Dimension)
+}
+
+// reads the info from collection metadata. Collections written before versioning have none, and
+// were always schema 1 embedded with ada-002.
+func collectionInfoFromMetadata(metadata map[string]interface{}) CollectionInfo {
+	if _, versioned := metadata[schemaVersionKey]; !versioned {
+		return CollectionInfo{SchemaVersion: 1, EmbeddingModel: string(openai.TextEmbeddingAda002), Dimension: 1536}
+	}
+	var info CollectionInfo
+	if version, ok := toFloat(metadata[schemaVersionKey]); ok {
+		info.SchemaVersion = int(version)
+	}
+	info.EmbeddingModel, _ = metadata[embeddingModelKey].(string)
+	if dimension, ok := toFloat(metadata[dimensionKey]); ok {
+		info.Dimension = int(dimension)
+	}
+	return info
+}
+
+// SchemaMismatchError is a collection that can't be used as it is and can't be migrated
+type SchemaMismatchError struct {
+	Collection string
+	Found      CollectionInfo
+	Expected   CollectionInfo
+	Reason     string
+}
+
+func (e *SchemaMismatchError) Error() string {
+	return fmt.Sprintf("collection '%s' %s (found %s, expected %s); run with -delete to re-ingest the schedule", e.Collection, e.Reason, e.Found, e.Expected)
+}
+
+// refuses a collection whose vectors can't be compared with the current model's queries, or that
+// was written by a newer version; older versions can be migrated
+func checkCollectionInfo(name string, info CollectionInfo) error {
+	expected := currentCollectionInfo()
+	mismatch := &SchemaMismatchError{Collection: name, Found: info, Expected: expected}
+	switch {
+	case info.EmbeddingModel != expected.EmbeddingModel || info.Dimension != expected.Dimension:
+		mismatch.Reason = "was embedded with a different model, so its vectors can't be compared with the queries"
+		return mismatch
+	case info.SchemaVersion > expected.SchemaVersion:
+		mismatch.Reason = "was written by a newer version of the chatbot"
+		return mismatch
+	}
+	return nil
+}
+
+// schemaMigration upgrades the collections from any version before it to its version
+type schemaMigration struct {
+	Version     int
+	Description string
+	Migrate     func(db *Db) error
+}
+
+// every migration in order, each run when the collections are older than its version. Versions
+// without one, such as 2, are brought up by the next: rewriting the course metadata for schema 3
+// also adds the ActualEnrollment of schema 2.
+var schemaMigrations = []schemaMigration{
+	{Version: 3, Description: "add ActualEnrollment and mark unassigned instructors and unscheduled sections in the course metadata", Migrate: migrateCourseMetadata},
+	{Version: 4, Description: "key the instructors by email with their profiles", Migrate: migrateInstructors},
+	{Version: 5, Description: "index subject codes and course codes instead of course titles", Migrate: migrateSubjects},
+}
+
+// checks that every existing collection was embedded with the current model, then migrates the
+// ones in an older schema and records the current version on them. Collections that can't be
+// used are refused with a SchemaMismatchError rather than queried with the wrong vectors.
+func (db *Db) checkCollectionSchemas() error {
+	expected := currentCollectionInfo()
+	lowest := expected.SchemaVersion
+	var existing []string
+	for _, collection := range []struct {
+		name       string
+		collection VectorCollection
+	}{
+		{db.coursesCollectionName, db.coursesCollection},
+		{db.instructorsCollectionName, db.instructorsCollection},
+		{db.subjectsCollectionName, db.subjectsCollection},
+		{db.courseCodesCollectionName, db.courseCodesCollection},
+	} {
+		if collection.collection == nil {
+			continue
+		}
+		info, err := db.store.CollectionInfo(db.ctx, collection.name)
+		if err != nil {
+			return err
+		}
+		if err := checkCollectionInfo(collection.name, info); err != nil {
+			return err
+		}
+		if info.SchemaVersion < lowest {
+			lowest = info.SchemaVersion
+		}
+		existing = append(existing, collection.name)
+	}
+	if lowest == expected.SchemaVersion {
+		return nil
+	}
+
+	for _, migration := range schemaMigrations {
+		if migration.Version <= lowest {
+			continue
+		}
+		log.Printf("Migrating collections to schema %d: %s", migration.Version, migration.Description)
+		if err := migration.Migrate(db); err != nil {
+			return fmt.Errorf("migrating collections to schema %d: %w", migration.Version, err)
+		}
+	}
+	for _, name := range existing {
+		if err := db.store.SetCollectionInfo(db.ctx, name, expected); err != nil {
+			return err
+		}
+	}
+	log.Printf("Collections migrated from schema %d to %d.", lowest, expected.SchemaVersion)
+	return nil
+}
+
+// rewrites the metadata of every course from its document, which holds the whole course, keeping
+// the documents and so any catalog information joined onto them. The records are written back
+// with the embeddings they have, since neither the documents nor the model changed.
+func migrateCourseMetadata(db *Db) error {
+	if db.coursesCollection == nil {
+		return nil
+	}
+	embedded, ok := db.coursesCollection.(EmbeddedCollection)
+	if !ok {
+		return fmt.Errorf("collection '%s' can't be read with its embeddings", db.coursesCollectionName)
+	}
+	records, err := embedded.GetEmbedded(db.ctx)
+	if err != nil {
+		return err
+	}
+
+	for i := range records {
+		course, err := decodeCourse(records[i].Document)
+		if err != nil {
+			return fmt.Errorf("course '%s' has a document that isn't a course: %w", records[i].ID, err)
+		}
+		records[i].Metadata = courseMetadata(course)
+	}
+
+	for start := 0; start < len(records); start += ingestBatchSize {
+		end := min(start+ingestBatchSize, len(records))
+		if err := embedded.UpsertEmbedded(db.ctx, records[start:end]); err != nil {
+			return &BatchError{Collection: db.coursesCollectionName, Start: start, End: end, Err: err}
+		}
+	}
+	return nil
+}
+
+// rebuilds the instructors collection from the course documents, since its records were keyed by
+// name and can't be rewritten in place under their email
+func migrateInstructors(db *Db) error {
+	if db.coursesCollection == nil || db.instructorsCollection == nil {
+		return nil
+	}
+	docs, err := db.storedCollectionDocuments()
+	if err != nil {
+		return err
+	}
+	db.instructorsCollection, err = db.rebuildCollection(db.instructorsCollectionName, docs.instructors)
+	return err
+}
+
+// rebuilds the subjects collection, which held course titles, as an index of subject codes named
+// after the departments given on start-up, and builds the course codes collection alongside it
+func migrateSubjects(db *Db) error {
+	if db.coursesCollection == nil {
+		return nil
+	}
+	docs, err := db.storedCollectionDocuments()
+	if err != nil {
+		return err
+	}
+	if db.subjectsCollection, err = db.rebuildCollection(db.subjectsCollectionName, docs.subjects); err != nil {
+		return err
+	}
+	db.courseCodesCollection, err = db.rebuildCollection(db.courseCodesCollectionName, docs.courseCodes)
+	return err
+}
+
+// builds the records of every collection from the course documents already in the store
+func (db *Db) storedCollectionDocuments() (ingestDocuments, error) {
+	records, err := db.coursesCollection.Get(db.ctx, nil, nil)
+	if err != nil {
+		return ingestDocuments{}, err
+	}
+
+	courses := make([]Course, 0, len(records.Documents))
+	for i, document := range records.Documents {
+		course, err := decodeCourse(document)
+		if err != nil {
+			return ingestDocuments{}, fmt.Errorf("course '%s' has a document that isn't a course: %w", records.IDs[i], err)
+		}
+		courses = append(courses, course)
+	}
+	return buildCollectionDocuments(courses, db.departments)
+}
+
+// replaces the named collection with a new one holding the records
+func (db *Db) rebuildCollection(name string, docs collectionDocuments) (VectorCollection, error) {
+	err := db.store.DeleteCollection(db.ctx, name)
+	if err != nil && !errors.Is(err, ErrCollectionNotFound) {
+		return nil, err
+	}
+	collection, err := db.store.CreateCollection(db.ctx, name, currentCollectionInfo())
+	if err != nil {
+		return nil, err
+	}
+	_, failed := db.writeInBatches(name, docs, false, func(ids, documents []string, metadatas []map[string]interface{}) error {
+		return collection.Add(db.ctx, ids, documents, metadatas)
+	})
+	if len(failed) > 0 {
+		failed[0].Collection = name
+		return nil, &failed[0]
+	}
+	return collection, nil
+}
@@ -0,0 +1,127 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"os"
+	"testing"
+
+	"github.com/amikos-tech/chroma-go/types"
+)
+
+// recordingEmbeddings remembers every document it embeds
+type recordingEmbeddings struct {
+	types.EmbeddingFunction
+	texts []string
+}
+
+func (e *recordingEmbeddings) EmbedDocuments(ctx context.Context, texts []string) ([]*types.Embedding, error) {
+	e.texts = append(e.texts, texts...)
+	return e.EmbeddingFunction.EmbedDocuments(ctx, texts)
+}
+
+func TestCheckCollectionSchemas(t *testing.T) {
+	// the course metadata of schema 1, before ActualEnrollment was added
+	schema1Metadata := func(course Course) map[string]interface{} {
+		metadata := courseMetadata(course)
+		delete(metadata, "ActualEnrollment")
+		return metadata
+	}
+
+	tests := []struct {
+		name string
+		// the info recorded on the collections, or nil for collections written before versioning
+		info *CollectionInfo
+		// whether the collections are refused, and whether their metadata is migrated
+		refused  bool
+		migrated bool
+	}{
+		{"current", &CollectionInfo{collectionSchemaVersion, string(embeddingModel), embeddingDimension}, false, false},
+		{"written before versioning", nil, false, true},
+		{"older schema", &CollectionInfo{1, string(embeddingModel), embeddingDimension}, false, true},
+		{"different model", &CollectionInfo{collectionSchemaVersion, "text-embedding-3-large", 3072}, true, false},
+		{"newer schema", &CollectionInfo{collectionSchemaVersion + 1, string(embeddingModel), embeddingDimension}, true, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			ctx := context.Background()
+			embeddings := &recordingEmbeddings{EmbeddingFunction: types.NewConsistentHashEmbeddingFunction()}
+			store, err := newLocalStore(t.TempDir(), embeddings)
+			if err != nil {
+				t.Fatalf("Error opening store: %v", err)
+			}
+			db := &Db{
+				ctx:                       ctx,
+				store:                     store,
+				coursesCollectionName:     "usf-courses",
+				instructorsCollectionName: "instructors",
+				subjectsCollectionName:    "subjects",
+				courseCodesCollectionName: "course-codes",
+			}
+			if err := db.createCollections(); err != nil {
+				t.Fatalf("Error creating collections: %v", err)
+			}
+
+			course := Course{CRN: "40646", Subject: "CS", CourseNumber: "272", Title: "Software Development", ActualEnrollment: "31", InstructorFirstName: "Philip", InstructorLastName: "Peterson", InstructorEmail: "phpeterson@usfca.edu"}
+			document := `{"CRN": "40646", "SUBJ": "CS", "CRSE NUM": "272", "Title Short Desc": "Software Development", "Actual Enrollment": "31", "Primary Instructor First Name": "Philip", "Primary Instructor Last Name": "Peterson", "Primary Instructor Email": "phpeterson@usfca.edu"}`
+			if err := db.coursesCollection.Add(ctx, []string{course.CRN}, []string{document}, []map[string]interface{}{schema1Metadata(course)}); err != nil {
+				t.Fatalf("Error adding records: %v", err)
+			}
+			// before schema 4 instructors were keyed by their name
+			if err := db.instructorsCollection.Add(ctx, []string{"Philip Peterson"}, []string{"Philip Peterson"}, nil); err != nil {
+				t.Fatalf("Error adding records: %v", err)
+			}
+			for _, name := range []string{db.coursesCollectionName, db.instructorsCollectionName, db.subjectsCollectionName, db.courseCodesCollectionName} {
+				if test.info == nil {
+					os.Remove(store.infoPath(name))
+				} else if err := store.SetCollectionInfo(ctx, name, *test.info); err != nil {
+					t.Fatalf("Error setting collection info: %v", err)
+				}
+			}
+
+			embeddings.texts = nil
+			err = db.checkCollectionSchemas()
+			var mismatch *SchemaMismatchError
+			if refused := errors.As(err, &mismatch); refused != test.refused {
+				t.Fatalf("Expected refused to be %v, got %v", test.refused, err)
+			}
+			if test.refused {
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+
+			records, err := db.coursesCollection.Get(ctx, nil, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if _, migrated := records.Metadatas[0]["ActualEnrollment"]; migrated != test.migrated {
+				t.Errorf("Expected migrated to be %v, got metadata %v", test.migrated, records.Metadatas[0])
+			}
+			if records.Documents[0] != document {
+				t.Errorf("Expected the document to be kept, got %s", records.Documents[0])
+			}
+			for _, text := range embeddings.texts {
+				if text == document {
+					t.Errorf("Expected the course to keep its embedding rather than be embedded again")
+				}
+			}
+			instructors, err := db.instructorsCollection.Get(ctx, nil, nil)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if keyedByEmail := len(instructors.IDs) == 1 && instructors.IDs[0] == "phpeterson@usfca.edu"; keyedByEmail != test.migrated {
+				t.Errorf("Expected the instructors to be keyed by email to be %v, got %v", test.migrated, instructors.IDs)
+			}
+			info, err := store.CollectionInfo(ctx, db.subjectsCollectionName)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if info != currentCollectionInfo() {
+				t.Errorf("Expected the collections to be recorded as %v, got %v", currentCollectionInfo(), info)
+			}
+		})
+	}
+}
@@ -0,0 +1,345 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+
+	chroma "github.com/amikos-tech/chroma-go"
+	chhttp "github.com/amikos-tech/chroma-go/pkg/commons/http"
+	"github.com/amikos-tech/chroma-go/types"
+)
+
+// the backends a VectorStore can be
+const (
+	storeChroma   = "chroma"
+	storeLocal    = "local"
+	storePostgres = "postgres"
+)
+
+var storeBackends = []string{storeChroma, storeLocal, storePostgres}
+
+// where the local store keeps its collections unless told otherwise
+const defaultLocalStorePath = "vectorstore"
+
+// the Chroma server the chroma store talks to
+const chromaURL = "http://localhost:8000"
+
+// VectorStore holds the named collections the chatbot reads and writes. Chroma is the default
+// backend; the local store keeps them in memory and on disk so that no server is needed, and the
+// postgres store keeps them in tables next to other data.
+type VectorStore interface {
+	// the collection with the name, or an error matching ErrCollectionNotFound if it doesn't exist
+	GetCollection(ctx context.Context, name string) (VectorCollection, error)
+	// creates the collection recording info on it, or returns it if it already exists
+	CreateCollection(ctx context.Context, name string, info CollectionInfo) (VectorCollection, error)
+	// deletes the collection, returning an error matching ErrCollectionNotFound if it doesn't exist
+	DeleteCollection(ctx context.Context, name string) error
+	// how the collection's records were written
+	CollectionInfo(ctx context.Context, name string) (CollectionInfo, error)
+	// records how the collection's records were written, e.g. after migrating them
+	SetCollectionInfo(ctx context.Context, name string, info CollectionInfo) error
+}
+
+// opens the store of the backend, embedding documents with embeddings and talking to Chroma through
+// httpClient. path is the local store's directory and dsn the postgres store's connection string;
+// the others ignore them.
+func newVectorStore(backend, path, dsn string, embeddings types.EmbeddingFunction, httpClient *http.Client) (VectorStore, error) {
+	switch backend {
+	case "", storeChroma:
+		client, err := chroma.NewClient(chroma.WithBasePath(chromaURL), chroma.WithHTTPClient(httpClient))
+		if err != nil {
+			return nil, &StoreUnavailableError{Op: "creating client", Err: err}
+		}
+		return &chromaStore{client: client, embeddings: embeddings}, nil
+	case storeLocal:
+		if path == "" {
+			path = defaultLocalStorePath
+		}
+		return newLocalStore(path, embeddings)
+	case storePostgres:
+		return newPgvectorStore(context.Background(), dsn, embeddings)
+	}
+	return nil, fmt.Errorf("unknown vector store '%s', expected one of %v", backend, storeBackends)
+}
+
+// where the store of the backend keeps its collections: the local store's directory, the Chroma
+// server or the postgres connection string
+func storeLocation(backend, path, dsn string) (string, error) {
+	switch backend {
+	case "", storeChroma:
+		return chromaURL, nil
+	case storeLocal:
+		if path == "" {
+			path = defaultLocalStorePath
+		}
+		return filepath.Abs(path)
+	case storePostgres:
+		return dsn, nil
+	}
+	return "", fmt.Errorf("unknown vector store '%s', expected one of %v", backend, storeBackends)
+}
+
+// the directory the files built from a store's collections are kept in, such as the lexical index,
+// so that they always describe that store: the local store's own directory, or a directory in the
+// user's cache named after the Chroma server or postgres database
+func storeStateDir(backend, path, dsn string) (string, error) {
+	location, err := storeLocation(backend, path, dsn)
+	if err != nil {
+		return "", err
+	}
+	if backend == storeLocal {
+		return location, nil
+	}
+	cache, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("no directory to keep the %s store's indexes in: %w", backend, err)
+	}
+	if backend == "" {
+		backend = storeChroma
+	}
+	// hashed, since the connection string may hold a password
+	sum := sha256.Sum256([]byte(location))
+	return filepath.Join(cache, "usf-course-rag", backend+"-"+hex.EncodeToString(sum[:6])), nil
+}
+
+// chromaStore is a VectorStore backed by a Chroma server
+type chromaStore struct {
+	client     *chroma.Client
+	embeddings types.EmbeddingFunction
+}
+
+func (s *chromaStore) GetCollection(ctx context.Context, name string) (VectorCollection, error) {
+	collection, err := s.client.GetCollection(ctx, name, s.embeddings)
+	if err != nil {
+		return nil, storeError(fmt.Sprintf("getting collection '%s'", name), err)
+	}
+	return newChromaCollection(collection), nil
+}
+
+func (s *chromaStore) CreateCollection(ctx context.Context, name string, info CollectionInfo) (VectorCollection, error) {
+	collection, err := s.client.CreateCollection(ctx, name, info.metadata(), true, s.embeddings, types.L2)
+	if err != nil {
+		return nil, storeError(fmt.Sprintf("creating collection '%s'", name), err)
+	}
+	return newChromaCollection(collection), nil
+}
+
+func (s *chromaStore) DeleteCollection(ctx context.Context, name string) error {
+	_, err := s.client.DeleteCollection(ctx, name)
+	return storeError(fmt.Sprintf("deleting collection '%s'", name), err)
+}
+
+// the info is kept in the collection's metadata
+func (s *chromaStore) CollectionInfo(ctx context.Context, name string) (CollectionInfo, error) {
+	collection, err := s.client.GetCollection(ctx, name, s.embeddings)
+	if err != nil {
+		return CollectionInfo{}, storeError(fmt.Sprintf("getting collection '%s'", name), err)
+	}
+	return collectionInfoFromMetadata(collection.Metadata), nil
+}
+
+func (s *chromaStore) SetCollectionInfo(ctx context.Context, name string, info CollectionInfo) error {
+	op := fmt.Sprintf("updating collection '%s'", name)
+	collection, err := s.client.GetCollection(ctx, name, s.embeddings)
+	if err != nil {
+		return storeError(op, err)
+	}
+	// the index settings can't be changed once the collection exists, so they aren't sent back
+	metadata := info.metadata()
+	for key, value := range collection.Metadata {
+		if _, exists := metadata[key]; !exists && !strings.HasPrefix(key, "hnsw:") {
+			metadata[key] = value
+		}
+	}
+	_, err = collection.Update(ctx, collection.Name, &metadata)
+	return storeError(op, err)
+}
+
+// EmbeddedCollection is a VectorCollection whose records can be read and written along with their
+// embeddings, so that they can be moved between stores without embedding them again
+type EmbeddedCollection interface {
+	VectorCollection
+	// every record with its embedding
+	GetEmbedded(ctx context.Context) ([]EmbeddedRecord, error)
+	// adds the records with the embeddings they have, replacing existing ones with the same IDs
+	UpsertEmbedded(ctx context.Context, records []EmbeddedRecord) error
+}
+
+// EmbeddedRecord is a record of a collection along with its embedding
+type EmbeddedRecord struct {
+	ID        string                 `json:"id"`
+	Document  string                 `json:"document"`
+	Metadata  map[string]interface{} `json:"metadata,omitempty"`
+	Embedding []float32              `json:"embedding"`
+}
+
+// VectorCollection is the part of a vector store collection the chatbot reads and writes. Chroma or
+// the local store back it in production and tests swap in an in-memory fake.
+type VectorCollection interface {
+	// adds new records, embedding their documents
+	Add(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error
+	// adds new records and replaces existing ones with the same IDs
+	Upsert(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error
+	// the nResults records most similar to the text that match the where filter, closest first
+	Query(ctx context.Context, text string, nResults int, where map[string]interface{}) (CollectionResults, error)
+	// the records with the given IDs, or every record if there are none, that match the where filter
+	Get(ctx context.Context, ids []string, where map[string]interface{}) (CollectionResults, error)
+}
+
+// CollectionResults are the records returned by a query or get; the slices are parallel
+type CollectionResults struct {
+	IDs       []string
+	Documents []string
+	Metadatas []map[string]interface{}
+	// only set by queries, where a smaller distance is a closer match
+	Distances []float32
+}
+
+// chromaCollection adapts a Chroma collection to VectorCollection
+type chromaCollection struct {
+	collection *chroma.Collection
+}
+
+// wraps a Chroma collection, keeping a missing collection nil
+func newChromaCollection(collection *chroma.Collection) VectorCollection {
+	if collection == nil {
+		return nil
+	}
+	return &chromaCollection{collection: collection}
+}
+
+func (c *chromaCollection) Add(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error {
+	embeddings, err := c.embed(ctx, documents)
+	if err != nil {
+		return err
+	}
+	_, err = c.collection.Add(ctx, embeddings, metadatas, documents, ids)
+	return storeError("adding records", err)
+}
+
+func (c *chromaCollection) Upsert(ctx context.Context, ids, documents []string, metadatas []map[string]interface{}) error {
+	embeddings, err := c.embed(ctx, documents)
+	if err != nil {
+		return err
+	}
+	_, err = c.collection.Upsert(ctx, embeddings, metadatas, documents, ids)
+	return storeError("upserting records", err)
+}
+
+// embeds the documents before they are written, so that a failure to embed them can be told apart
+// from a failure to store them
+func (c *chromaCollection) embed(ctx context.Context, documents []string) ([]*types.Embedding, error) {
+	embeddings, err := c.collection.EmbeddingFunction.EmbedDocuments(ctx, documents)
+	if err != nil {
+		return nil, &EmbeddingError{Err: err}
+	}
+	return embeddings, nil
+}
+
+func (c *chromaCollection) Query(ctx context.Context, text string, nResults int, where map[string]interface{}) (CollectionResults, error) {
+	results, err := c.collection.Query(ctx, []string{text}, int32(nResults), where, nil, nil)
+	if err != nil {
+		return CollectionResults{}, storeError("querying records", err)
+	}
+
+	var found CollectionResults
+	if len(results.Ids) > 0 {
+		found.IDs = results.Ids[0]
+	}
+	if len(results.Documents) > 0 {
+		found.Documents = results.Documents[0]
+	}
+	if len(results.Metadatas) > 0 {
+		found.Metadatas = results.Metadatas[0]
+	}
+	if len(results.Distances) > 0 {
+		found.Distances = results.Distances[0]
+	}
+	return found, nil
+}
+
+func (c *chromaCollection) Get(ctx context.Context, ids []string, where map[string]interface{}) (CollectionResults, error) {
+	results, err := c.collection.Get(ctx, where, nil, ids, nil)
+	if err != nil {
+		return CollectionResults{}, storeError("getting records", err)
+	}
+	return CollectionResults{
+		IDs:       results.Ids,
+		Documents: results.Documents,
+		Metadatas: results.Metadatas,
+	}, nil
+}
+
+func (c *chromaCollection) GetEmbedded(ctx context.Context) ([]EmbeddedRecord, error) {
+	results, err := c.collection.Get(ctx, nil, nil, nil, []types.QueryEnum{types.IDocuments, types.IMetadatas, types.IEmbeddings})
+	if err != nil {
+		return nil, storeError("getting records", err)
+	}
+	records := make([]EmbeddedRecord, len(results.Ids))
+	for i, id := range results.Ids {
+		records[i] = EmbeddedRecord{ID: id}
+		if i < len(results.Documents) {
+			records[i].Document = results.Documents[i]
+		}
+		if i < len(results.Metadatas) {
+			records[i].Metadata = results.Metadatas[i]
+		}
+		if i < len(results.Embeddings) {
+			records[i].Embedding = embeddingValues(results.Embeddings[i])
+		}
+	}
+	return records, nil
+}
+
+func (c *chromaCollection) UpsertEmbedded(ctx context.Context, records []EmbeddedRecord) error {
+	var ids, documents []string
+	var metadatas []map[string]interface{}
+	var embeddings []*types.Embedding
+	for _, record := range records {
+		ids = append(ids, record.ID)
+		documents = append(documents, record.Document)
+		metadatas = append(metadatas, record.Metadata)
+		embeddings = append(embeddings, types.NewEmbeddingFromFloat32(record.Embedding))
+	}
+	_, err := c.collection.Upsert(ctx, embeddings, metadatas, documents, ids)
+	return storeError("upserting records", err)
+}
+
+// the error types Chroma names in its error bodies, e.g. {"error": "NotFoundError", "message": "..."}
+var (
+	chromaNotFoundErrors  = map[string]bool{"NotFoundError": true, "InvalidCollection": true, "InvalidCollectionException": true}
+	chromaDuplicateErrors = map[string]bool{"DuplicateIDError": true, "IDAlreadyExistsError": true, "UniqueConstraintError": true}
+)
+
+// classifies the error of a Chroma request by its HTTP status and the error type in its body:
+// requests Chroma couldn't answer become StoreUnavailableError, missing collections and duplicate
+// IDs become a StoreError of their kind, and anything else is returned as it is
+func storeError(op string, err error) error {
+	if err == nil {
+		return nil
+	}
+	var chromaErr *chhttp.ChromaError
+	if !errors.As(err, &chromaErr) {
+		return err
+	}
+
+	// older servers write the error type as a Python exception, e.g. "NotFoundError('...')"
+	errorType, _, _ := strings.Cut(chromaErr.ErrorID, "(")
+	switch {
+	case chromaErr.ErrorCode == http.StatusNotFound || chromaNotFoundErrors[errorType]:
+		return &StoreError{Kind: ErrCollectionNotFound, Op: op, Err: err}
+	case chromaErr.ErrorCode == http.StatusConflict || chromaDuplicateErrors[errorType]:
+		return &StoreError{Kind: ErrDuplicateID, Op: op, Err: err}
+	case chromaErr.ErrorCode == 0 || chromaErr.ErrorCode >= 500:
+		// no response at all, or the server failed
+		return &StoreUnavailableError{Op: op, Err: err}
+	}
+	return err
+}
@@ -0,0 +1,390 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// a subject written out followed by a course number, such as "Comp Sci 272"
+var namedCourseCodePattern = regexp.MustCompile(`(?i)^(.*[A-Z].*?)\s*-?\s*(\d{2,4}[A-Z]?)$`)
+
+// a subject code or a subject written out, such as "CS" or "Comp Sci"
+var subjectNamePattern = regexp.MustCompile(`^[A-Za-z][A-Za-z&.,' -]*$`)
+
+// Department is the name of a subject code from the departments file, with the other names
+// students use for it
+type Department struct {
+	Code    string   `json:"code"`
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+}
+
+// SubjectEntry is a subject code of the schedule with its department and college
+type SubjectEntry struct {
+	Code string `json:"code"`
+	// from the departments file; empty when it has none for the code
+	Department string   `json:"department,omitempty"`
+	Aliases    []string `json:"aliases,omitempty"`
+	// the college of most of the subject's sections, and every college it is taught in
+	College  string   `json:"college"`
+	Colleges []string `json:"colleges"`
+}
+
+// CourseCodeEntry is a course of the schedule keyed by its subject and number, with every title
+// its sections are listed under
+type CourseCodeEntry struct {
+	Code         string `json:"code"`
+	Subject      string `json:"subject"`
+	CourseNumber string `json:"courseNumber"`
+	// the title of most of its sections, and every title seen
+	Title  string   `json:"title"`
+	Titles []string `json:"titles"`
+}
+
+// reads a departments file: a JSON list of {"code", "name", "aliases"}, keyed by the subject code
+func loadDepartments(filePath string) (map[string]Department, error) {
+	data, err := os.ReadFile(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open departments file: %w", err)
+	}
+
+	var entries []Department
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("failed to unmarshal departments file: %w", err)
+	}
+
+	departments := make(map[string]Department, len(entries))
+	for _, entry := range entries {
+		entry.Code = strings.ToUpper(strings.TrimSpace(entry.Code))
+		if !subjectCodePattern.MatchString(entry.Code) {
+			return nil, fmt.Errorf("invalid subject code '%s' in departments file", entry.Code)
+		}
+		entry.Name = strings.TrimSpace(entry.Name)
+		departments[entry.Code] = entry
+	}
+	return departments, nil
+}
+
+// the value seen most often, or the first one seen when there is a tie
+func mostCommon(values []string, counts map[string]int) string {
+	var common string
+	for _, value := range values {
+		if counts[value] > counts[common] {
+			common = value
+		}
+	}
+	return common
+}
+
+// the subject codes of the schedule with their colleges and, when the departments file has them,
+// their department names, ordered by code
+func buildSubjectIndex(courses []Course, departments map[string]Department) []SubjectEntry {
+	colleges := make(map[string][]string)
+	counts := make(map[string]map[string]int)
+	for _, course := range courses {
+		code := strings.ToUpper(strings.TrimSpace(course.Subject))
+		if code == "" {
+			continue
+		}
+		if counts[code] == nil {
+			counts[code] = make(map[string]int)
+			colleges[code] = nil
+		}
+		if college := strings.TrimSpace(course.College); college != "" {
+			if counts[code][college] == 0 {
+				colleges[code] = append(colleges[code], college)
+			}
+			counts[code][college]++
+		}
+	}
+
+	codes := make([]string, 0, len(colleges))
+	for code := range colleges {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	entries := make([]SubjectEntry, 0, len(codes))
+	for _, code := range codes {
+		entry := SubjectEntry{Code: code, College: mostCommon(colleges[code], counts[code])}
+		entry.Colleges = append([]string{}, colleges[code]...)
+		sort.Strings(entry.Colleges)
+		if department, exists := departments[code]; exists {
+			entry.Department = department.Name
+			entry.Aliases = department.Aliases
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// the courses of the schedule keyed by subject and number with every title seen, ordered by code
+func buildCourseIndex(courses []Course) []CourseCodeEntry {
+	entries := make(map[string]*CourseCodeEntry)
+	counts := make(map[string]map[string]int)
+	for _, course := range courses {
+		code, ok := normalizeCourseCode(course.Subject + " " + course.CourseNumber)
+		if !ok {
+			continue
+		}
+		entry, exists := entries[code]
+		if !exists {
+			fields := strings.Fields(code)
+			entry = &CourseCodeEntry{Code: code, Subject: fields[0], CourseNumber: fields[1]}
+			entries[code] = entry
+			counts[code] = make(map[string]int)
+		}
+		if title := strings.TrimSpace(course.Title); title != "" {
+			if counts[code][title] == 0 {
+				entry.Titles = append(entry.Titles, title)
+			}
+			counts[code][title]++
+		}
+	}
+
+	codes := make([]string, 0, len(entries))
+	for code := range entries {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+
+	index := make([]CourseCodeEntry, 0, len(codes))
+	for _, code := range codes {
+		entry := entries[code]
+		entry.Title = mostCommon(entry.Titles, counts[code])
+		index = append(index, *entry)
+	}
+	return index
+}
+
+// the text a subject is embedded as: its department name and aliases when it has them, so that
+// "Computer Science" finds CS
+func (entry SubjectEntry) document() string {
+	if entry.Department == "" {
+		return entry.Code
+	}
+	return strings.Join(append([]string{entry.Department}, entry.Aliases...), "; ")
+}
+
+// the metadata stored alongside each subject. Lists are joined into strings since metadata values
+// can't be lists.
+func subjectMetadata(entry SubjectEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"Code":       entry.Code,
+		"Department": entry.Department,
+		"Aliases":    strings.Join(entry.Aliases, "; "),
+		"College":    entry.College,
+		"Colleges":   strings.Join(entry.Colleges, ", "),
+	}
+}
+
+// the metadata stored alongside each course code
+func courseCodeMetadata(entry CourseCodeEntry) map[string]interface{} {
+	return map[string]interface{}{
+		"Code":         entry.Code,
+		"Subject":      entry.Subject,
+		"CourseNumber": entry.CourseNumber,
+		"Titles":       strings.Join(entry.Titles, "; "),
+	}
+}
+
+// the values of a list joined into a metadata string
+func metadataList(metadata map[string]interface{}, key, separator string) []string {
+	text, _ := metadata[key].(string)
+	var values []string
+	for _, value := range strings.Split(text, separator) {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+	return values
+}
+
+// reads a subject back from a subjects record. Records written before the subjects collection
+// held subject codes are course titles and have no code.
+func subjectFromRecord(metadata map[string]interface{}) (SubjectEntry, bool) {
+	code, _ := metadata["Code"].(string)
+	if code == "" {
+		return SubjectEntry{}, false
+	}
+	entry := SubjectEntry{Code: code}
+	entry.Department, _ = metadata["Department"].(string)
+	entry.College, _ = metadata["College"].(string)
+	entry.Aliases = metadataList(metadata, "Aliases", ";")
+	entry.Colleges = metadataList(metadata, "Colleges", ",")
+	return entry, true
+}
+
+// reads a course code back from a course-codes record
+func courseCodeFromRecord(id, document string, metadata map[string]interface{}) CourseCodeEntry {
+	entry := CourseCodeEntry{Code: id, Title: document}
+	entry.Subject, _ = metadata["Subject"].(string)
+	entry.CourseNumber, _ = metadata["CourseNumber"].(string)
+	entry.Titles = metadataList(metadata, "Titles", ";")
+	if len(entry.Titles) == 0 {
+		entry.Titles = []string{document}
+	}
+	return entry
+}
+
+// lowercases the text and folds its accents and punctuation away, so "Comp. Sci" is "comp sci"
+func normalizeName(text string) string {
+	text = strings.ToLower(foldAccents(text))
+	text = strings.Map(func(r rune) rune {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return ' '
+	}, text)
+	return strings.Join(strings.Fields(text), " ")
+}
+
+// whether every word of the text starts the matching word of the name, as "comp sci" does
+// "computer science" and "software dev" does "software development"
+func abbreviates(text, name string) bool {
+	words, nameWords := strings.Fields(text), strings.Fields(name)
+	if len(words) == 0 || len(words) != len(nameWords) {
+		return false
+	}
+	for i, word := range words {
+		if !strings.HasPrefix(nameWords[i], word) {
+			return false
+		}
+	}
+	return true
+}
+
+// the names a text is matched against exactly or as an abbreviation
+func matchNames(text string, names []string) (exact, abbreviated bool) {
+	normalized := normalizeName(text)
+	for _, name := range names {
+		name = normalizeName(name)
+		if normalized == name || strings.ReplaceAll(normalized, " ", "") == strings.ReplaceAll(name, " ", "") {
+			return true, true
+		}
+		if abbreviates(normalized, name) {
+			abbreviated = true
+		}
+	}
+	return false, abbreviated
+}
+
+// the subject whose code or department name the text is, or abbreviates as "Comp Sci" does
+// "Computer Science". An abbreviation of several subjects matches none of them.
+func matchSubject(db *Db, text string) (SubjectEntry, bool, error) {
+	if db.subjectsCollection == nil || strings.TrimSpace(text) == "" {
+		return SubjectEntry{}, false, nil
+	}
+	records, err := db.subjectsCollection.Get(db.ctx, nil, nil)
+	if err != nil {
+		return SubjectEntry{}, false, fmt.Errorf("error getting subjects: %w", err)
+	}
+
+	var abbreviated []SubjectEntry
+	for i := range records.IDs {
+		entry, ok := subjectFromRecord(metadataAt(records, i))
+		if !ok {
+			continue
+		}
+		if strings.EqualFold(entry.Code, strings.TrimSpace(text)) {
+			return entry, true, nil
+		}
+		names := entry.Aliases
+		if entry.Department != "" {
+			names = append([]string{entry.Department}, names...)
+		}
+		exact, abbreviation := matchNames(text, names)
+		if exact {
+			return entry, true, nil
+		}
+		if abbreviation {
+			abbreviated = append(abbreviated, entry)
+		}
+	}
+	if len(abbreviated) == 1 {
+		return abbreviated[0], true, nil
+	}
+	return SubjectEntry{}, false, nil
+}
+
+// resolves a subject code or a department name, written out in full, abbreviated or with typos,
+// to its subject, taking the closest department by embedding when nothing matches exactly
+func resolveSubject(db *Db, text string) (SubjectEntry, bool, error) {
+	if entry, ok, err := matchSubject(db, text); ok || err != nil || db.subjectsCollection == nil {
+		return entry, ok, err
+	}
+
+	results, err := db.subjectsCollection.Query(db.ctx, text, 1, nil)
+	if err != nil {
+		return SubjectEntry{}, false, fmt.Errorf("error querying subjects collection: %w", err)
+	}
+	for i := range results.IDs {
+		// subjects without a department are embedded as their code, which says nothing of the name
+		if entry, ok := subjectFromRecord(metadataAt(results, i)); ok && entry.Department != "" {
+			return entry, true, nil
+		}
+	}
+	return SubjectEntry{}, false, nil
+}
+
+// resolves a course code written any of the ways students write one, such as "CS272", "cs 272"
+// or "Comp Sci 272", to "CS 272". The subject has to match exactly, since a number after a
+// phrase isn't always a course.
+func resolveCourseCode(db *Db, text string) (string, bool, error) {
+	match := namedCourseCodePattern.FindStringSubmatch(strings.TrimSpace(text))
+	if match == nil {
+		return "", false, nil
+	}
+	subject, ok, err := matchSubject(db, match[1])
+	if err != nil {
+		return "", false, err
+	}
+	if ok {
+		return subject.Code + " " + strings.ToUpper(match[2]), true, nil
+	}
+	// a code whose subject isn't in the index, as in collections ingested before it was built
+	if code, ok := normalizeCourseCode(text); ok {
+		return code, true, nil
+	}
+	return "", false, nil
+}
+
+// the course whose title the text is, or abbreviates as "Software Dev" does "Software
+// Development", falling back to the closest title by embedding
+func resolveCourseTitle(db *Db, text string) (CourseCodeEntry, bool, error) {
+	if db.courseCodesCollection == nil || strings.TrimSpace(text) == "" {
+		return CourseCodeEntry{}, false, nil
+	}
+	records, err := db.courseCodesCollection.Get(db.ctx, nil, nil)
+	if err != nil {
+		return CourseCodeEntry{}, false, fmt.Errorf("error getting course codes: %w", err)
+	}
+
+	var abbreviated []CourseCodeEntry
+	for i, id := range records.IDs {
+		entry := courseCodeFromRecord(id, records.Documents[i], metadataAt(records, i))
+		exact, abbreviation := matchNames(text, entry.Titles)
+		if exact {
+			return entry, true, nil
+		}
+		if abbreviation {
+			abbreviated = append(abbreviated, entry)
+		}
+	}
+	if len(abbreviated) == 1 {
+		return abbreviated[0], true, nil
+	}
+
+	results, err := db.courseCodesCollection.Query(db.ctx, text, 1, nil)
+	if err != nil {
+		return CourseCodeEntry{}, false, fmt.Errorf("error querying course codes collection: %w", err)
+	}
+	if len(results.IDs) == 0 {
+		return CourseCodeEntry{}, false, nil
+	}
+	return courseCodeFromRecord(results.IDs[0], results.Documents[0], metadataAt(results, 0)), true, nil
+}
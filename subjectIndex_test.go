@@ -0,0 +1,92 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestBuildSubjectAndCourseIndexes(t *testing.T) {
+	departments, err := loadDepartments("testdata/departments.json")
+	if err != nil {
+		t.Fatalf("Error loading departments: %v", err)
+	}
+	courses := append([]Course{
+		{Subject: "CS", CourseNumber: "272", Section: "04", CRN: "40647", Title: "Software Dev", College: "SC"},
+		{Subject: "CS", CourseNumber: "272", Section: "05", CRN: "40648", Title: "Software Development", College: "AS"},
+		{Subject: "ART", CourseNumber: "101", Section: "01", CRN: "40001", Title: "Drawing", College: "LA"},
+	}, fakeCourses...)
+
+	subjects := buildSubjectIndex(courses, departments)
+	expectedSubjects := []SubjectEntry{
+		{Code: "ART", College: "LA", Colleges: []string{"LA"}},
+		{Code: "BIOL", Department: "Biology", College: "SC", Colleges: []string{"SC"}},
+		{Code: "CS", Department: "Computer Science", Aliases: []string{"CompSci"}, College: "SC", Colleges: []string{"AS", "SC"}},
+		{Code: "PHIL", Department: "Philosophy", College: "LA", Colleges: []string{"LA"}},
+	}
+	if !reflect.DeepEqual(subjects, expectedSubjects) {
+		t.Errorf("Expected subjects %+v, got %+v", expectedSubjects, subjects)
+	}
+
+	var cs272 CourseCodeEntry
+	for _, entry := range buildCourseIndex(courses) {
+		if entry.Code == "CS 272" {
+			cs272 = entry
+		}
+	}
+	expected := CourseCodeEntry{Code: "CS 272", Subject: "CS", CourseNumber: "272", Title: "Software Development", Titles: []string{"Software Dev", "Software Development"}}
+	if !reflect.DeepEqual(cs272, expected) {
+		t.Errorf("Expected %+v, got %+v", expected, cs272)
+	}
+}
+
+func TestResolveCourseCode(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+
+	tests := []struct {
+		text     string
+		expected string
+		ok       bool
+	}{
+		{"CS272", "CS 272", true},
+		{"cs 272", "CS 272", true},
+		{"cs-272l", "CS 272L", true},
+		{"Comp Sci 272", "CS 272", true},
+		{"computer science 315", "CS 315", true},
+		{"CompSci 272", "CS 272", true},
+		{"Philosophy 240", "PHIL 240", true},
+		// a subject that isn't indexed, which is still a course code
+		{"MATH 201", "MATH 201", true},
+		{"Software Development", "", false},
+		{"Web Development 2024", "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.text, func(t *testing.T) {
+			code, ok, err := resolveCourseCode(db, test.text)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if code != test.expected || ok != test.ok {
+				t.Errorf("Expected %q, %v, got %q, %v", test.expected, test.ok, code, ok)
+			}
+		})
+	}
+}
+
+func TestResolveSubjectAndTitle(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+
+	for text, expected := range map[string]string{"cs": "CS", "Computer Science": "CS", "Comp Sci": "CS", "bio": "BIOL", "philosophy": "PHIL"} {
+		subject, ok, err := resolveSubject(db, text)
+		if err != nil || !ok || subject.Code != expected {
+			t.Errorf("Expected %q to resolve to %s, got %+v, %v, %v", text, expected, subject, ok, err)
+		}
+	}
+
+	for text, expected := range map[string]string{"Software Dev": "CS 272", "software development lab": "CS 272L", "Comp Architecture": "CS 315"} {
+		course, ok, err := resolveCourseTitle(db, text)
+		if err != nil || !ok || course.Code != expected {
+			t.Errorf("Expected %q to resolve to %s, got %+v, %v, %v", text, expected, course, ok, err)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenLimiter keeps the tokens sent to the embedding provider under a budget per minute. The
+// budget refills continuously, so up to a minute's worth goes straight through and the rest waits.
+type tokenLimiter struct {
+	perMinute int
+
+	mu        sync.Mutex
+	available float64
+	updated   time.Time
+	// replaced in tests so that they don't have to wait
+	now   func() time.Time
+	sleep func(ctx context.Context, d time.Duration) error
+}
+
+// a limiter of perMinute tokens, or nil for no limit
+func newTokenLimiter(perMinute int) *tokenLimiter {
+	if perMinute <= 0 {
+		return nil
+	}
+	return &tokenLimiter{perMinute: perMinute}
+}
+
+// blocks until tokens can be sent without going over the budget, or ctx is done. Requests larger
+// than the whole budget wait for all of it rather than forever.
+func (l *tokenLimiter) wait(ctx context.Context, tokens int) error {
+	if l == nil {
+		return nil
+	}
+	if tokens > l.perMinute {
+		tokens = l.perMinute
+	}
+	for {
+		l.mu.Lock()
+		now := l.clock()
+		if l.updated.IsZero() {
+			l.available = float64(l.perMinute)
+		} else {
+			l.available += now.Sub(l.updated).Minutes() * float64(l.perMinute)
+			if l.available > float64(l.perMinute) {
+				l.available = float64(l.perMinute)
+			}
+		}
+		l.updated = now
+		if l.available >= float64(tokens) {
+			l.available -= float64(tokens)
+			l.mu.Unlock()
+			return nil
+		}
+		wait := time.Duration((float64(tokens) - l.available) / float64(l.perMinute) * float64(time.Minute))
+		l.mu.Unlock()
+
+		if err := l.pause(ctx, wait); err != nil {
+			return err
+		}
+	}
+}
+
+func (l *tokenLimiter) pause(ctx context.Context, d time.Duration) error {
+	if l.sleep != nil {
+		return l.sleep(ctx, d)
+	}
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (l *tokenLimiter) clock() time.Time {
+	if l.now != nil {
+		return l.now()
+	}
+	return time.Now()
+}
+
+// a rough count of the tokens in texts, at about 4 characters a token for English
+func estimateTokens(texts []string) int {
+	tokens := 0
+	for _, text := range texts {
+		tokens += (len(text) + 3) / 4
+	}
+	return tokens
+}
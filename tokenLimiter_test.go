@@ -0,0 +1,49 @@
+package main
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTokenLimiter(t *testing.T) {
+	tests := []struct {
+		name string
+		// the tokens of each request, sent one after another
+		requests []int
+		// the waits the limiter asked for
+		waits []time.Duration
+	}{
+		{"under the budget", []int{400, 600}, nil},
+		{"over the budget waits for it to refill", []int{600, 600}, []time.Duration{12 * time.Second}},
+		{"larger than the budget waits for all of it", []int{1000, 5000}, []time.Duration{time.Minute}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			now := time.Date(2024, 8, 20, 14, 40, 0, 0, time.UTC)
+			var waits []time.Duration
+			limiter := newTokenLimiter(1000)
+			limiter.now = func() time.Time { return now }
+			limiter.sleep = func(ctx context.Context, d time.Duration) error {
+				waits = append(waits, d)
+				now = now.Add(d)
+				return nil
+			}
+
+			for _, tokens := range test.requests {
+				if err := limiter.wait(context.Background(), tokens); err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+			}
+			if !reflect.DeepEqual(waits, test.waits) {
+				t.Errorf("Expected waits %v, got %v", test.waits, waits)
+			}
+		})
+	}
+
+	if err := newTokenLimiter(0).wait(context.Background(), 1e9); err != nil {
+		t.Errorf("Expected no limit to never wait, got %v", err)
+	}
+}
@@ -16,7 +16,7 @@ func MakeTool() openai.Tool {
 			},
 			"Subject": {
 				Type:        jsonschema.String,
-				Description: "Subject code, e.g. CS",
+				Description: "Subject code, e.g. CS, or the subject written out, e.g. Computer Science or Comp Sci",
 			},
 			"CourseNumber": {
 				Type:        jsonschema.String,
@@ -28,7 +28,7 @@ func MakeTool() openai.Tool {
 			},
 			"TitleShortDesc": {
 				Type:        jsonschema.String,
-				Description: "The subject of the course. e.g. Bioinformatics",
+				Description: "The subject of the course. e.g. Bioinformatics, or a whole course code when the user gives one, e.g. CS272 or Comp Sci 272",
 			},
 			"PrimaryInstructorEmail": {
 				Type:        jsonschema.String,
@@ -40,15 +40,15 @@ func MakeTool() openai.Tool {
 			},
 			"MeetDays": {
 				Type:        jsonschema.String,
-				Description: "Days of the week when the course meets",
+				Description: "Days of the week when the course meets, as letters from M, T, W, R (Thursday), F, S and U (Sunday), e.g. MWF or TR",
 			},
 			"BeginTime": {
 				Type:        jsonschema.String,
-				Description: "Start time of the course",
+				Description: "Start time of the course, e.g. 14:40 or 2:40 PM",
 			},
 			"EndTime": {
 				Type:        jsonschema.String,
-				Description: "End time of the course",
+				Description: "End time of the course, e.g. 16:25 or 4:25 PM",
 			},
 			"Building": {
 				Type:        jsonschema.String,
@@ -70,6 +70,23 @@ func MakeTool() openai.Tool {
 				Type:        jsonschema.String,
 				Description: "Full name of the instructor",
 			},
+			"Query": {
+				Type:        jsonschema.String,
+				Description: "A short free-text search phrase describing the topic the user is looking for in their own words, e.g. machine learning ethics",
+			},
+			"SortBy": {
+				Type:        jsonschema.String,
+				Description: "How to order the courses: by relevance (default), by begin time, by course number, or by enrollment (largest first)",
+				Enum:        sortOrders,
+			},
+			"PageSize": {
+				Type:        jsonschema.Integer,
+				Description: "How many courses to return at most, between 1 and 50 (default 20)",
+			},
+			"Cursor": {
+				Type:        jsonschema.String,
+				Description: "The nextCursor of a previous result, to fetch the next page when the user wants to see more. When set, leave every other field out",
+			},
 		},
 	}
 	f := openai.FunctionDefinition{
@@ -93,6 +110,7 @@ func EmailTool() openai.Tool {
 				Description: "The email address of the instructor",
 			},
 		},
+		Required: []string{"email"},
 	}
 	f := openai.FunctionDefinition{
 		Name:        "email_instructor",
@@ -106,6 +124,66 @@ func EmailTool() openai.Tool {
 	return t
 }
 
+func EligibilityTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"completed_courses": {
+				Type:        jsonschema.Array,
+				Description: "Courses the student has already completed, as subject and number, e.g. [\"CS 110\", \"CS 112\"]",
+				Items:       &jsonschema.Definition{Type: jsonschema.String},
+			},
+			"courses": {
+				Type:        jsonschema.Array,
+				Description: "Courses the student wants to take, e.g. [\"CS 315\"], or a subject code such as [\"CS\"] to check every course in that subject",
+				Items:       &jsonschema.Definition{Type: jsonschema.String},
+			},
+		},
+		Required: []string{"completed_courses", "courses"},
+	}
+	f := openai.FunctionDefinition{
+		Name:        "check_eligibility",
+		Description: "Checks the prerequisites of courses against the courses a student has completed and returns the sections they are eligible for, the missing prerequisites and the path through the prerequisite graph as evidence",
+		Parameters:  params,
+	}
+	t := openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+	return t
+}
+
+func InstructorTool() openai.Tool {
+	params := jsonschema.Definition{
+		Type: jsonschema.Object,
+		Properties: map[string]jsonschema.Definition{
+			"name": {
+				Type:        jsonschema.String,
+				Description: "The instructor's name as the user wrote it, e.g. Phil Peterson",
+			},
+			"email": {
+				Type:        jsonschema.String,
+				Description: "The instructor's email address, when the user gave it or a previous result included it",
+			},
+		},
+	}
+	f := openai.FunctionDefinition{
+		Name:        "get_instructor",
+		Description: "Gets an instructor's profile: their email, the spellings of their name, colleges, subjects, number of sections and total enrollment, their full teaching schedule this term, and their office hours when known. Give the name or the email",
+		Parameters:  params,
+	}
+	t := openai.Tool{
+		Type:     openai.ToolTypeFunction,
+		Function: &f,
+	}
+	return t
+}
+
+// every tool the chatbot can call
+func AllTools() []openai.Tool {
+	return []openai.Tool{MakeTool(), EmailTool(), EligibilityTool(), InstructorTool()}
+}
+
 func InitializeDialogue () []openai.ChatCompletionMessage{
 	dialogue := []openai.ChatCompletionMessage{
 		{
@@ -125,13 +203,18 @@ func InitializeDialogue () []openai.ChatCompletionMessage{
 				"Room": "101",
 				"InstructorFirstName": "Optimus",
 				"InstructorLastName": "Prime",
-				"InstructorFullName": "Optimus Prime"
+				"InstructorFullName": "Optimus Prime",
+				"Query": "ethics of machine learning"
 			  }
-			  the 'TitleShortDesc' field should be treated as the subject of the sentence. For instance, the 'TitleShortDesc' for the sentence "I want to learn skateboarding" should be "skateboarding". Please only extract fields if you are confident that the field exists in the user's text.
+			  the 'TitleShortDesc' field should be treated as the subject of the sentence. For instance, the 'TitleShortDesc' for the sentence "I want to learn skateboarding" should be "skateboarding". Please only extract fields if you are confident that the field exists in the user's text. The 'Query' field is different: whenever the user describes a topic rather than an exact course title, put a short search phrase for that topic in 'Query' so that courses can be found by similarity.
 			  You can use the provided function tools to fetch the required information: {
                 "get_relevant_courses": and parameters are the extracted fields from the user's text.
 				"email_instructor": takes in the "email" field from the user's text.
+				"get_instructor": takes an instructor's "name" or "email". Use it whenever the user asks about an instructor rather than a course, e.g. what they teach, how to reach them or their office hours.
+				"check_eligibility": takes the courses the user has completed and the courses they want to take. Use it whenever the user asks whether they can take a course. When you answer, always show the prerequisite path from the "evidence" field (e.g. "CS 315 → CS 245: completed") so the user can see why they are or are not eligible.
               }
+			  Whenever your answer mentions a specific course section, cite the CRN of the record you got it from right after it, in the form [CRN 40646]. Only cite CRNs that appear in your tool results, and only state times, rooms and instructors exactly as they appear in the cited record.
+			  When a record's "Instructor Status" is "Staff/TBA", say that the instructor is not yet assigned rather than leaving the name blank. When its "Meeting Status" is "Online/Asynchronous", say that the section is online with no scheduled meetings, when it is "Online/Synchronous", say that the section meets online at its listed times, and when it is "TBA", say that its times or place are still to be announced.
 			  If you feel like the user's question requires multiple different tool calls or repeated tool calls of the same tool, you can just send one tool call, wait for the too call response, and continuing making subsequent calls until you have all the required information.`,
 		},
 	}
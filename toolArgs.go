@@ -0,0 +1,313 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/mail"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var (
+	crnPattern          = regexp.MustCompile(`^\d{5}$`)
+	subjectCodePattern  = regexp.MustCompile(`^[A-Za-z]{2,5}$`)
+	courseNumberFormat  = regexp.MustCompile(`^\d{3}[A-Za-z]?$`)
+	sectionFormat       = regexp.MustCompile(`^\d{1,2}[A-Za-z]?$`)
+	meetDaysFormat      = regexp.MustCompile(`^[MTWRFSU]{1,7}$`)
+	twentyFourHourClock = regexp.MustCompile(`^(\d{1,2}):?(\d{2})$`)
+)
+
+// toolArgumentError is a tool call whose arguments don't decode or validate. It is sent back to
+// the model as the tool's response so that it can fix the arguments and call the tool again.
+type toolArgumentError struct {
+	Tool     string
+	Problems []string
+}
+
+func (e *toolArgumentError) Error() string {
+	return fmt.Sprintf("invalid arguments for %s: %s", e.Tool, strings.Join(e.Problems, "; "))
+}
+
+// the tool response for a failed tool call: argument errors ask the model to retry, anything else
+// is reported as a failure
+func toolErrorContent(failure string, err error) string {
+	var argumentErr *toolArgumentError
+	if errors.As(err, &argumentErr) {
+		return fmt.Sprintf("The arguments of %s are invalid: %s. Fix these arguments and call %s again.",
+			argumentErr.Tool, strings.Join(argumentErr.Problems, "; "), argumentErr.Tool)
+	}
+	return failure + ": " + err.Error()
+}
+
+// the response to a tool call that failed, or the error itself when the question can't go on: a
+// busy or unreachable service won't be fixed by the model calling the tool again, and a cancelled
+// question has to stop rather than be answered from an empty result
+func toolFailure(failure string, err error) (string, error) {
+	if isBusyError(err) || errors.Is(err, context.Canceled) {
+		return "", err
+	}
+	return toolErrorContent(failure, err), nil
+}
+
+// decodes the arguments of a tool call into v, rejecting unknown arguments and values of the wrong type
+func decodeToolArguments(tool, arguments string, v interface{}) error {
+	if strings.TrimSpace(arguments) == "" {
+		arguments = "{}"
+	}
+	decoder := json.NewDecoder(strings.NewReader(arguments))
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(v); err != nil {
+		return &toolArgumentError{Tool: tool, Problems: []string{describeDecodeError(err)}}
+	}
+	return nil
+}
+
+// turns a JSON decoding error into something the model can act on
+func describeDecodeError(err error) string {
+	var typeErr *json.UnmarshalTypeError
+	var syntaxErr *json.SyntaxError
+	switch {
+	case errors.As(err, &typeErr):
+		return fmt.Sprintf("%s must be a %s, not a %s", typeErr.Field, jsonTypeName(typeErr.Type.Kind().String()), typeErr.Value)
+	case errors.As(err, &syntaxErr):
+		return "the arguments are not valid JSON: " + err.Error()
+	case errors.Is(err, io.ErrUnexpectedEOF):
+		return "the arguments are not valid JSON: they end too early"
+	case strings.HasPrefix(err.Error(), "json: unknown field "):
+		return "unknown argument " + strings.TrimPrefix(err.Error(), "json: unknown field ")
+	}
+	return err.Error()
+}
+
+func jsonTypeName(kind string) string {
+	switch kind {
+	case "int", "int64", "float64":
+		return "number"
+	case "slice":
+		return "list"
+	}
+	return kind
+}
+
+// courseSearchArgs are the arguments of get_relevant_courses
+type courseSearchArgs struct {
+	CRN                    string `json:"CRN"`
+	Subject                string `json:"Subject"`
+	CourseNumber           string `json:"CourseNumber"`
+	Section                string `json:"Section"`
+	TitleShortDesc         string `json:"TitleShortDesc"`
+	PrimaryInstructorEmail string `json:"PrimaryInstructorEmail"`
+	College                string `json:"College"`
+	MeetDays               string `json:"MeetDays"`
+	BeginTime              string `json:"BeginTime"`
+	EndTime                string `json:"EndTime"`
+	Building               string `json:"Building"`
+	Room                   string `json:"Room"`
+	InstructorFirstName    string `json:"InstructorFirstName"`
+	InstructorLastName     string `json:"InstructorLastName"`
+	InstructorFullName     string `json:"InstructorFullName"`
+	Query                  string `json:"Query"`
+	SortBy                 string `json:"SortBy"`
+	PageSize               int    `json:"PageSize"`
+	Cursor                 string `json:"Cursor"`
+}
+
+// decodes and validates the arguments of get_relevant_courses, normalizing values into the form
+// they are stored in, e.g. "2:40 PM" becomes "1440"
+func parseCourseSearchArgs(arguments string) (courseSearchArgs, error) {
+	var args courseSearchArgs
+	if err := decodeToolArguments("get_relevant_courses", arguments, &args); err != nil {
+		return args, err
+	}
+
+	var problems []string
+	check := func(field string, value *string, valid bool, format string) {
+		if *value != "" && !valid {
+			problems = append(problems, fmt.Sprintf("%s '%s' is not %s", field, *value, format))
+		}
+	}
+	for _, value := range []*string{
+		&args.CRN, &args.Subject, &args.CourseNumber, &args.Section, &args.TitleShortDesc,
+		&args.PrimaryInstructorEmail, &args.College, &args.MeetDays, &args.BeginTime, &args.EndTime,
+		&args.Building, &args.Room, &args.InstructorFirstName, &args.InstructorLastName,
+		&args.InstructorFullName, &args.Query, &args.SortBy, &args.Cursor,
+	} {
+		*value = strings.TrimSpace(*value)
+	}
+
+	check("CRN", &args.CRN, crnPattern.MatchString(args.CRN), "a five digit CRN such as 40646")
+	args.Subject = strings.ToUpper(args.Subject)
+	check("Subject", &args.Subject, subjectNamePattern.MatchString(args.Subject), "a subject code such as CS or a subject such as Computer Science")
+	args.CourseNumber = strings.ToUpper(args.CourseNumber)
+	check("CourseNumber", &args.CourseNumber, courseNumberFormat.MatchString(args.CourseNumber), "a course number such as 272 or 272L")
+	check("Section", &args.Section, sectionFormat.MatchString(args.Section), "a section number such as 03")
+	if sectionFormat.MatchString(args.Section) {
+		args.Section = normalizeSection(args.Section)
+	}
+	check("PrimaryInstructorEmail", &args.PrimaryInstructorEmail, isEmailAddress(args.PrimaryInstructorEmail), "an email address")
+	args.MeetDays = strings.ToUpper(args.MeetDays)
+	check("MeetDays", &args.MeetDays, meetDaysFormat.MatchString(args.MeetDays) && !hasRepeatedLetter(args.MeetDays),
+		"meeting days written as letters from M, T, W, R (Thursday), F, S and U (Sunday), such as MWF or TR")
+	for _, clock := range []struct {
+		field string
+		value *string
+	}{{"BeginTime", &args.BeginTime}, {"EndTime", &args.EndTime}} {
+		normalized, ok := normalizeClockTime(*clock.value)
+		check(clock.field, clock.value, ok, "a time such as 14:40 or 2:40 PM")
+		if ok {
+			*clock.value = normalized
+		}
+	}
+	args.SortBy = strings.ToLower(args.SortBy)
+	check("SortBy", &args.SortBy, isSortOrder(args.SortBy), fmt.Sprintf("one of %v", sortOrders))
+	if args.PageSize < 0 || args.PageSize > maxPageSize {
+		problems = append(problems, fmt.Sprintf("PageSize %d is not between 1 and %d", args.PageSize, maxPageSize))
+	}
+
+	if len(problems) > 0 {
+		return args, &toolArgumentError{Tool: "get_relevant_courses", Problems: problems}
+	}
+	return args, nil
+}
+
+// the metadata conditions of the arguments, in the order of the tool's parameters. The fuzzy
+// instructor name and course title are left out since they are looked up separately.
+func (args courseSearchArgs) metadataConditions() []map[string]interface{} {
+	var conditions []map[string]interface{}
+	for _, field := range []struct {
+		key   string
+		value string
+	}{
+		{"CRN", args.CRN},
+		{"Subject", args.Subject},
+		{"CourseNumber", args.CourseNumber},
+		{"Section", args.Section},
+		{"PrimaryInstructorEmail", args.PrimaryInstructorEmail},
+		{"College", args.College},
+		{"MeetDays", args.MeetDays},
+		{"BeginTime", args.BeginTime},
+		{"EndTime", args.EndTime},
+		{"Building", args.Building},
+		{"Room", args.Room},
+		{"InstructorFirstName", args.InstructorFirstName},
+		{"InstructorLastName", args.InstructorLastName},
+	} {
+		if field.value != "" {
+			conditions = append(conditions, map[string]interface{}{field.key: field.value})
+		}
+	}
+	return conditions
+}
+
+// emailArgs are the arguments of email_instructor
+type emailArgs struct {
+	Email string `json:"email"`
+}
+
+func parseEmailArgs(arguments string) (emailArgs, error) {
+	var args emailArgs
+	if err := decodeToolArguments("email_instructor", arguments, &args); err != nil {
+		return args, err
+	}
+	args.Email = strings.TrimSpace(args.Email)
+	switch {
+	case args.Email == "":
+		return args, &toolArgumentError{Tool: "email_instructor", Problems: []string{"email is required"}}
+	case !isEmailAddress(args.Email):
+		return args, &toolArgumentError{Tool: "email_instructor", Problems: []string{fmt.Sprintf("email '%s' is not an email address", args.Email)}}
+	}
+	return args, nil
+}
+
+// instructorArgs are the arguments of get_instructor
+type instructorArgs struct {
+	Name  string `json:"name"`
+	Email string `json:"email"`
+}
+
+func parseInstructorArgs(arguments string) (instructorArgs, error) {
+	var args instructorArgs
+	if err := decodeToolArguments("get_instructor", arguments, &args); err != nil {
+		return args, err
+	}
+	args.Name = strings.TrimSpace(args.Name)
+	args.Email = strings.TrimSpace(args.Email)
+	switch {
+	case args.Name == "" && args.Email == "":
+		return args, &toolArgumentError{Tool: "get_instructor", Problems: []string{"name or email is required"}}
+	case args.Email != "" && !isEmailAddress(args.Email):
+		return args, &toolArgumentError{Tool: "get_instructor", Problems: []string{fmt.Sprintf("email '%s' is not an email address", args.Email)}}
+	}
+	return args, nil
+}
+
+// decodes and validates the arguments of check_eligibility
+func parseEligibilityArgs(arguments string) (eligibilityArgs, error) {
+	var args eligibilityArgs
+	if err := decodeToolArguments("check_eligibility", arguments, &args); err != nil {
+		return args, err
+	}
+
+	var problems []string
+	if len(args.Courses) == 0 {
+		problems = append(problems, "courses must list at least one course or subject code")
+	}
+	for i, course := range args.Courses {
+		args.Courses[i] = strings.TrimSpace(course)
+		if _, ok := normalizeCourseCode(args.Courses[i]); !ok && !subjectCodePattern.MatchString(args.Courses[i]) {
+			problems = append(problems, fmt.Sprintf("course '%s' is not a course code such as CS 315 or a subject code such as CS", course))
+		}
+	}
+	if _, invalid := completedCourseSet(args.CompletedCourses); len(invalid) > 0 {
+		problems = append(problems, fmt.Sprintf("completed_courses %q are not course codes such as CS 110", invalid))
+	}
+
+	if len(problems) > 0 {
+		return args, &toolArgumentError{Tool: "check_eligibility", Problems: problems}
+	}
+	return args, nil
+}
+
+// whether the value is a bare email address, without a display name
+func isEmailAddress(value string) bool {
+	address, err := mail.ParseAddress(value)
+	return err == nil && address.Address == value && address.Name == ""
+}
+
+func hasRepeatedLetter(value string) bool {
+	seen := make(map[rune]bool)
+	for _, r := range value {
+		if seen[r] {
+			return true
+		}
+		seen[r] = true
+	}
+	return false
+}
+
+// turns "14:40", "1440", "2:40 PM" or "2 pm" into the schedule's "1440"
+func normalizeClockTime(value string) (string, bool) {
+	value = strings.TrimSpace(value)
+	if matches := twentyFourHourClock.FindStringSubmatch(value); matches != nil {
+		hour, _ := strconv.Atoi(matches[1])
+		minute, _ := strconv.Atoi(matches[2])
+		if hour > 23 || minute > 59 {
+			return "", false
+		}
+		return fmt.Sprintf("%02d%02d", hour, minute), true
+	}
+
+	match := timePattern.FindStringSubmatch(value)
+	if match == nil || match[0] != value {
+		return "", false
+	}
+	minutes, ok := parseAnswerTime(match)
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%02d%02d", minutes/60, minutes%60), true
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/sashabaranov/go-openai"
+)
+
+func TestParseCourseSearchArgs(t *testing.T) {
+	tests := []struct {
+		name     string
+		args     string
+		expected courseSearchArgs
+		problems []string
+	}{
+		{
+			name: "values are normalized",
+			args: `{"Subject": " cs ", "CourseNumber": "272l", "Section": "3", "MeetDays": "mwf", "BeginTime": "2:40 PM", "EndTime": "16:25", "SortBy": "Time"}`,
+			expected: courseSearchArgs{
+				Subject: "CS", CourseNumber: "272L", Section: "03", MeetDays: "MWF",
+				BeginTime: "1440", EndTime: "1625", SortBy: sortByTime,
+			},
+		},
+		{
+			name:     "no arguments",
+			args:     ``,
+			expected: courseSearchArgs{},
+		},
+		{
+			name:     "unknown argument",
+			args:     `{"Subject": "CS", "Professor": "Benson"}`,
+			problems: []string{`unknown argument "Professor"`},
+		},
+		{
+			name:     "wrong type",
+			args:     `{"CRN": 40646}`,
+			problems: []string{"CRN must be a string, not a number"},
+		},
+		{
+			name:     "invalid JSON",
+			args:     `{"Subject": `,
+			problems: []string{"the arguments are not valid JSON: they end too early"},
+		},
+		{
+			name: "invalid values",
+			args: `{"CRN": "4064", "PrimaryInstructorEmail": "Greg Benson", "MeetDays": "MMW", "BeginTime": "25:00", "SortBy": "popularity", "PageSize": 500}`,
+			problems: []string{
+				"CRN '4064' is not a five digit CRN such as 40646",
+				"PrimaryInstructorEmail 'Greg Benson' is not an email address",
+				"MeetDays 'MMW' is not meeting days written as letters from M, T, W, R (Thursday), F, S and U (Sunday), such as MWF or TR",
+				"BeginTime '25:00' is not a time such as 14:40 or 2:40 PM",
+				"SortBy 'popularity' is not one of [relevance time course_number enrollment]",
+				"PageSize 500 is not between 1 and 50",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			args, err := parseCourseSearchArgs(test.args)
+			if test.problems == nil {
+				if err != nil {
+					t.Fatalf("Unexpected error: %v", err)
+				}
+				if !reflect.DeepEqual(args, test.expected) {
+					t.Errorf("Expected %+v, got %+v", test.expected, args)
+				}
+				return
+			}
+
+			var argumentErr *toolArgumentError
+			if !errors.As(err, &argumentErr) {
+				t.Fatalf("Expected an argument error, got %v", err)
+			}
+			if !reflect.DeepEqual(argumentErr.Problems, test.problems) {
+				t.Errorf("Expected problems %q, got %q", test.problems, argumentErr.Problems)
+			}
+		})
+	}
+}
+
+func TestNormalizeClockTime(t *testing.T) {
+	tests := []struct {
+		value    string
+		expected string
+		ok       bool
+	}{
+		{"0900", "0900", true},
+		{"9:00", "0900", true},
+		{"09:00 AM", "0900", true},
+		{"2 pm", "1400", true},
+		{"12:15 p.m.", "1215", true},
+		{"12:30 AM", "0030", true},
+		{"24:00", "", false},
+		{"13 PM", "", false},
+		{"noon", "", false},
+		{"9:00 AM tomorrow", "", false},
+	}
+	for _, test := range tests {
+		t.Run(test.value, func(t *testing.T) {
+			got, ok := normalizeClockTime(test.value)
+			if got != test.expected || ok != test.ok {
+				t.Errorf("Expected %q, %v, got %q, %v", test.expected, test.ok, got, ok)
+			}
+		})
+	}
+}
+
+func TestParseToolArgsRejectsInvalidValues(t *testing.T) {
+	tests := []struct {
+		name  string
+		parse func() error
+	}{
+		{"missing email", func() error { _, err := parseEmailArgs(`{}`); return err }},
+		{"email with a display name", func() error { _, err := parseEmailArgs(`{"email": "Greg <benson@usfca.edu>"}`); return err }},
+		{"email that isn't a string", func() error { _, err := parseEmailArgs(`{"email": ["benson@usfca.edu"]}`); return err }},
+		{"instructor without a name or email", func() error { _, err := parseInstructorArgs(`{"name": " "}`); return err }},
+		{"instructor email that isn't an address", func() error { _, err := parseInstructorArgs(`{"email": "benson"}`); return err }},
+		{"no courses", func() error { _, err := parseEligibilityArgs(`{"completed_courses": ["CS 110"]}`); return err }},
+		{"course that isn't a code", func() error {
+			_, err := parseEligibilityArgs(`{"completed_courses": [], "courses": ["Computer Architecture"]}`)
+			return err
+		}},
+		{"completed course that isn't a code", func() error {
+			_, err := parseEligibilityArgs(`{"completed_courses": ["intro to programming"], "courses": ["CS 315"]}`)
+			return err
+		}},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var argumentErr *toolArgumentError
+			if err := test.parse(); !errors.As(err, &argumentErr) {
+				t.Errorf("Expected an argument error, got %v", err)
+			}
+		})
+	}
+
+	args, err := parseEligibilityArgs(`{"completed_courses": ["CS 110", "112"], "courses": [" cs315 ", "MATH"]}`)
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(args.Courses, []string{"cs315", "MATH"}) {
+		t.Errorf("Expected trimmed courses, got %q", args.Courses)
+	}
+}
+
+// invalid arguments go back to the model as the tool's response, asking it to call the tool again
+func TestRunToolReportsInvalidArguments(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+	tests := []struct {
+		name     string
+		tool     string
+		args     string
+		expected string
+	}{
+		{"course search", "get_relevant_courses", `{"CRN": "abc"}`, "Fix these arguments and call get_relevant_courses again."},
+		{"email", "email_instructor", `{"email": 42}`, "email must be a string, not a number"},
+		{"eligibility", "check_eligibility", `{"courses": ["CS 315"], "semester": "fall"}`, `unknown argument "semester"`},
+		{"eligibility for a course name", "check_eligibility", `{"courses": ["calculus"]}`, `course 'calculus' is not a course code`},
+		{"eligibility for a code with a typo", "check_eligibility", `{"courses": ["CS 3I5"]}`, `course 'CS 3I5' is not a course code`},
+		{"unknown tool", "get_weather", `{}`, "there is no tool named 'get_weather'"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			call := openai.ToolCall{Function: openai.FunctionCall{Name: test.tool, Arguments: test.args}}
+			content, retrieved, err := runTool(db, call)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if !strings.Contains(content, test.expected) {
+				t.Errorf("Expected the response to contain %q, got %q", test.expected, content)
+			}
+			if len(retrieved) != 0 {
+				t.Errorf("Expected no courses, got %d", len(retrieved))
+			}
+		})
+	}
+}
@@ -3,108 +3,138 @@ package main
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"io"
 	"os"
-	"github.com/sashabaranov/go-openai"
+	"os/signal"
+	"time"
 )
 
-func StartUserInterface(db *Db){
+// how long a question may take in total, tool calls and retries included
+const turnTimeout = 3 * time.Minute
+
+// how soon after one Ctrl-C a second one has to come to exit
+const quitWindow = 2 * time.Second
+
+func StartUserInterface(db *Db, verifier *Verifier){
 	// openai client
-	ctx := context.Background();
-	client := openai.NewClient(os.Getenv("OPENAI_API_KEY"))
+	client := db.newChatClient()
+
+	agent := NewAgent(db, client)
+
+	// Ctrl-C cancels the question being answered rather than killing the program
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	defer signal.Stop(interrupts)
 
-	courseTool := MakeTool();
-	emailTool := EmailTool();
-	dialogue := InitializeDialogue()
+	runUserInterface(agent, verifier, readLines(os.Stdin), interrupts)
+}
 
-	// scanner to take in command line inputs from user
-	scanner := bufio.NewScanner(os.Stdin)
+// asks the agent every line the user enters until they enter q, press Ctrl-C twice in a row or
+// close the input. Ctrl-C while a question is being answered cancels just that question.
+func runUserInterface(agent *Agent, verifier *Verifier, lines <-chan string, interrupts <-chan os.Signal) {
+	// when the last Ctrl-C was pressed, whether it cancelled a question or not
+	var lastInterrupt time.Time
 	fmt.Print("Search> ")
-	for scanner.Scan(){
-		question := scanner.Text()
+	for {
+		var question string
+		select {
+		case line, open := <-lines:
+			if !open {
+				return
+			}
+			question = line
+		case <-interrupts:
+			fmt.Println()
+			if !lastInterrupt.IsZero() && time.Since(lastInterrupt) < quitWindow {
+				return
+			}
+			lastInterrupt = time.Now()
+			fmt.Print("Press Ctrl-C again or type q to quit.\nSearch> ")
+			continue
+		}
 		if question == "q"{
 			return
 		}
-		
-		dialogue = append(dialogue, openai.ChatCompletionMessage{
-            Role:    openai.ChatMessageRoleUser,
-            Content: question,
-        })	
 
-		resp, err := client.CreateChatCompletion(ctx,
-			openai.ChatCompletionRequest{
-				Model: openai.GPT4oMini,
-				Messages: dialogue,
-				Tools:    []openai.Tool{courseTool, emailTool},
-			},
-		)
-		if err != nil {
-			fmt.Printf("Completion error:%v len(choices):%v\n", err,
-				len(resp.Choices))
-			return	
+		if answerQuestion(agent, verifier, question, interrupts) {
+			lastInterrupt = time.Now()
 		}
-		if len(resp.Choices) == 0{
-			fmt.Printf("No OpenAI response found. Skipping...\n")
-			continue
+		fmt.Print("Search> ")
+	}
+}
+
+// scans r on its own goroutine so that waiting for the user's input can be interrupted
+func readLines(r io.Reader) <-chan string {
+	lines := make(chan string)
+	go func() {
+		defer close(lines)
+		// scanner to take in command line inputs from user
+		scanner := bufio.NewScanner(r)
+		for scanner.Scan(){
+			lines <- scanner.Text()
 		}
+	}()
+	return lines
+}
 
-		msg := resp.Choices[0].Message
-		dialogue = append(dialogue, msg)
-		// if the AI called tools, handle the tools appropriately
-		if len(msg.ToolCalls) != 0{
-			for _, tool := range msg.ToolCalls {
-				fmt.Printf("OpenAI called us back wanting to invoke our function '%v' with params '%v'\n",
-					tool.Function.Name, tool.Function.Arguments)
-				
-				var content string
-				// determine which tool is called and perform the appropriate action
-				if tool.Function.Name == "email_instructor" {
-					if err := emailProfessor(tool.Function.Arguments); err != nil {
-						fmt.Printf("Error opening email: %v\n", err)
-					}
-					content = "an email draft has been opened successfully and the user has sent an email to the recipient."
-				} else if tool.Function.Name == "get_relevant_courses" {
-					whereFilter, err := BuildWhereFilterFromJSONString(db, tool.Function.Arguments)
-					if err != nil {
-						fmt.Printf("Error building WhereFilter: %v\n", err)
-						continue
-					} else {
-						fmt.Printf("Trying to build WhereFilter with params: %v\nGot: %v\n\n", tool.Function.Arguments, whereFilter)
-					}
-		
-					queryResults := queryDB(db, whereFilter)
-		
-					// add the chromaDB query results to our dialogue as a new chat message
-					content = `If you believe you have enough information to answer the original user question with the information attached below, then answer it. Be sure to include all options to the user's question: ` + queryResults + "\n\nHowever, if you do not think you have enough information, then feel free to make another tool call."
-				}
-				// append the tool's response to our dialogue as a new chat message
-				dialogue = append(dialogue, openai.ChatCompletionMessage{
-					Role:       openai.ChatMessageRoleTool,
-					Content:    content,
-					Name:       tool.Function.Name,
-					ToolCallID: tool.ID,
-				})
-			}
-			
-			fmt.Printf("Sending OpenAI our function's response and requesting the reply to the original question...\n")
-			resp, err = client.CreateChatCompletion(ctx,
-				openai.ChatCompletionRequest{
-					Model: openai.GPT4oMini,
-					Messages: dialogue,
-					Tools:    []openai.Tool{courseTool, emailTool},
-				},
-			)
-			if err != nil || len(resp.Choices) != 1 {
-				fmt.Printf("2nd completion error: err:%v len(choices):%v\n", err,
-					len(resp.Choices))
-				return
-			}
-			msg = resp.Choices[0].Message
+// answers one question and prints the answer, or nothing but a note if it is interrupted, which is
+// reported so that a second Ctrl-C soon after exits
+func answerQuestion(agent *Agent, verifier *Verifier, question string, interrupts <-chan os.Signal) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), turnTimeout)
+	defer cancel()
+	answered := make(chan struct{})
+	defer close(answered)
+	go func() {
+		select {
+		case <-interrupts:
+			cancel()
+		case <-answered:
 		}
-		
-		
-		// display OpenAI's response to the original question utilizing our function
-		fmt.Printf("%v\n", msg.Content)
-		fmt.Print("Search> ")
+	}()
+	// the timeout ends the context with DeadlineExceeded, so Canceled can only be Ctrl-C
+	interrupted := func() bool {
+		return errors.Is(ctx.Err(), context.Canceled)
+	}
+
+	before := agent.checkpoint()
+	turn, err := agent.Ask(ctx, question)
+	if err != nil {
+		switch {
+		case interrupted():
+			fmt.Printf("\nCancelled, the question was dropped.\n")
+			return true
+		case errors.Is(err, errNoResponse):
+			fmt.Printf("No OpenAI response found. Skipping...\n")
+		case isBusyError(err):
+			fmt.Printf("The service is busy right now, please try again in a moment. (%v)\n", err)
+		default:
+			fmt.Printf("Something went wrong answering that question, please try again: %v\n", err)
+		}
+		return false
+	}
+
+	// check the answer against the courses the tools returned, asking the model to fix it if needed
+	answer, outcome := verifier.Review(question, turn.Answer, turn.Sources, func(feedback string) (string, error) {
+		return agent.Revise(ctx, feedback)
+	})
+	if interrupted() {
+		// the answer was never shown, so the conversation goes back to before the question
+		agent.rewind(before)
+		fmt.Printf("\nCancelled, the question was dropped.\n")
+		return true
+	}
+
+	// display OpenAI's response to the original question utilizing our function
+	fmt.Printf("%v\n", answer)
+	// followed by the courses it cited and any citations that don't hold up
+	report := checkCitations(answer, turn.Sources)
+	if verifier.Mode != verifyOff {
+		report.Mismatches = outcome.Warnings()
+	}
+	if footer := formatSourcesFooter(report, turn.Sources); footer != "" {
+		fmt.Printf("\n%v", footer)
 	}
-}
\ No newline at end of file
+	return false
+}
@@ -5,88 +5,150 @@ import (
 	"fmt"
 	"os/exec"
 	"runtime"
+	"sort"
 	"strings"
-)
 
-func BuildWhereFilterFromJSONString(db *Db, jsonStr string) (map[string]interface{}, error) {
-	var data map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
-		return nil, fmt.Errorf("failed to unmarshal JSON: %w", err)
-	}
+	"github.com/sashabaranov/go-openai"
+)
 
-	var orConditions []map[string]interface{}
+// performs the action for a tool call from the model and returns the content of the tool's response
+// along with the courses it returned, which the answer may cite. Failures the model can act on are
+// the content; an error is only returned when the question can't go on.
+func runTool(db *Db, tool openai.ToolCall) (string, []Course, error) {
+	var (
+		content   string
+		retrieved []Course
+	)
+	// determine which tool is called and perform the appropriate action
+	switch tool.Function.Name {
+	case "email_instructor":
+		if err := emailProfessor(tool.Function.Arguments); err != nil {
+			fmt.Printf("Error opening email: %v\n", err)
+			content, err := toolFailure("the email draft could not be opened", err)
+			return content, nil, err
+		}
+		content = "an email draft has been opened successfully and the user has sent an email to the recipient."
+	case "get_relevant_courses":
+		q, err := courseQueryFromJSONString(db, tool.Function.Arguments)
+		if err != nil {
+			fmt.Printf("Error building WhereFilter: %v\n", err)
+			content, err := toolFailure("the course search failed", err)
+			return content, nil, err
+		} else {
+			fmt.Printf("Trying to build WhereFilter with params: %v\nGot: %v\n\n", tool.Function.Arguments, q.Where)
+		}
 
-	for key, value := range data {
-		// skip instructor name and subject for now because we will look at it individually later
-		if key == "InstructorFullName" || key == "TitleShortDesc" {
-			continue
+		queryResults, courses, err := queryDB(db, q)
+		if err != nil {
+			fmt.Printf("Error querying collection: %v\n", err)
+			content, err := toolFailure("the course search failed", err)
+			return content, nil, err
 		}
+		retrieved = courses
 
-		if strValue, ok := value.(string); ok {
-			trimmed := strings.TrimSpace(strValue)
-			if trimmed == "" {
-				continue
+		// add the chromaDB query results to our dialogue as a new chat message
+		content = `If you believe you have enough information to answer the original user question with the information attached below, then answer it. Be sure to include all options to the user's question: ` + queryResults + "\n\nIf \"truncated\" is true, tell the user how many courses were found in \"total\" and that more are available; if they ask to see more, call get_relevant_courses again with only the \"Cursor\" argument set to \"nextCursor\". However, if you do not think you have enough information, then feel free to make another tool call."
+	case "get_instructor":
+		result, sections, err := getInstructor(db, tool.Function.Arguments)
+		retrieved = sections
+		if err != nil {
+			fmt.Printf("Error getting instructor: %v\n", err)
+			if content, err = toolFailure("the instructor lookup failed", err); err != nil {
+				return "", nil, err
+			}
+		} else {
+			content = `Answer the user's question with the instructor profiles below. If several instructors share the name, tell the user and list each with their email. Cite the CRN of every section you mention from their "schedule", and only give office hours that appear in "officeHours": ` + result
+		}
+	case "check_eligibility":
+		result, sections, err := checkEligibility(db, tool.Function.Arguments)
+		retrieved = sections
+		if err != nil {
+			fmt.Printf("Error checking eligibility: %v\n", err)
+			if content, err = toolFailure("the eligibility check failed", err); err != nil {
+				return "", nil, err
 			}
-			orConditions = append(orConditions, map[string]interface{}{key: trimmed})
 		} else {
-			orConditions = append(orConditions, map[string]interface{}{key: value})
+			content = `Answer the user's question with the eligibility results below. For every course, show the prerequisite path from its "evidence" field and list the sections the user can register for: ` + result
 		}
+	default:
+		content = fmt.Sprintf("there is no tool named '%s'; call one of the tools you were given", tool.Function.Name)
 	}
+	return content, retrieved, nil
+}
 
-	// turn fuzzy instructor name into canonical name
-	if instructorFullName, exists := data["InstructorFullName"]; exists {
-		if strValue, ok := instructorFullName.(string); ok {
-			trimmed := strings.TrimSpace(strValue)
-			if trimmed != "" {
-				queryResults, err := db.instructorsCollection.Query(
-					db.ctx,
-					[]string{trimmed},
-					1,
-					nil,
-					nil,
-					nil,
-				)
-				if err != nil {
-					return nil, fmt.Errorf("error querying instructors collection: %w", err)
-				}
+// builds the metadata filter of a get_relevant_courses tool call
+func BuildWhereFilterFromJSONString(db *Db, jsonStr string) (map[string]interface{}, error) {
+	args, err := parseCourseSearchArgs(jsonStr)
+	if err != nil {
+		return nil, err
+	}
+	return buildWhereFilter(db, args)
+}
 
-				// check if the collection actually returned anything
-				if len(queryResults.Documents) > 0 && len(queryResults.Documents[0]) > 0 {
-					instructorFullName := queryResults.Documents[0][0]
-					fmt.Println("Instructor canonical name: ", instructorFullName)
+func buildWhereFilter(db *Db, args courseSearchArgs) (map[string]interface{}, error) {
+	// turn a subject written out, such as "Computer Science" or "Comp Sci", into its code
+	if args.Subject != "" {
+		subject, ok, err := resolveSubject(db, args.Subject)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			args.Subject = subject.Code
+		}
+	}
+	orConditions := args.metadataConditions()
+
+	// turn fuzzy instructor name into canonical name
+	if args.InstructorFullName != "" {
+		instructors, err := matchInstructors(db, args.InstructorFullName)
+		if err != nil {
+			return nil, err
+		}
 
-					orConditions = append(orConditions, map[string]interface{}{"InstructorFullName": instructorFullName})
+		// match every spelling of the instructor's name on the schedule
+		seen := make(map[string]bool)
+		for _, instructor := range instructors {
+			fmt.Println("Instructor canonical name: ", instructor.Name)
+			for _, name := range instructor.NameVariants {
+				if !seen[name] {
+					seen[name] = true
+					orConditions = append(orConditions, map[string]interface{}{"InstructorFullName": name})
 				}
 			}
 		}
 	}
 
-	if titleShortDesc, exists := data["TitleShortDesc"]; exists {
-		if strValue, ok := titleShortDesc.(string); ok {
-			trimmed := strings.TrimSpace(strValue)
-			if trimmed != "" {
-				queryResults, err := db.subjectsCollection.Query(
-					db.ctx,
-					[]string{trimmed},
-					1,
-					nil,
-					nil, 
-					nil,  
-				)
-				if err != nil {
-					return nil, fmt.Errorf("error querying subjects collection: %w", err)
-				}
-
-				if len(queryResults.Documents) > 0 && len(queryResults.Documents[0]) > 0 {
-					subjectName := queryResults.Documents[0][0]
-					fmt.Println("Canonical subject course name: ", subjectName)
-					orConditions = append(orConditions, map[string]interface{}{"TitleShortDesc": subjectName})
+	if args.TitleShortDesc != "" {
+		// a course code in place of a title, such as "CS272" or "Comp Sci 272"
+		code, isCode, err := resolveCourseCode(db, args.TitleShortDesc)
+		if err != nil {
+			return nil, err
+		}
+		if isCode {
+			fields := strings.Fields(code)
+			fmt.Println("Canonical course code: ", code)
+			orConditions = append(orConditions, map[string]interface{}{
+				"$and": []map[string]interface{}{
+					{"Subject": fields[0]},
+					{"CourseNumber": fields[1]},
+				},
+			})
+		} else {
+			course, ok, err := resolveCourseTitle(db, args.TitleShortDesc)
+			if err != nil {
+				return nil, err
+			}
+			if ok {
+				fmt.Println("Canonical subject course name: ", course.Title)
+				// match every title the course is listed under
+				for _, title := range course.Titles {
+					orConditions = append(orConditions, map[string]interface{}{"TitleShortDesc": title})
 				}
 			}
 		}
 	}
 
-	if len(orConditions) == 1{
+	if len(orConditions) == 1 {
 		orConditions = append(orConditions, map[string]interface{}{"Section": "999"})
 	}
 
@@ -98,53 +160,180 @@ func BuildWhereFilterFromJSONString(db *Db, jsonStr string) (map[string]interfac
 	return whereFilter, nil
 }
 
-func queryDB(db *Db, whereFilter map[string]interface{})string{
-	// query the metadatas with WhereFilter
-	results, err := db.coursesCollection.Query(
-		db.ctx,
-		[]string{"."},
-		50,
-		whereFilter,  
-		nil,
-		nil,
-	)
+// builds the search of a get_relevant_courses tool call, resuming from its cursor if it has one
+func courseQueryFromJSONString(db *Db, jsonStr string) (courseQuery, error) {
+	args, err := parseCourseSearchArgs(jsonStr)
+	if err != nil {
+		return courseQuery{}, err
+	}
+
+	offset := 0
+	if args.Cursor != "" {
+		cursor, err := decodeCursor(args.Cursor)
+		if err != nil {
+			return courseQuery{}, &toolArgumentError{Tool: "get_relevant_courses", Problems: []string{err.Error()}}
+		}
+		args, offset = cursor.Args, cursor.Offset
+	}
+
+	whereFilter, err := buildWhereFilter(db, args)
+	if err != nil {
+		return courseQuery{}, err
+	}
+	q := courseQuery{
+		Args:     args,
+		Where:    whereFilter,
+		Query:    args.Query,
+		SortBy:   args.SortBy,
+		Offset:   offset,
+		PageSize: args.PageSize,
+	}
+	return q.normalized(), nil
+}
+
+// runs the search and returns the requested page of sorted courses
+func queryCourses(db *Db, q courseQuery) (CoursePage, error) {
+	// combine the metadata filter, vector similarity and BM25 into one ranking
+	ids, documents, exact, err := hybridSearch(db, q.Where, q.Query)
+	if err != nil {
+		return CoursePage{}, err
+	}
+
+	var courses []Course
+	for _, id := range ids {
+		retrievedDocument, exists := documents[id]
+		if !exists {
+			continue
+		}
+
+		retrievedCourse, err := decodeCourse(retrievedDocument)
+		if err != nil {
+			fmt.Printf("Error unmarshaling retrieved document: %v\n", err)
+			continue
+		}
+		courses = append(courses, retrievedCourse)
+	}
+
+	sortCourses(courses, q.SortBy)
+	return buildPage(courses, q, exact)
+}
+
+// returns the page of courses as JSON for the model, along with the courses on it
+func queryDB(db *Db, q courseQuery) (string, []Course, error) {
+	page, err := queryCourses(db, q)
+	if err != nil {
+		return "", nil, err
+	}
+
+	pageJSON, err := json.Marshal(page)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal query results: %w", err)
+	}
+	return string(pageJSON), page.Courses, nil
+}
+
+// the arguments of a check_eligibility tool call
+type eligibilityArgs struct {
+	CompletedCourses []string `json:"completed_courses"`
+	Courses          []string `json:"courses"`
+}
+
+// checks the requested courses against the prerequisite graph and attaches the sections of every course
+func checkEligibility(db *Db, jsonStr string) (string, []Course, error) {
+	args, err := parseEligibilityArgs(jsonStr)
 	if err != nil {
-		fmt.Printf("Error querying collection: %v\n", err)
-		fmt.Print("Search> ")
-		return ""
+		return "", nil, err
 	}
 
-	// turn the DB query results into a []string
-	matchingCourses := []string{}
-	for _, doc := range results.Documents {
-		for _, retrievedDocument := range doc {
-			var retrievedCourse map[string]interface{}
-			err = json.Unmarshal([]byte(retrievedDocument), &retrievedCourse)
+	completed, _ := completedCourseSet(args.CompletedCourses)
+
+	var results []Eligibility
+	for _, target := range args.Courses {
+		// a whole subject: check every course of that subject on the schedule
+		if !strings.ContainsAny(target, "0123456789") {
+			subject := strings.ToUpper(target)
+			sections, err := getSections(db, map[string]interface{}{"Subject": subject})
 			if err != nil {
-				fmt.Printf("Error unmarshaling retrieved document: %v\n", err)
-				continue
+				return "", nil, err
+			}
+			byCourse := make(map[string][]Course)
+			for _, section := range sections {
+				code := catalogKey(section.Subject, section.CourseNumber)
+				byCourse[code] = append(byCourse[code], section)
+			}
+			codes := make([]string, 0, len(byCourse))
+			for code := range byCourse {
+				codes = append(codes, code)
 			}
+			sort.Strings(codes)
+			for _, code := range codes {
+				result := db.prereqGraph.CheckEligibility(code, completed)
+				result.Sections = byCourse[code]
+				results = append(results, result)
+			}
+			continue
+		}
 
-			delete(retrievedCourse, "vector")
-			retrievedCourseJSON, _ := json.MarshalIndent(retrievedCourse, "", "  ")
-			matchingCourses = append(matchingCourses, string(retrievedCourseJSON))
+		code, ok := normalizeCourseCode(target)
+		if !ok {
+			return "", nil, &toolArgumentError{Tool: "check_eligibility", Problems: []string{fmt.Sprintf("course '%s' is not a course code such as CS 315 or a subject code such as CS", target)}}
 		}
+		fields := strings.Fields(code)
+		sections, err := getSections(db, map[string]interface{}{
+			"$and": []map[string]interface{}{
+				{"Subject": fields[0]},
+				{"CourseNumber": fields[1]},
+			},
+		})
+		if err != nil {
+			return "", nil, err
+		}
+		result := db.prereqGraph.CheckEligibility(code, completed)
+		result.Sections = sections
+		results = append(results, result)
+	}
+
+	response := map[string]interface{}{
+		"results": results,
+	}
+	resultJSON, err := json.MarshalIndent(response, "", "  ")
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to marshal eligibility results: %w", err)
 	}
-	return strings.Join(matchingCourses, "\n")
+
+	var sections []Course
+	for _, result := range results {
+		sections = append(sections, result.Sections...)
+	}
+	return string(resultJSON), sections, nil
 }
 
-func emailProfessor(jsonStr string)error{
-	// Construct the mailto URL
-	var data map[string]interface{}
-	if err := json.Unmarshal([]byte(jsonStr), &data); err != nil {
-		return fmt.Errorf("failed to unmarshal JSON: %w", err)
+// every section in the courses collection matching the where filter
+func getSections(db *Db, whereFilter map[string]interface{}) ([]Course, error) {
+	results, err := db.coursesCollection.Get(db.ctx, nil, whereFilter)
+	if err != nil {
+		return nil, fmt.Errorf("error getting sections: %w", err)
 	}
-	if _, exists := data["email"]; !exists {
-		return fmt.Errorf("email parameter not found in JSON")
+
+	var sections []Course
+	for _, document := range results.Documents {
+		section, err := decodeCourse(document)
+		if err != nil {
+			fmt.Printf("Error unmarshaling retrieved document: %v\n", err)
+			continue
+		}
+		sections = append(sections, section)
 	}
+	return sections, nil
+}
 
-	professorEmail := data["email"].(string)
-	mailto := fmt.Sprintf("mailto:%s", professorEmail)
+// opens a draft to the instructor in the user's mail client
+func emailProfessor(jsonStr string) error {
+	args, err := parseEmailArgs(jsonStr)
+	if err != nil {
+		return err
+	}
+	mailto := fmt.Sprintf("mailto:%s", args.Email)
 
 	// account for different operating systems
 	var cmd *exec.Cmd
@@ -159,4 +348,4 @@ func emailProfessor(jsonStr string)error{
 
 	// Execute the command to open the mail client
 	return cmd.Start()
-}
\ No newline at end of file
+}
@@ -0,0 +1,247 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+)
+
+// the conditions of a where filter's $or as sorted JSON, since they are built from a map
+func orConditionStrings(t *testing.T, whereFilter map[string]interface{}) []string {
+	t.Helper()
+	conditions, _ := whereFilter["$or"].([]map[string]interface{})
+	strs := []string{}
+	for _, condition := range conditions {
+		data, err := json.Marshal(condition)
+		if err != nil {
+			t.Fatalf("Error marshaling condition: %v", err)
+		}
+		strs = append(strs, string(data))
+	}
+	sort.Strings(strs)
+	return strs
+}
+
+func TestBuildWhereFilterFromJSONString(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+
+	tests := []struct {
+		name     string
+		args     string
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "metadata fields",
+			args:     `{"Subject": "CS", "CourseNumber": "272"}`,
+			expected: []string{`{"CourseNumber":"272"}`, `{"Subject":"CS"}`},
+		},
+		{
+			name:     "a single condition is padded",
+			args:     `{"Subject": " PHIL "}`,
+			expected: []string{`{"Section":"999"}`, `{"Subject":"PHIL"}`},
+		},
+		{
+			name:     "fuzzy instructor name",
+			args:     `{"InstructorFullName": "Phil Peterson"}`,
+			expected: []string{`{"InstructorFullName":"Philip Peterson"}`, `{"Section":"999"}`},
+		},
+		{
+			name:     "fuzzy course title",
+			args:     `{"TitleShortDesc": "bioinformatics"}`,
+			expected: []string{`{"Section":"999"}`, `{"TitleShortDesc":"Bioinformatics"}`},
+		},
+		{
+			name:     "subject written out",
+			args:     `{"Subject": "Comp Sci"}`,
+			expected: []string{`{"Section":"999"}`, `{"Subject":"CS"}`},
+		},
+		{
+			name:     "course code as a title",
+			args:     `{"TitleShortDesc": "cs272"}`,
+			expected: []string{`{"$and":[{"Subject":"CS"},{"CourseNumber":"272"}]}`, `{"Section":"999"}`},
+		},
+		{
+			name:     "abbreviated course title",
+			args:     `{"TitleShortDesc": "Software Dev"}`,
+			expected: []string{`{"Section":"999"}`, `{"TitleShortDesc":"Software Development"}`},
+		},
+		{
+			name:     "search arguments and blank fields are not filters",
+			args:     `{"Query": "ethics", "SortBy": "time", "PageSize": 5, "Subject": "  "}`,
+			expected: []string{},
+		},
+		{
+			name:    "invalid JSON",
+			args:    `{"Subject": `,
+			wantErr: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			whereFilter, err := BuildWhereFilterFromJSONString(db, test.args)
+			if test.wantErr {
+				if err == nil {
+					t.Fatalf("Expected an error, got filter %v", whereFilter)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if got := orConditionStrings(t, whereFilter); !reflect.DeepEqual(got, test.expected) {
+				t.Errorf("Expected conditions %v, got %v", test.expected, got)
+			}
+		})
+	}
+}
+
+func TestQueryDB(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+	or := func(conditions ...map[string]interface{}) map[string]interface{} {
+		return map[string]interface{}{"$or": conditions}
+	}
+
+	tests := []struct {
+		name          string
+		query         courseQuery
+		expectedCRNs  []string
+		expectedTotal int
+		truncated     bool
+	}{
+		{
+			name:          "subject sorted by course number",
+			query:         courseQuery{Where: or(map[string]interface{}{"Subject": "CS"}, map[string]interface{}{"Section": "999"}), SortBy: sortByCourseNumber},
+			expectedCRNs:  []string{"40646", "42344", "40649", "42345"},
+			expectedTotal: 4,
+		},
+		{
+			name:          "instructor sorted by time",
+			query:         courseQuery{Where: or(map[string]interface{}{"InstructorLastName": "Benson"}, map[string]interface{}{"Section": "999"}), SortBy: sortByTime},
+			expectedCRNs:  []string{"40649", "42345"},
+			expectedTotal: 2,
+		},
+		{
+			name:          "paged",
+			query:         courseQuery{Where: or(map[string]interface{}{"Subject": "CS"}, map[string]interface{}{"Section": "999"}), SortBy: sortByCourseNumber, PageSize: 2},
+			expectedCRNs:  []string{"40646", "42344"},
+			expectedTotal: 4,
+			truncated:     true,
+		},
+		{
+			name:          "no matches",
+			query:         courseQuery{Where: or(map[string]interface{}{"Subject": "MATH"}, map[string]interface{}{"Section": "999"})},
+			expectedCRNs:  []string{},
+			expectedTotal: 0,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			content, courses, err := queryDB(db, test.query.normalized())
+			if err != nil {
+				t.Fatalf("Error querying: %v", err)
+			}
+
+			var page CoursePage
+			if err := json.Unmarshal([]byte(content), &page); err != nil {
+				t.Fatalf("Error unmarshaling page %q: %v", content, err)
+			}
+			if got := crns(courses); !reflect.DeepEqual(got, test.expectedCRNs) {
+				t.Errorf("Expected CRNs %v, got %v", test.expectedCRNs, got)
+			}
+			if !reflect.DeepEqual(crns(page.Courses), crns(courses)) {
+				t.Errorf("The page sent to the model %v differs from the courses returned %v", crns(page.Courses), crns(courses))
+			}
+			if page.Total != test.expectedTotal || page.Truncated != test.truncated {
+				t.Errorf("Expected total %d and truncated %v, got %d and %v", test.expectedTotal, test.truncated, page.Total, page.Truncated)
+			}
+		})
+	}
+}
+
+func TestQueryDBFollowsCursor(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+	args := `{"Subject": "CS", "SortBy": "course_number", "PageSize": 2}`
+
+	var got []string
+	for page := 0; page < 3; page++ {
+		q, err := courseQueryFromJSONString(db, args)
+		if err != nil {
+			t.Fatalf("Error building query: %v", err)
+		}
+		content, courses, err := queryDB(db, q)
+		if err != nil {
+			t.Fatalf("Error querying: %v", err)
+		}
+		got = append(got, crns(courses)...)
+
+		var result CoursePage
+		if err := json.Unmarshal([]byte(content), &result); err != nil {
+			t.Fatalf("Error unmarshaling page: %v", err)
+		}
+		if result.NextCursor == "" {
+			break
+		}
+		args = `{"Cursor": "` + result.NextCursor + `"}`
+	}
+
+	expected := []string{"40646", "42344", "40649", "42345"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected CRNs %v across pages, got %v", expected, got)
+	}
+}
+
+func TestQueryDBRanksQueryText(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+
+	_, courses, err := queryDB(db, courseQuery{Query: "bioinformatics"}.normalized())
+	if err != nil {
+		t.Fatalf("Error querying: %v", err)
+	}
+	if len(courses) == 0 || courses[0].CRN != "40519" {
+		t.Errorf("Expected Bioinformatics (40519) first, got %v", crns(courses))
+	}
+}
+
+func TestCourseQueryRefusesTamperedCursor(t *testing.T) {
+	db := newFakeDb(t, fakeCourses)
+	encode := func(cursor string) string {
+		return `{"Cursor": "` + base64.RawURLEncoding.EncodeToString([]byte(cursor)) + `"}`
+	}
+
+	// a raw filter in a cursor is never trusted; only the arguments are read
+	q, err := courseQueryFromJSONString(db, encode(`{"where": {"$or": [{"CRN": {"$ne": ""}}]}, "offset": 2}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if hasFilterConditions(q.Where) || q.Offset != 2 {
+		t.Errorf("Expected the raw filter to be ignored, got %+v", q)
+	}
+
+	// the arguments are validated and the filter rebuilt from them
+	q, err = courseQueryFromJSONString(db, encode(`{"args": {"Subject": "cs", "BeginTime": "2:40 PM"}, "offset": 2}`))
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	expected := map[string]interface{}{"$or": []map[string]interface{}{{"Subject": "CS"}, {"BeginTime": "1440"}}}
+	if !reflect.DeepEqual(q.Where, expected) || q.Offset != 2 {
+		t.Errorf("Expected filter %v at offset 2, got %v at %d", expected, q.Where, q.Offset)
+	}
+
+	for _, cursor := range []string{
+		`{"args": {"MeetDays": "MMM"}}`,
+		`{"args": {"PageSize": 500}}`,
+		`{"args": {}, "offset": -1}`,
+		`not json`,
+	} {
+		var argumentErr *toolArgumentError
+		if _, err := courseQueryFromJSONString(db, encode(cursor)); !errors.As(err, &argumentErr) {
+			t.Errorf("Expected cursor %s to be refused as invalid arguments, got %v", cursor, err)
+		}
+	}
+}
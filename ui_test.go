@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"testing"
+	"time"
 
 	"github.com/sashabaranov/go-openai"
 )
@@ -212,93 +213,33 @@ func TestAIResponse(t *testing.T){
 		},
 	}
 
-	db, err := initializeDB()
+	db, err := initializeDB(StartOptions{HTTPClient: testHTTPClient(t), Store: storeChroma})
     if err != nil {
         t.Fatalf("Error starting db: %v\n", err)
     }
 
-    client := openai.NewClient(os.Getenv("OPENAI_API_KEY"))
-    courseTool := MakeTool()
-	emailTool := EmailTool();
+    client := db.newChatClient()
     ctx := context.Background()
 
 	for _, test := range tests {
 		t.Run(test.name, func(t *testing.T) {
-			dialogue := InitializeDialogue()
-			dialogue = append(dialogue, openai.ChatCompletionMessage{
-				Role:    openai.ChatMessageRoleUser,
-				Content: test.queryString,
-			})	
-	
-			resp, err := client.CreateChatCompletion(ctx,
-				openai.ChatCompletionRequest{
-					Model: openai.GPT4oMini,
-					Messages: dialogue,
-					Tools:    []openai.Tool{courseTool, emailTool},
-				},
-			)
+			agent := NewAgent(db, client)
+			turn, err := agent.Ask(ctx, test.queryString)
 			if err != nil {
-				t.Errorf("Completion error:%v len(choices):%v\n", err, len(resp.Choices))
-			}
-			if len(resp.Choices) == 0{
-				t.Errorf("No OpenAI response found. Skipping...\n")
+				t.Fatalf("%v\n", err)
 			}
-	
-			msg := resp.Choices[0].Message
-			dialogue = append(dialogue, msg)
-			// if the AI called tools, handle the tools appropriately
-			if len(msg.ToolCalls) != 0{
-				for _, tool := range msg.ToolCalls {
-					fmt.Printf("OpenAI called us back wanting to invoke our function '%v' with params '%v'\n",
-						tool.Function.Name, tool.Function.Arguments)
-					
-					var content string
-					// determine which tool is called and perform the appropriate action
-					if tool.Function.Name == "email_instructor" {
-						if err := emailProfessor(tool.Function.Arguments); err != nil {
-							fmt.Printf("Error opening email: %v\n", err)
-						}
-						content = "an email draft has been opened successfully and the user has sent an email to the recipient."
-					} else if tool.Function.Name == "get_relevant_courses" {
-						whereFilter, err := BuildWhereFilterFromJSONString(db, tool.Function.Arguments)
-						if err != nil {
-							fmt.Printf("Error building WhereFilter: %v\n", err)
-							continue
-						} else {
-							fmt.Printf("Trying to build WhereFilter with params: %v\nGot: %v\n\n", tool.Function.Arguments, whereFilter)
-						}
+			sources := turn.Sources
 			
-						queryResults := queryDB(db, whereFilter)
-			
-						// add the chromaDB query results to our dialogue as a new chat message
-						content = `If you believe you have enough information to answer the original user question with the information attached below, then answer it. Be sure to include all options to the user's question: ` + queryResults + "\n\nHowever, if you do not think you have enough information, then feel free to make another tool call."
-					}
-					// append the tool's response to our dialogue as a new chat message
-					dialogue = append(dialogue, openai.ChatCompletionMessage{
-						Role:       openai.ChatMessageRoleTool,
-						Content:    content,
-						Name:       tool.Function.Name,
-						ToolCallID: tool.ID,
-					})
-				}
-				
-				fmt.Printf("Sending OpenAI our function's response and requesting the reply to the original question...\n")
-				resp, err = client.CreateChatCompletion(ctx,
-					openai.ChatCompletionRequest{
-						Model: openai.GPT4oMini,
-						Messages: dialogue,
-						Tools:    []openai.Tool{courseTool, emailTool},
-					},
-				)
-				if err != nil || len(resp.Choices) != 1 {
-					fmt.Printf("2nd completion error: err:%v len(choices):%v\n", err,
-						len(resp.Choices))
-					return
-				}
-				msg = resp.Choices[0].Message
+			aiFinalResponse := turn.Answer
+
+			// every cited CRN must come from the tool results, and every detail must match its course
+			report := checkCitations(aiFinalResponse, sources)
+			for _, crn := range report.Unknown {
+				t.Errorf("Answer cites CRN %s, which no tool call returned", crn)
+			}
+			for _, mismatch := range verifyAnswer(aiFinalResponse, sources) {
+				t.Errorf("Answer does not match the retrieved courses: %s", mismatch)
 			}
-			
-			aiFinalResponse := msg.Content
 			
 			if resp := compareAIResponseWithExpected(client, aiFinalResponse, test.expected); resp != "true"{
 				t.Errorf(resp)
@@ -330,3 +271,89 @@ func compareAIResponseWithExpected(client *openai.Client, aiResponse, expected s
 
 	return resp.Choices[0].Message.Content
 }
+
+// blockingChat never answers, returning only once the question's context is cancelled
+type blockingChat struct {
+	started chan struct{}
+}
+
+func (c *blockingChat) CreateChatCompletion(ctx context.Context, request openai.ChatCompletionRequest) (openai.ChatCompletionResponse, error) {
+	c.started <- struct{}{}
+	<-ctx.Done()
+	return openai.ChatCompletionResponse{}, ctx.Err()
+}
+
+func TestUserInterfaceInterrupts(t *testing.T) {
+	// sends a line, reporting whether the interface was still there to read it
+	send := func(lines chan<- string, line string) bool {
+		select {
+		case lines <- line:
+			return true
+		case <-time.After(time.Second):
+			return false
+		}
+	}
+
+	tests := []struct {
+		name string
+		// what the user does, given the channels the interface reads; false if the interface stopped
+		// reading too early
+		session func(lines chan<- string, interrupts chan<- os.Signal, started <-chan struct{}) bool
+	}{
+		{"Ctrl-C cancels the question and q exits", func(lines chan<- string, interrupts chan<- os.Signal, started <-chan struct{}) bool {
+			lines <- "What courses does Phil Peterson teach?"
+			<-started
+			interrupts <- os.Interrupt
+			// only read once the interface is back at the prompt
+			return send(lines, "q")
+		}},
+		{"a second Ctrl-C exits", func(lines chan<- string, interrupts chan<- os.Signal, started <-chan struct{}) bool {
+			lines <- "What courses does Phil Peterson teach?"
+			<-started
+			interrupts <- os.Interrupt
+			interrupts <- os.Interrupt
+			return true
+		}},
+		{"one Ctrl-C at the prompt doesn't exit", func(lines chan<- string, interrupts chan<- os.Signal, started <-chan struct{}) bool {
+			interrupts <- os.Interrupt
+			return send(lines, "q")
+		}},
+		{"two Ctrl-Cs at the prompt exit", func(lines chan<- string, interrupts chan<- os.Signal, started <-chan struct{}) bool {
+			interrupts <- os.Interrupt
+			interrupts <- os.Interrupt
+			return true
+		}},
+		{"closing the input exits", func(lines chan<- string, interrupts chan<- os.Signal, started <-chan struct{}) bool {
+			close(lines)
+			return true
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			chat := &blockingChat{started: make(chan struct{}, 1)}
+			agent := NewAgent(newFakeDb(t, fakeCourses), chat)
+			before := agent.checkpoint()
+			lines := make(chan string)
+			interrupts := make(chan os.Signal, 1)
+
+			done := make(chan struct{})
+			go func() {
+				runUserInterface(agent, &Verifier{Mode: verifyOff}, lines, interrupts)
+				close(done)
+			}()
+			if !test.session(lines, interrupts, chat.started) {
+				t.Fatalf("The interface exited before the session was over")
+			}
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatalf("The interface didn't exit")
+			}
+			if agent.checkpoint() != before {
+				t.Errorf("Expected the cancelled question to be dropped from the dialogue, got %d messages instead of %d", agent.checkpoint(), before)
+			}
+		})
+	}
+}
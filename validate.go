@@ -0,0 +1,317 @@
+package main
+
+import (
+	"encoding/csv"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// the kinds of problems validation reports, in the order they are listed
+const (
+	issueUnreadable = "unreadable rows"
+	issueMissing    = "missing fields"
+	issueMalformed  = "malformed times, days and dates"
+	issueDuplicate  = "duplicate IDs"
+	issueUnknown    = "unknown codes"
+	issueEmail      = "suspicious emails"
+)
+
+var issueCategories = []string{issueUnreadable, issueMissing, issueMalformed, issueDuplicate, issueUnknown, issueEmail}
+
+// the fields every section needs, which are ingested as empty strings when they are missing
+var requiredFields = []string{"SUBJ", "CRSE NUM", "SEC", "CRN", "Schedule Type Code", "Campus Code", "Title Short Desc", "Instruction Mode Desc", "Meeting Type Codes", "Meet Start", "Meet End", "BLDG", "College"}
+
+// the codes the schedule uses, so that a typo or a new code isn't taken for a real value. A
+// section can have several meeting types, separated by spaces.
+var knownCodes = []struct {
+	field    string
+	codes    map[string]bool
+	multiple bool
+}{
+	{"Instruction Mode Desc", map[string]bool{"In-Person": true, "Hybrid": true, "Online Synchronous": true, "Online Asynchronous": true, "Traditional": true, "Non-Traditional": true}, false},
+	{"Meeting Type Codes", map[string]bool{"IP": true, "RE": true, "CLAS": true, "OL": true, "HY": true, "FINL": true}, true},
+	{"College", map[string]bool{"LA": true, "ED": true, "SC": true, "NS": true, "BU": true, "LW": true, "PL": true}, false},
+}
+
+var (
+	clockTime    = regexp.MustCompile(`^([01][0-9]|2[0-3])[0-5][0-9]$`)
+	emailAddress = regexp.MustCompile(`^[A-Za-z0-9._%+-]+@[A-Za-z0-9.-]+\.[A-Za-z]{2,}$`)
+)
+
+// the domain of every instructor's email
+const instructorEmailDomain = "usfca.edu"
+
+// ValidationIssue is a problem with a row of the schedule CSV
+type ValidationIssue struct {
+	Line     int
+	Category string
+	Message  string
+}
+
+// ValidationReport is every problem found in the schedule CSV
+type ValidationReport struct {
+	Path   string
+	Rows   int
+	Issues []ValidationIssue
+}
+
+func (r *ValidationReport) add(line int, category, format string, args ...interface{}) {
+	r.Issues = append(r.Issues, ValidationIssue{Line: line, Category: category, Message: fmt.Sprintf(format, args...)})
+}
+
+// the issues of each category, in row order
+func (r *ValidationReport) byCategory() map[string][]ValidationIssue {
+	categories := map[string][]ValidationIssue{}
+	for _, issue := range r.Issues {
+		categories[issue.Category] = append(categories[issue.Category], issue)
+	}
+	for _, issues := range categories {
+		sort.SliceStable(issues, func(i, j int) bool { return issues[i].Line < issues[j].Line })
+	}
+	return categories
+}
+
+// the number of issues in each category
+func (r *ValidationReport) Summary() string {
+	if len(r.Issues) == 0 {
+		return fmt.Sprintf("Validated %d rows of %s: no issues.\n", r.Rows, r.Path)
+	}
+	var b strings.Builder
+	fmt.Fprintf(&b, "Validated %d rows of %s: %d issues.\n", r.Rows, r.Path, len(r.Issues))
+	categories := r.byCategory()
+	for _, category := range issueCategories {
+		if issues := categories[category]; len(issues) > 0 {
+			fmt.Fprintf(&b, "  %s: %d\n", category, len(issues))
+		}
+	}
+	return b.String()
+}
+
+// the summary followed by the issues of each category with their row numbers, at most limit of
+// each; 0 lists them all
+func (r *ValidationReport) Format(limit int) string {
+	var b strings.Builder
+	b.WriteString(r.Summary())
+	categories := r.byCategory()
+	for _, category := range issueCategories {
+		issues := categories[category]
+		if len(issues) == 0 {
+			continue
+		}
+		fmt.Fprintf(&b, "\n%s (%d):\n", category, len(issues))
+		for i, issue := range issues {
+			if limit > 0 && i == limit {
+				fmt.Fprintf(&b, "  ... and %d more\n", len(issues)-limit)
+				break
+			}
+			fmt.Fprintf(&b, "  row %d: %s\n", issue.Line, issue.Message)
+		}
+	}
+	return b.String()
+}
+
+// checks every row of the schedule CSV for missing fields, malformed times, days and dates,
+// repeated CRNs, unknown codes and suspicious instructor emails
+func validateSchedule(path string) (*ValidationReport, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open CSV file: %w", err)
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	headers, err := reader.Read()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSV header: %w", err)
+	}
+	columns := map[string]int{}
+	for i, header := range headers {
+		columns[strings.TrimSpace(header)] = i
+	}
+	for _, header := range []string{"CRN", "Meet Days", "Begin Time", "End Time", "Actual Enrollment", "Primary Instructor First Name", "Primary Instructor Last Name", "Primary Instructor Email"} {
+		if _, exists := columns[header]; !exists {
+			return nil, fmt.Errorf("the CSV has no '%s' column", header)
+		}
+	}
+
+	report := &ValidationReport{Path: path}
+	crns := map[string]int{}
+	emails := map[string]struct {
+		line int
+		name string
+	}{}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		var parseErr *csv.ParseError
+		if errors.As(err, &parseErr) {
+			report.Rows++
+			report.add(parseErr.StartLine, issueUnreadable, "%v", parseErr.Err)
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CSV records: %w", err)
+		}
+		report.Rows++
+		line, _ := reader.FieldPos(0)
+		if len(record) < len(headers) {
+			report.add(line, issueUnreadable, "has %d fields, expected %d", len(record), len(headers))
+			continue
+		}
+		field := func(name string) string {
+			if i, exists := columns[name]; exists {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		for _, name := range requiredFields {
+			if _, exists := columns[name]; exists && field(name) == "" {
+				report.add(line, issueMissing, "%s is empty", name)
+			}
+		}
+		first, last, email := field("Primary Instructor First Name"), field("Primary Instructor Last Name"), field("Primary Instructor Email")
+		switch {
+		case first == "" && last == "":
+			report.add(line, issueMissing, "no instructor, so the section is ingested as Staff/TBA")
+		case first == "" || last == "":
+			report.add(line, issueMissing, "the instructor '%s' is missing a first or last name", strings.TrimSpace(first+" "+last))
+		}
+		if email == "" && (first != "" || last != "") {
+			report.add(line, issueMissing, "Primary Instructor Email is empty")
+		}
+
+		validateMeetings(report, line, field)
+
+		if enrollment := field("Actual Enrollment"); enrollment != "" {
+			if n, err := strconv.Atoi(enrollment); err != nil || n < 0 {
+				report.add(line, issueMalformed, "Actual Enrollment '%s' isn't a number of students", enrollment)
+			}
+		}
+
+		if crn := field("CRN"); crn != "" {
+			if previous, seen := crns[crn]; seen {
+				report.add(line, issueDuplicate, "CRN %s is also on row %d, so this row is skipped when ingesting", crn, previous)
+			} else {
+				crns[crn] = line
+			}
+		}
+
+		for _, known := range knownCodes {
+			value := field(known.field)
+			if value == "" {
+				continue
+			}
+			codes := []string{value}
+			if known.multiple {
+				codes = strings.Fields(value)
+			}
+			for _, code := range codes {
+				if !known.codes[code] {
+					report.add(line, issueUnknown, "%s '%s' isn't a known code", known.field, code)
+				}
+			}
+		}
+
+		if email != "" {
+			name := strings.TrimSpace(first + " " + last)
+			switch {
+			case !emailAddress.MatchString(email):
+				report.add(line, issueEmail, "'%s' isn't an email address", email)
+			case !strings.HasSuffix(strings.ToLower(email), "@"+instructorEmailDomain):
+				report.add(line, issueEmail, "'%s' isn't a %s address", email, instructorEmailDomain)
+			}
+			if previous, seen := emails[strings.ToLower(email)]; seen && previous.name != name {
+				report.add(line, issueEmail, "'%s' belongs to %s here but to %s on row %d", email, name, previous.name, previous.line)
+			} else if !seen {
+				emails[strings.ToLower(email)] = struct {
+					line int
+					name string
+				}{line, name}
+			}
+		}
+	}
+	return report, nil
+}
+
+// checks that the meeting days, times and dates of a row are well formed and consistent
+func validateMeetings(report *ValidationReport, line int, field func(string) string) {
+	days, begin, end := field("Meet Days"), field("Begin Time"), field("End Time")
+	if days != "" {
+		seen := map[rune]bool{}
+		for _, day := range days {
+			if !strings.ContainsRune("MTWRFSU", day) || seen[day] {
+				report.add(line, issueMalformed, "Meet Days '%s' isn't a set of the days MTWRFSU", days)
+				break
+			}
+			seen[day] = true
+		}
+	}
+
+	validTimes := true
+	for _, clock := range []struct{ name, value string }{{"Begin Time", begin}, {"End Time", end}} {
+		if clock.value != "" && !clockTime.MatchString(clock.value) {
+			report.add(line, issueMalformed, "%s '%s' isn't a time like 1440", clock.name, clock.value)
+			validTimes = false
+		}
+	}
+	switch {
+	case (begin == "") != (end == ""):
+		report.add(line, issueMalformed, "has a Begin Time or End Time without the other")
+	case validTimes && begin != "" && begin >= end:
+		report.add(line, issueMalformed, "begins at %s but ends at %s", begin, end)
+	}
+	if days != "" && begin == "" && end == "" {
+		report.add(line, issueMalformed, "meets on %s but has no times", days)
+	}
+	if days == "" && begin != "" {
+		report.add(line, issueMalformed, "has times but no Meet Days")
+	}
+
+	var dates []time.Time
+	for _, date := range []struct{ name, value string }{{"Meet Start", field("Meet Start")}, {"Meet End", field("Meet End")}} {
+		if date.value == "" {
+			continue
+		}
+		parsed, err := time.Parse("1/2/06", date.value)
+		if err != nil {
+			report.add(line, issueMalformed, "%s '%s' isn't a date like 8/20/24", date.name, date.value)
+			continue
+		}
+		dates = append(dates, parsed)
+	}
+	if len(dates) == 2 && dates[0].After(dates[1]) {
+		report.add(line, issueMalformed, "starts on %s after it ends on %s", field("Meet Start"), field("Meet End"))
+	}
+}
+
+func runValidateCommand(args []string) error {
+	flags := flag.NewFlagSet("validate", flag.ContinueOnError)
+	csvFlag := flags.String("csv", scheduleCSVPath, "Schedule CSV to validate")
+	limitFlag := flags.Int("limit", 20, "Most rows listed for each kind of issue; 0 for all of them")
+	strictFlag := flags.Bool("strict", false, "Fail when any issue is found")
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+
+	report, err := validateSchedule(*csvFlag)
+	if err != nil {
+		return err
+	}
+	fmt.Print(report.Format(*limitFlag))
+	if *strictFlag && len(report.Issues) > 0 {
+		return &ValidationError{Path: report.Path, Issues: len(report.Issues)}
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestValidateSchedule(t *testing.T) {
+	header := "SUBJ,CRSE NUM,SEC,CRN,Schedule Type Code,Campus Code,Title Short Desc,Instruction Mode Desc,Meeting Type Codes,Meet Days,Begin Time,End Time,Meet Start,Meet End,BLDG,RM,Actual Enrollment,Primary Instructor First Name,Primary Instructor Last Name,Primary Instructor Email,College"
+	valid := "CS,272,03,40646,LEC,M,Software Development,In-Person,IP,TR,1440,1625,8/20/24,12/4/24,LS,G12,40,Philip,Peterson,phpeterson@usfca.edu,SC"
+
+	tests := []struct {
+		name     string
+		row      string
+		category string
+		// part of the message of the issue
+		message string
+	}{
+		{"no instructor", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,IP,MW,1000,1145,8/20/24,12/4/24,HR,235,12,,,,SC", issueMissing, "ingested as Staff/TBA"},
+		{"no building", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,IP,MW,1000,1145,8/20/24,12/4/24,,,12,Greg,Benson,benson@usfca.edu,SC", issueMissing, "BLDG is empty"},
+		{"blank meeting type", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,,MW,1000,1145,8/20/24,12/4/24,HR,235,12,Greg,Benson,benson@usfca.edu,SC", issueMissing, "Meeting Type Codes is empty"},
+		{"malformed time", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,IP,MW,10am,1145,8/20/24,12/4/24,HR,235,12,Greg,Benson,benson@usfca.edu,SC", issueMalformed, "Begin Time '10am'"},
+		{"ends before it begins", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,IP,MW,1145,1000,8/20/24,12/4/24,HR,235,12,Greg,Benson,benson@usfca.edu,SC", issueMalformed, "begins at 1145 but ends at 1000"},
+		{"malformed days", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,IP,MXW,1000,1145,8/20/24,12/4/24,HR,235,12,Greg,Benson,benson@usfca.edu,SC", issueMalformed, "Meet Days 'MXW'"},
+		{"malformed date", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,IP,MW,1000,1145,2024-08-20,12/4/24,HR,235,12,Greg,Benson,benson@usfca.edu,SC", issueMalformed, "Meet Start '2024-08-20'"},
+		{"repeated CRN", "CS,272,04,40646,LEC,M,Software Development,In-Person,IP,TR,1440,1625,8/20/24,12/4/24,LS,G12,40,Philip,Peterson,phpeterson@usfca.edu,SC", issueDuplicate, "also on row 2"},
+		{"unknown code", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,XX,MW,1000,1145,8/20/24,12/4/24,HR,235,12,Greg,Benson,benson@usfca.edu,SC", issueUnknown, "Meeting Type Codes 'XX'"},
+		{"email of another domain", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,IP,MW,1000,1145,8/20/24,12/4/24,HR,235,12,Greg,Benson,benson@gmail.com,SC", issueEmail, "isn't a usfca.edu address"},
+		{"email of another instructor", "CS,490,01,41000,LEC,M,Senior Team Project,In-Person,IP,MW,1000,1145,8/20/24,12/4/24,HR,235,12,Greg,Benson,phpeterson@usfca.edu,SC", issueEmail, "but to Philip Peterson on row 2"},
+		{"too few fields", "CS,490,01,41000", issueUnreadable, "has 4 fields"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "schedule.csv")
+			if err := os.WriteFile(path, []byte(strings.Join([]string{header, valid, test.row}, "\n")+"\n"), 0644); err != nil {
+				t.Fatalf("Error writing CSV: %v", err)
+			}
+			report, err := validateSchedule(path)
+			if err != nil {
+				t.Fatalf("Unexpected error: %v", err)
+			}
+			if report.Rows != 2 || len(report.Issues) != 1 {
+				t.Fatalf("Expected a single issue in 2 rows, got %d rows and %+v", report.Rows, report.Issues)
+			}
+			issue := report.Issues[0]
+			if issue.Line != 3 || issue.Category != test.category || !strings.Contains(issue.Message, test.message) {
+				t.Errorf("Expected %s on row 3 mentioning %q, got %+v", test.category, test.message, issue)
+			}
+			if !strings.Contains(report.Format(0), "row 3: "+issue.Message) {
+				t.Errorf("Expected the report to list the issue by row, got %q", report.Format(0))
+			}
+		})
+	}
+}
+
+func TestValidateCommandStrict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "schedule.csv")
+	rows := "SUBJ,CRSE NUM,SEC,CRN,Schedule Type Code,Campus Code,Title Short Desc,Instruction Mode Desc,Meeting Type Codes,Meet Days,Begin Time,End Time,Meet Start,Meet End,BLDG,RM,Actual Enrollment,Primary Instructor First Name,Primary Instructor Last Name,Primary Instructor Email,College\n" +
+		"CS,490,01,41000,LEC,M,Senior Team Project,In-Person,IP,MW,1000,1145,8/20/24,12/4/24,HR,235,12,,,,SC\n"
+	if err := os.WriteFile(path, []byte(rows), 0644); err != nil {
+		t.Fatalf("Error writing CSV: %v", err)
+	}
+
+	if err := runValidateCommand([]string{"-csv", path}); err != nil {
+		t.Errorf("Expected issues to only be reported, got %v", err)
+	}
+	var validationErr *ValidationError
+	if err := runValidateCommand([]string{"-csv", path, "-strict"}); !errors.As(err, &validationErr) || validationErr.Issues != 1 {
+		t.Errorf("Expected strict mode to fail with 1 issue, got %v", err)
+	}
+}
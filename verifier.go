@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+// what the verifier does when an answer doesn't match the retrieved courses
+const (
+	verifyOff      = "off"
+	verifyWarn     = "warn"
+	verifyCorrect  = "correct"
+	verifyReprompt = "reprompt"
+)
+
+var verifyModes = []string{verifyOff, verifyWarn, verifyCorrect, verifyReprompt}
+
+// the outcomes recorded in the verification log
+const (
+	outcomePassed    = "passed"
+	outcomeSkipped   = "skipped"
+	outcomeWarned    = "warned"
+	outcomeCorrected = "corrected"
+	outcomeReprompt  = "reprompted"
+)
+
+// Verifier checks the claims of a final answer against the courses returned during the turn
+type Verifier struct {
+	Mode string
+	// JSONL file every outcome is appended to; empty to not log
+	LogPath string
+}
+
+// VerificationOutcome is what the verifier found and did for one answer
+type VerificationOutcome struct {
+	Time        time.Time       `json:"time"`
+	Question    string          `json:"question"`
+	Answer      string          `json:"answer"`
+	FinalAnswer string          `json:"finalAnswer"`
+	Mode        string          `json:"mode"`
+	Outcome     string          `json:"outcome"`
+	Mismatches  []claimMismatch `json:"mismatches,omitempty"`
+	// mismatches still in the final answer, which are shown to the user as warnings
+	Remaining []claimMismatch `json:"remaining,omitempty"`
+	Error     string          `json:"error,omitempty"`
+}
+
+// the warnings left for the user after the verifier is done
+func (outcome VerificationOutcome) Warnings() []string {
+	warnings := make([]string, 0, len(outcome.Remaining))
+	for _, mismatch := range outcome.Remaining {
+		warnings = append(warnings, mismatch.String())
+	}
+	return warnings
+}
+
+func NewVerifier(mode, logPath string) (*Verifier, error) {
+	for _, known := range verifyModes {
+		if mode == known {
+			return &Verifier{Mode: mode, LogPath: logPath}, nil
+		}
+	}
+	return nil, fmt.Errorf("unknown verify mode '%s', expected one of %v", mode, verifyModes)
+}
+
+// verifies an answer and, depending on the mode, corrects it, re-prompts the model through
+// reprompt or leaves it with warnings. Returns the answer to show the user.
+func (v *Verifier) Review(question, answer string, sources map[string]Course, reprompt func(feedback string) (string, error)) (string, VerificationOutcome) {
+	outcome := VerificationOutcome{
+		Time:        time.Now(),
+		Question:    question,
+		Answer:      answer,
+		FinalAnswer: answer,
+		Mode:        v.Mode,
+	}
+	if v.Mode == verifyOff {
+		outcome.Outcome = outcomeSkipped
+		return answer, outcome
+	}
+
+	outcome.Mismatches = verifyAnswer(answer, sources)
+	switch {
+	case len(outcome.Mismatches) == 0:
+		outcome.Outcome = outcomePassed
+	case v.Mode == verifyCorrect:
+		outcome.FinalAnswer, outcome.Remaining = correctAnswer(answer, outcome.Mismatches)
+		outcome.Outcome = outcomeCorrected
+		if len(outcome.Remaining) == len(outcome.Mismatches) {
+			outcome.Outcome = outcomeWarned
+		}
+	case v.Mode == verifyReprompt && reprompt != nil:
+		outcome.Outcome = outcomeReprompt
+		revised, err := reprompt(repromptFeedback(outcome.Mismatches))
+		if err != nil {
+			outcome.Error = err.Error()
+			outcome.Outcome = outcomeWarned
+			outcome.Remaining = outcome.Mismatches
+			break
+		}
+		outcome.FinalAnswer = revised
+		outcome.Remaining = verifyAnswer(revised, sources)
+	default:
+		outcome.Outcome = outcomeWarned
+		outcome.Remaining = outcome.Mismatches
+	}
+
+	if err := v.log(outcome); err != nil {
+		fmt.Printf("Error logging verification outcome: %v\n", err)
+	}
+	return outcome.FinalAnswer, outcome
+}
+
+// appends the outcome to the verification log
+func (v *Verifier) log(outcome VerificationOutcome) error {
+	if v.LogPath == "" {
+		return nil
+	}
+	entry, err := json.Marshal(outcome)
+	if err != nil {
+		return fmt.Errorf("failed to marshal verification outcome: %w", err)
+	}
+	file, err := os.OpenFile(v.LogPath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open verification log: %w", err)
+	}
+	defer file.Close()
+	_, err = file.Write(append(entry, '\n'))
+	return err
+}
+
+// finds every claim in the answer that contradicts the course it refers to. A chunk refers to the
+// courses it cites, or failing that to the retrieved sections of the course code it names.
+func verifyAnswer(answer string, sources map[string]Course) []claimMismatch {
+	knownSubjects := subjectsOf(sources)
+
+	var mismatches []claimMismatch
+	position := 0
+	for _, chunk := range splitAnswer(answer) {
+		offset := position + strings.Index(answer[position:], chunk)
+		position = offset + len(chunk)
+
+		claims := extractClaims(citationPattern.ReplaceAllString(chunk, ""), knownSubjects)
+		if len(claims) == 0 {
+			continue
+		}
+
+		var records []Course
+		for _, crn := range citedCRNs(chunk) {
+			if course, exists := sources[crn]; exists {
+				records = append(records, course)
+			}
+		}
+		if len(records) == 0 {
+			records = sectionsNamedIn(claims, sources)
+		}
+		if len(records) == 0 {
+			continue
+		}
+		for _, mismatch := range compareClaims(claims, records) {
+			mismatch.chunkOffset = offset
+			mismatches = append(mismatches, mismatch)
+		}
+	}
+	return mismatches
+}
+
+// the retrieved sections of the course codes, and sections if given, claimed in a chunk
+func sectionsNamedIn(claims []answerClaim, sources map[string]Course) []Course {
+	codes := make(map[string]bool)
+	sections := make(map[string]bool)
+	for _, claim := range claims {
+		switch claim.Field {
+		case claimCourse:
+			codes[claim.Value] = true
+		case claimSection:
+			sections[claim.Value] = true
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+
+	var records []Course
+	for _, crn := range sortedKeys(sources) {
+		course := sources[crn]
+		if !codes[catalogKey(course.Subject, course.CourseNumber)] {
+			continue
+		}
+		if len(sections) > 0 && !sections[normalizeSection(course.Section)] {
+			continue
+		}
+		records = append(records, course)
+	}
+	return records
+}
+
+// replaces every mismatched value with the value of the record when the chunk refers to a single
+// course, and returns the mismatches it could not correct
+func correctAnswer(answer string, mismatches []claimMismatch) (string, []claimMismatch) {
+	var remaining []claimMismatch
+	// correct from the last chunk to the first so earlier offsets stay valid
+	sorted := append([]claimMismatch{}, mismatches...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].chunkOffset > sorted[j].chunkOffset })
+	for _, mismatch := range sorted {
+		head, tail := answer[:mismatch.chunkOffset], answer[mismatch.chunkOffset:]
+		if len(mismatch.CRNs) != 1 || mismatch.Actual == "none" || mismatch.Actual == "no scheduled time" || !strings.Contains(tail, mismatch.claim.Phrase) {
+			remaining = append(remaining, mismatch)
+			continue
+		}
+		corrected := strings.Replace(mismatch.claim.Phrase, mismatch.claim.Raw, mismatch.Actual, 1)
+		answer = head + strings.Replace(tail, mismatch.claim.Phrase, corrected, 1)
+	}
+	return answer, remaining
+}
+
+// the message sent back to the model when its answer contradicts the records
+func repromptFeedback(mismatches []claimMismatch) string {
+	var b strings.Builder
+	b.WriteString("Your answer contradicts the course records returned by the tools:\n")
+	for _, mismatch := range mismatches {
+		fmt.Fprintf(&b, "- %s\n", mismatch.String())
+	}
+	b.WriteString("Rewrite your answer with these details corrected to match the records exactly, keeping the CRN citations. Reply with the corrected answer only.")
+	return b.String()
+}
+
+func sortedKeys(sources map[string]Course) []string {
+	keys := make([]string, 0, len(sources))
+	for key := range sources {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
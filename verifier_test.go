@@ -0,0 +1,139 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var verifierSources = map[string]Course{
+	"40649": {CRN: "40649", Subject: "CS", CourseNumber: "315", Section: "01", Title: "Computer Architecture", MeetDays: "TR", BeginTime: "1440", EndTime: "1625", Building: "LS", Room: "307", InstructorFirstName: "Greg", InstructorLastName: "Benson"},
+	"42345": {CRN: "42345", Subject: "CS", CourseNumber: "315L", Section: "01", Title: "Laboratory", MeetDays: "W", BeginTime: "1645", EndTime: "1815", Building: "LS", Room: "307", InstructorFirstName: "Greg", InstructorLastName: "Benson"},
+	"42346": {CRN: "42346", Subject: "CS", CourseNumber: "315L", Section: "02", Title: "Laboratory", MeetDays: "W", BeginTime: "1825", EndTime: "1955", Building: "LS", Room: "307", InstructorFirstName: "Greg", InstructorLastName: "Benson"},
+}
+
+func TestVerifyAnswer(t *testing.T) {
+	tests := []struct {
+		name     string
+		answer   string
+		expected []string
+	}{
+		{
+			name:   "correct answer",
+			answer: "CS 315 [CRN 40649] meets Tuesdays and Thursdays from 2:40 PM to 4:25 PM in Room: LS 307 with Greg Benson.",
+		},
+		{
+			name:     "wrong days and building",
+			answer:   "CS 315 [CRN 40649] meets on Mondays and Wednesdays in Building: KA.",
+			expected: []string{"days", "building"},
+		},
+		{
+			name:     "course code without a citation",
+			answer:   "CS 315L Section 02 meets Days: W at 4:45 PM.",
+			expected: []string{"time"},
+		},
+		{
+			name:     "cited CRN of a different course",
+			answer:   "CS 272 [CRN 40649] is taught by Greg Benson.",
+			expected: []string{"course"},
+		},
+		{
+			name:   "nothing to resolve",
+			answer: "I could not find any guitar courses.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var fields []string
+			for _, mismatch := range verifyAnswer(test.answer, verifierSources) {
+				fields = append(fields, mismatch.Field)
+			}
+			if strings.Join(fields, ",") != strings.Join(test.expected, ",") {
+				t.Errorf("Mismatched fields = %v, expected %v", fields, test.expected)
+			}
+		})
+	}
+}
+
+func TestVerifierReview(t *testing.T) {
+	wrong := "1. Computer Architecture [CRN 40649], room 122, 2:40 PM\n2. Laboratory [CRN 42345], room 307, 4:45 PM\n3. Laboratory [CRN 42346], room 122"
+
+	tests := []struct {
+		name      string
+		mode      string
+		reprompt  string
+		outcome   string
+		answer    string
+		remaining int
+	}{
+		{name: "off", mode: verifyOff, outcome: outcomeSkipped, answer: wrong},
+		{name: "warn", mode: verifyWarn, outcome: outcomeWarned, answer: wrong, remaining: 2},
+		{
+			name:    "correct",
+			mode:    verifyCorrect,
+			outcome: outcomeCorrected,
+			answer:  "1. Computer Architecture [CRN 40649], room 307, 2:40 PM\n2. Laboratory [CRN 42345], room 307, 4:45 PM\n3. Laboratory [CRN 42346], room 307",
+		},
+		{
+			name:     "reprompt",
+			mode:     verifyReprompt,
+			reprompt: "Computer Architecture [CRN 40649] meets in room 307.",
+			outcome:  outcomeReprompt,
+			answer:   "Computer Architecture [CRN 40649] meets in room 307.",
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			logPath := filepath.Join(t.TempDir(), "verification-log.jsonl")
+			verifier, err := NewVerifier(test.mode, logPath)
+			if err != nil {
+				t.Fatalf("Error creating verifier: %v", err)
+			}
+
+			answer, outcome := verifier.Review("Where is CS 315?", wrong, verifierSources, func(feedback string) (string, error) {
+				if !strings.Contains(feedback, "room 122") {
+					t.Errorf("Feedback does not list the mismatch: %s", feedback)
+				}
+				return test.reprompt, nil
+			})
+			if answer != test.answer {
+				t.Errorf("Answer = %q, expected %q", answer, test.answer)
+			}
+			if outcome.Outcome != test.outcome {
+				t.Errorf("Outcome = %s, expected %s", outcome.Outcome, test.outcome)
+			}
+			if len(outcome.Remaining) != test.remaining {
+				t.Errorf("Remaining = %v, expected %d", outcome.Warnings(), test.remaining)
+			}
+
+			// every outcome but "off" is logged
+			file, err := os.Open(logPath)
+			if test.mode == verifyOff {
+				if err == nil {
+					t.Errorf("Expected no log when verification is off")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Error opening log: %v", err)
+			}
+			defer file.Close()
+			scanner := bufio.NewScanner(file)
+			if !scanner.Scan() {
+				t.Fatalf("Log is empty")
+			}
+			var logged VerificationOutcome
+			if err := json.Unmarshal(scanner.Bytes(), &logged); err != nil {
+				t.Fatalf("Error unmarshaling log entry: %v", err)
+			}
+			if logged.Outcome != test.outcome || logged.FinalAnswer != test.answer {
+				t.Errorf("Logged %+v", logged)
+			}
+		})
+	}
+}
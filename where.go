@@ -0,0 +1,141 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// reports whether a record's metadata satisfies a Chroma-style where filter, e.g.
+// {"$or": [{"Subject": "CS"}, {"BeginTime": {"$gte": "1200"}}]}
+func matchesWhere(metadata map[string]interface{}, where map[string]interface{}) bool {
+	for key, condition := range where {
+		switch key {
+		case "$and":
+			for _, sub := range whereConditions(condition) {
+				if !matchesWhere(metadata, sub) {
+					return false
+				}
+			}
+		case "$or":
+			conditions := whereConditions(condition)
+			matched := len(conditions) == 0
+			for _, sub := range conditions {
+				if matchesWhere(metadata, sub) {
+					matched = true
+					break
+				}
+			}
+			if !matched {
+				return false
+			}
+		default:
+			if !matchesField(metadata[key], condition) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// the sub-filters of an $and/$or, which are []interface{} when decoded from JSON
+func whereConditions(condition interface{}) []map[string]interface{} {
+	switch conditions := condition.(type) {
+	case []map[string]interface{}:
+		return conditions
+	case []interface{}:
+		var maps []map[string]interface{}
+		for _, sub := range conditions {
+			if m, ok := sub.(map[string]interface{}); ok {
+				maps = append(maps, m)
+			}
+		}
+		return maps
+	}
+	return nil
+}
+
+// compares a metadata value with a literal or an operator such as {"$in": [...]}
+func matchesField(value interface{}, condition interface{}) bool {
+	operators, ok := condition.(map[string]interface{})
+	if !ok {
+		return compareValues(value, condition) == 0
+	}
+
+	for operator, operand := range operators {
+		var matched bool
+		switch operator {
+		case "$eq":
+			matched = compareValues(value, operand) == 0
+		case "$ne":
+			matched = compareValues(value, operand) != 0
+		case "$gt":
+			matched = value != nil && compareValues(value, operand) > 0
+		case "$gte":
+			matched = value != nil && compareValues(value, operand) >= 0
+		case "$lt":
+			matched = value != nil && compareValues(value, operand) < 0
+		case "$lte":
+			matched = value != nil && compareValues(value, operand) <= 0
+		case "$in", "$nin":
+			for _, candidate := range operandList(operand) {
+				if compareValues(value, candidate) == 0 {
+					matched = true
+					break
+				}
+			}
+			if operator == "$nin" {
+				matched = !matched
+			}
+		}
+		if !matched {
+			return false
+		}
+	}
+	return true
+}
+
+func operandList(operand interface{}) []interface{} {
+	switch list := operand.(type) {
+	case []interface{}:
+		return list
+	case []string:
+		values := make([]interface{}, len(list))
+		for i, v := range list {
+			values[i] = v
+		}
+		return values
+	}
+	return []interface{}{operand}
+}
+
+// orders two metadata values: numbers numerically, everything else by its string form
+func compareValues(a, b interface{}) int {
+	if x, ok := toFloat(a); ok {
+		if y, ok := toFloat(b); ok {
+			switch {
+			case x < y:
+				return -1
+			case x > y:
+				return 1
+			}
+			return 0
+		}
+	}
+	return strings.Compare(fmt.Sprint(a), fmt.Sprint(b))
+}
+
+func toFloat(value interface{}) (float64, bool) {
+	switch v := value.(type) {
+	case int:
+		return float64(v), true
+	case int32:
+		return float64(v), true
+	case int64:
+		return float64(v), true
+	case float32:
+		return float64(v), true
+	case float64:
+		return v, true
+	}
+	return 0, false
+}